@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"com.deablabs.teno-voice/pkg/client"
+	"gopkg.in/yaml.v3"
+)
+
+func join(ctx context.Context, c *client.Client, args []string) error {
+	flags := flag.NewFlagSet("join", flag.ExitOnError)
+	file := flags.String("f", "", "YAML file with the join request")
+	flags.Parse(args)
+
+	if *file == "" {
+		return fmt.Errorf("join needs a join request file: tenoctl join -f call.yaml")
+	}
+
+	var joinReq client.JoinRequest
+	if err := readYAMLFile(*file, &joinReq); err != nil {
+		return err
+	}
+
+	message, err := c.Join(ctx, joinReq)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(message)
+	return nil
+}
+
+func leave(ctx context.Context, c *client.Client, args []string) error {
+	botID, guildID, _, err := callArgs("leave", args)
+	if err != nil {
+		return err
+	}
+
+	message, err := c.Leave(ctx, botID, guildID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(message)
+	return nil
+}
+
+func list(ctx context.Context, c *client.Client, args []string) error {
+	callInfos, err := c.ListCalls(ctx)
+	if err != nil {
+		return err
+	}
+
+	printCalls(callInfos)
+	return nil
+}
+
+func participants(ctx context.Context, c *client.Client, args []string) error {
+	botID, guildID, _, err := callArgs("participants", args)
+	if err != nil {
+		return err
+	}
+
+	roster, err := c.Participants(ctx, botID, guildID)
+	if err != nil {
+		return err
+	}
+
+	printParticipants(roster)
+	return nil
+}
+
+func summary(ctx context.Context, c *client.Client, args []string) error {
+	botID, guildID, _, err := callArgs("summary", args)
+	if err != nil {
+		return err
+	}
+
+	callSummary, err := c.Summary(ctx, botID, guildID)
+	if err != nil {
+		return err
+	}
+
+	if callSummary.Text == "" {
+		fmt.Println("No summary yet")
+		return nil
+	}
+	fmt.Printf("%d lines summarized, updated %s\n\n%s\n", callSummary.LinesSummarized, callSummary.UpdatedAt.Local().Format("15:04:05"), callSummary.Text)
+	return nil
+}
+
+func prompt(ctx context.Context, c *client.Client, args []string) error {
+	botID, guildID, _, err := callArgs("prompt", args)
+	if err != nil {
+		return err
+	}
+
+	rendered, err := c.Prompt(ctx, botID, guildID)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range rendered.Messages {
+		fmt.Printf("[%s]\n%s\n\n", message.Role, message.Content)
+	}
+	printTokenBreakdown(rendered.PromptTokensBySection)
+	return nil
+}
+
+func config(ctx context.Context, c *client.Client, args []string) error {
+	botID, guildID, rest, err := callArgs("config", args)
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("config", flag.ExitOnError)
+	file := flags.String("f", "", "YAML file with the config sections to replace")
+	flags.Parse(rest)
+
+	if *file == "" {
+		return fmt.Errorf("config needs a config file: tenoctl config <bot_id> <guild_id> -f patch.yaml")
+	}
+
+	var patch client.Config
+	if err := readYAMLFile(*file, &patch); err != nil {
+		return err
+	}
+
+	if err := c.UpdateConfig(ctx, botID, guildID, patch); err != nil {
+		return err
+	}
+
+	fmt.Println("Config updated")
+	return nil
+}
+
+func tail(ctx context.Context, c *client.Client, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("tail needs a stream: transcript, tools or usage")
+	}
+	stream := args[0]
+
+	botID, guildID, _, err := callArgs("tail "+stream, args[1:])
+	if err != nil {
+		return err
+	}
+
+	switch stream {
+	case "transcript":
+		lines := c.Transcript(ctx, botID, guildID)
+		defer lines.Close()
+		for lines.Next() {
+			fmt.Println(lines.Value())
+		}
+		return lines.Err()
+	case "tools", "tool-messages", "events":
+		events := c.ToolMessages(ctx, botID, guildID)
+		defer events.Close()
+		for events.Next() {
+			printEvent(events.Value())
+		}
+		return events.Err()
+	case "usage", "usages":
+		usages := c.Usages(ctx, botID, guildID)
+		defer usages.Close()
+		for usages.Next() {
+			printUsage(usages.Value())
+		}
+		return usages.Err()
+	default:
+		return fmt.Errorf("unknown stream %q, expected transcript, tools or usage", stream)
+	}
+}
+
+func say(ctx context.Context, c *client.Client, args []string) error {
+	botID, guildID, rest, err := callArgs("say", args)
+	if err != nil {
+		return err
+	}
+
+	text := strings.Join(rest, " ")
+	if text == "" {
+		return fmt.Errorf("say needs some text: tenoctl say <bot_id> <guild_id> <text>")
+	}
+
+	return c.Say(ctx, botID, guildID, text)
+}
+
+func respond(ctx context.Context, c *client.Client, args []string) error {
+	botID, guildID, _, err := callArgs("respond", args)
+	if err != nil {
+		return err
+	}
+
+	return c.Respond(ctx, botID, guildID)
+}
+
+func interrupt(ctx context.Context, c *client.Client, args []string) error {
+	botID, guildID, _, err := callArgs("interrupt", args)
+	if err != nil {
+		return err
+	}
+
+	return c.Interrupt(ctx, botID, guildID)
+}
+
+func confirmations(ctx context.Context, c *client.Client, args []string) error {
+	botID, guildID, _, err := callArgs("confirmations", args)
+	if err != nil {
+		return err
+	}
+
+	pending, err := c.Confirmations(ctx, botID, guildID)
+	if err != nil {
+		return err
+	}
+
+	printConfirmations(pending)
+	return nil
+}
+
+func tasks(ctx context.Context, c *client.Client, args []string) error {
+	botID, guildID, _, err := callArgs("tasks", args)
+	if err != nil {
+		return err
+	}
+
+	callTasks, err := c.Tasks(ctx, botID, guildID)
+	if err != nil {
+		return err
+	}
+
+	printTasks(callTasks)
+	return nil
+}
+
+func jobs(ctx context.Context, c *client.Client, args []string) error {
+	botID, guildID, _, err := callArgs("jobs", args)
+	if err != nil {
+		return err
+	}
+
+	scheduled, err := c.Jobs(ctx, botID, guildID)
+	if err != nil {
+		return err
+	}
+
+	printJobs(scheduled)
+	return nil
+}
+
+func schedule(ctx context.Context, c *client.Client, args []string) error {
+	botID, guildID, rest, err := callArgs("schedule", args)
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("schedule", flag.ExitOnError)
+	file := flags.String("f", "", "YAML file describing the job")
+	flags.Parse(rest)
+
+	if *file == "" {
+		return fmt.Errorf("schedule needs a job file: tenoctl schedule <bot_id> <guild_id> -f job.yaml")
+	}
+
+	var spec client.JobSpec
+	if err := readYAMLFile(*file, &spec); err != nil {
+		return err
+	}
+
+	job, err := c.ScheduleJob(ctx, botID, guildID, spec)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Scheduled job %s, first running at %s\n", job.ID, job.NextRun.Local().Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+func cancelJob(ctx context.Context, c *client.Client, args []string) error {
+	botID, guildID, rest, err := callArgs("cancel-job", args)
+	if err != nil {
+		return err
+	}
+
+	if len(rest) != 1 {
+		return fmt.Errorf("cancel-job needs a job ID: tenoctl cancel-job <bot_id> <guild_id> <id>")
+	}
+
+	return c.CancelJob(ctx, botID, guildID, rest[0])
+}
+
+func confirm(ctx context.Context, c *client.Client, args []string) error {
+	botID, guildID, rest, err := callArgs("confirm", args)
+	if err != nil {
+		return err
+	}
+
+	if len(rest) != 2 || (rest[1] != "yes" && rest[1] != "no") {
+		return fmt.Errorf("confirm needs a confirmation ID and an answer: tenoctl confirm <bot_id> <guild_id> <id> yes|no")
+	}
+
+	return c.ResolveConfirmation(ctx, botID, guildID, rest[0], rest[1] == "yes")
+}
+
+// callArgs reads the bot and guild IDs that identify a call, and returns the remaining arguments
+func callArgs(command string, args []string) (string, string, []string, error) {
+	if len(args) < 2 {
+		return "", "", nil, fmt.Errorf("%s needs a bot ID and a guild ID", command)
+	}
+	return args[0], args[1], args[2:], nil
+}
+
+// readYAMLFile reads a YAML file into target, replacing ${VAR} references with environment variables.
+// The YAML is converted to JSON first, so the same field names as the API are used, and unknown fields are reported.
+func readYAMLFile(path string, target interface{}) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var document interface{}
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(content))), &document); err != nil {
+		return fmt.Errorf("error parsing %s: %s", path, err)
+	}
+
+	jsonDocument, err := json.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("error converting %s to JSON: %s", path, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonDocument))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("error reading %s: %s", path, err)
+	}
+
+	return nil
+}
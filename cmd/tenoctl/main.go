@@ -0,0 +1,102 @@
+// tenoctl operates calls through the teno-voice REST API.
+//
+// The API address and key are read from the TENO_URL and TENO_API_KEY environment variables,
+// or from the -url and -key flags placed before the command.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"com.deablabs.teno-voice/pkg/client"
+)
+
+const usage = `Usage: tenoctl [-url URL] [-key API_KEY] <command> [arguments]
+
+Commands:
+  join -f call.yaml                        join a voice channel, as described by a YAML join request
+  leave <bot_id> <guild_id>                leave the voice channel
+  list                                     list the ongoing calls
+  participants <bot_id> <guild_id>         list the users in the call's voice channel
+  summary <bot_id> <guild_id>              print the rolling summary of the call
+  prompt <bot_id> <guild_id>               print the prompt the bot would respond to now
+  tasks <bot_id> <guild_id>                list the call's tasks with their statuses
+  jobs <bot_id> <guild_id>                 list the jobs scheduled in the call
+  schedule <bot_id> <guild_id> -f job.yaml schedule the job described by the YAML file
+  cancel-job <bot_id> <guild_id> <id>      cancel a scheduled job
+  config <bot_id> <guild_id> -f patch.yaml replace the config sections present in the YAML file
+  tail <stream> <bot_id> <guild_id>        follow a stream: transcript, tools or usage
+  say <bot_id> <guild_id> <text>           make the bot speak the text
+  respond <bot_id> <guild_id>              make the bot respond to the transcript now
+  interrupt <bot_id> <guild_id>            stop the bot's current response
+  confirmations <bot_id> <guild_id>        list the tool invocations waiting to be confirmed
+  confirm <bot_id> <guild_id> <id> yes|no  confirm or decline a tool invocation
+
+YAML files use the same field names as the JSON API, and ${VAR} references are replaced
+with environment variables, so secrets like the bot token can stay out of the file.
+`
+
+type command func(ctx context.Context, c *client.Client, args []string) error
+
+var commands = map[string]command{
+	"join":          join,
+	"leave":         leave,
+	"list":          list,
+	"participants":  participants,
+	"config":        config,
+	"tail":          tail,
+	"say":           say,
+	"respond":       respond,
+	"interrupt":     interrupt,
+	"confirmations": confirmations,
+	"confirm":       confirm,
+	"summary":       summary,
+	"prompt":        prompt,
+	"tasks":         tasks,
+	"jobs":          jobs,
+	"schedule":      schedule,
+	"cancel-job":    cancelJob,
+}
+
+func main() {
+	flags := flag.NewFlagSet("tenoctl", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+	}
+
+	url := flags.String("url", envOrDefault("TENO_URL", "http://localhost:8080"), "teno-voice API address")
+	apiKey := flags.String("key", os.Getenv("TENO_API_KEY"), "teno-voice API key")
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	run, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", flags.Arg(0))
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := client.NewClient(*url, *apiKey)
+
+	if err := run(ctx, c, flags.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func envOrDefault(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"com.deablabs.teno-voice/pkg/client"
+)
+
+func printCalls(callInfos []client.CallInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BOT ID\tGUILD ID\tCHANNEL ID\tBOT NAME\tPARTICIPANTS\tDURATION")
+	for _, call := range callInfos {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", call.BotID, call.GuildID, call.ChannelID, call.BotName, call.Participants, time.Since(call.StartTime).Round(time.Second))
+	}
+	w.Flush()
+}
+
+func printParticipants(participants []client.Participant) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER ID\tUSERNAME\tDISPLAY NAME\tBOT\tMUTED\tDEAFENED")
+	for _, p := range participants {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%t\t%t\n", p.UserID, p.Username, p.DisplayName, p.Bot, p.Muted, p.Deafened)
+	}
+	w.Flush()
+}
+
+func printConfirmations(confirmations []client.PendingConfirmation) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTOOL\tINPUT\tEXPIRES IN")
+	for _, confirmation := range confirmations {
+		expiresIn := time.Until(confirmation.ExpiresAt).Round(time.Second)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", confirmation.ID, confirmation.ToolMessage.Name, confirmation.ToolMessage.Input, expiresIn)
+	}
+	w.Flush()
+}
+
+func printTasks(tasks []client.Task) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATUS\tDUE")
+	for _, task := range tasks {
+		due := "-"
+		if task.Due != nil {
+			due = task.Due.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", task.ID, task.Name, task.Status, due)
+	}
+	w.Flush()
+}
+
+func printJobs(jobs []client.Job) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tNEXT RUN\tEVERY\tRUNS")
+	for _, job := range jobs {
+		name := job.Name
+		if name == "" {
+			name = job.Say + job.Instruction
+		}
+		every := "-"
+		if job.Every > 0 {
+			every = (time.Duration(job.Every) * time.Second).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", job.ID, name, job.NextRun.Local().Format("2006-01-02 15:04:05"), every, job.Runs)
+	}
+	w.Flush()
+}
+
+func printTokenBreakdown(tokens map[string]int) {
+	sections := make([]string, 0, len(tokens))
+	total := 0
+	for section, count := range tokens {
+		sections = append(sections, section)
+		total += count
+	}
+	sort.Strings(sections)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SECTION\tTOKENS")
+	for _, section := range sections {
+		fmt.Fprintf(w, "%s\t%d\n", section, tokens[section])
+	}
+	fmt.Fprintf(w, "total\t%d\n", total)
+	w.Flush()
+}
+
+func printEvent(event client.Event) {
+	prefix := fmt.Sprintf("[%s] %-12s", time.Now().Format("15:04:05"), event.Type)
+
+	switch event.Type {
+	case "tool-message":
+		var toolMessages []client.ToolMessage
+		if err := event.Decode(&toolMessages); err == nil {
+			for _, toolMessage := range toolMessages {
+				if toolMessage.SpeakerID != "" {
+					fmt.Printf("%s %s(%s) for %s\n", prefix, toolMessage.Name, toolMessage.Input, toolMessage.SpeakerID)
+				} else {
+					fmt.Printf("%s %s(%s)\n", prefix, toolMessage.Name, toolMessage.Input)
+				}
+			}
+			return
+		}
+	case "tool-rejected":
+		var rejection client.ToolRejection
+		if err := event.Decode(&rejection); err == nil {
+			fmt.Printf("%s %s(%s): %s\n", prefix, rejection.Name, rejection.Input, rejection.Reason)
+			return
+		}
+	case "call-ended":
+		var callEnded client.CallEndedEvent
+		if err := event.Decode(&callEnded); err == nil {
+			fmt.Printf("%s %s after %s\n", prefix, callEnded.Reason, (time.Duration(callEnded.Duration) * time.Second).Round(time.Second))
+			return
+		}
+	case "wake":
+		var wake client.WakeEvent
+		if err := event.Decode(&wake); err == nil {
+			if wake.Alias != "" {
+				fmt.Printf("%s woken by %s saying %q (score %.2f, combined %.2f)\n", prefix, wake.Username, wake.Alias, wake.Score, wake.Combined)
+			} else {
+				fmt.Printf("%s woken by %s (search confidence %.2f)\n", prefix, wake.Username, wake.SearchConfidence)
+			}
+			return
+		}
+	case "retrieval":
+		var retrieval client.RetrievalEvent
+		if err := event.Decode(&retrieval); err == nil {
+			chunks := make([]string, len(retrieval.Chunks))
+			for i, chunk := range retrieval.Chunks {
+				chunks[i] = fmt.Sprintf("%s #%d (%.2f)", chunk.Document, chunk.Index+1, chunk.Score)
+			}
+			fmt.Printf("%s %s: %s\n", prefix, retrieval.Retriever, strings.Join(chunks, ", "))
+			return
+		}
+	case "task":
+		var update client.TaskUpdate
+		if err := event.Decode(&update); err == nil {
+			switch update.Reason {
+			case "added", "removed", "overdue":
+				fmt.Printf("%s %s %s\n", prefix, update.Task.ID, update.Reason)
+			default:
+				fmt.Printf("%s %s %s -> %s (%s)\n", prefix, update.Task.ID, update.From, update.Task.Status, update.Reason)
+			}
+			return
+		}
+	case "job":
+		var update client.JobUpdate
+		if err := event.Decode(&update); err == nil {
+			fmt.Printf("%s %s %s\n", prefix, update.Job.ID, update.Reason)
+			return
+		}
+	case "presence":
+		var presence client.PresenceEvent
+		if err := event.Decode(&presence); err == nil {
+			fmt.Printf("%s %s %s\n", prefix, presence.Participant.DisplayName, presence.Type)
+			return
+		}
+	}
+
+	fmt.Printf("%s %s\n", prefix, indentJSON(event.Data))
+}
+
+func printUsage(usage client.UsageEvent) {
+	prefix := fmt.Sprintf("[%s] %-14s", time.Now().Format("15:04:05"), usage.UsageType)
+
+	switch usage.UsageType {
+	case "LLM":
+		fmt.Printf("%s %s %s (%s): %d prompt + %d completion tokens\n", prefix, usage.Service, usage.Model, usage.Purpose, usage.PromptTokens, usage.CompletionTokens)
+	case "TextToSpeech":
+		fmt.Printf("%s %s %s: %d characters\n", prefix, usage.Service, usage.Model, usage.Characters)
+	case "Transcription":
+		fmt.Printf("%s %s %s: %.2f minutes\n", prefix, usage.Service, usage.Model, usage.Minutes)
+	default:
+		fmt.Printf("%s %+v\n", prefix, usage)
+	}
+}
+
+// indentJSON returns data as indented JSON if it is JSON, or unchanged otherwise
+func indentJSON(data string) string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		return data
+	}
+
+	indented, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return data
+	}
+
+	return string(indented)
+}
@@ -9,8 +9,11 @@ require (
 	github.com/disgoorg/log v1.2.1
 	github.com/disgoorg/snowflake/v2 v2.0.1
 	github.com/go-chi/chi v1.5.4
+	github.com/go-playground/validator/v10 v10.14.1
 	github.com/joho/godotenv v1.5.1
+	github.com/pkoukk/tiktoken-go v0.1.5
 	github.com/redis/go-redis/v9 v9.0.5
+	gopkg.in/yaml.v3 v3.0.1
 	mccoy.space/g/ogg v0.0.0-20221103053400-1ea94e6f3152
 )
 
@@ -21,10 +24,8 @@ require (
 	github.com/gabriel-vasile/mimetype v1.4.2 // indirect
 	github.com/go-playground/locales v0.14.1 // indirect
 	github.com/go-playground/universal-translator v0.18.1 // indirect
-	github.com/go-playground/validator/v10 v10.14.1 // indirect
 	github.com/google/uuid v1.3.0 // indirect
 	github.com/leodido/go-urn v1.2.4 // indirect
-	github.com/pkoukk/tiktoken-go v0.1.5 // indirect
 	golang.org/x/net v0.10.0 // indirect
 	golang.org/x/text v0.11.0 // indirect
 )
@@ -3,6 +3,8 @@ package auth
 import (
 	"net/http"
 	"strings"
+
+	"com.deablabs.teno-voice/pkg/helpers"
 )
 
 func ApiKeyAuthMiddleware(apiKey string) func(next http.Handler) http.Handler {
@@ -10,7 +12,7 @@ func ApiKeyAuthMiddleware(apiKey string) func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				helpers.WriteError(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
 
@@ -18,7 +20,7 @@ func ApiKeyAuthMiddleware(apiKey string) func(next http.Handler) http.Handler {
 			if token == apiKey {
 				next.ServeHTTP(w, r)
 			} else {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				helpers.WriteError(w, "Unauthorized", http.StatusUnauthorized)
 			}
 		})
 	}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"com.deablabs.teno-voice/internal/deps"
@@ -18,6 +19,7 @@ import (
 	texttospeech "com.deablabs.teno-voice/internal/textToSpeech"
 	"com.deablabs.teno-voice/internal/transcript"
 	"com.deablabs.teno-voice/pkg/helpers"
+	"github.com/disgoorg/disgo/bot"
 	"github.com/disgoorg/disgo/voice"
 	"github.com/disgoorg/snowflake/v2"
 	"github.com/go-chi/chi"
@@ -30,7 +32,9 @@ type JoinRequest struct {
 	GuildID            string `validate:"required"`
 	ChannelID          string `validate:"required"`
 	RedisTranscriptKey string
-	Config             Config `validate:"required"`
+	// If set, the bot follows this user when they switch voice channels in the guild, and leaves when they disconnect
+	FollowUserID string
+	Config       Config `validate:"required"`
 }
 
 type Config struct {
@@ -41,17 +45,34 @@ type Config struct {
 	TTSConfig         *texttospeech.TTSConfigPayload  `validate:"required,TTSConfigValidation"`
 	TranscriptConfig  *transcript.TranscriptConfig    `validate:"required"`
 	TranscriberConfig *speechtotext.TranscriberConfig `validate:"required"`
+	LifecycleConfig   *LifecycleConfig
+	AccessPolicy      *responder.AccessPolicy
 }
 
 type Call struct {
-	startTime           time.Time
-	connection          *voice.Conn
-	closeSignalChan     chan struct{}
-	transcriptSSEChan   chan string
-	toolMessagesSSEChan chan responder.SSEMessage
-	usageSSEChan        chan string
-	responder           *responder.Responder
-	transcriber         *speechtotext.Transcriber
+	botID                 string
+	guildID               string
+	startTime             time.Time
+	connection            *discord.Connection
+	closeSignalChan       chan struct{}
+	transcriptSSEChan     chan string
+	toolMessagesSSEChan   chan responder.SSEMessage
+	usageSSEChan          chan string
+	responder             *responder.Responder
+	transcriber           *speechtotext.Transcriber
+	discordClient         bot.Client
+	speakers              map[snowflake.ID]*discord.Speaker
+	speakersMutex         *sync.Mutex
+	tracker               *discord.VoiceChannelTracker
+	config                atomic.Pointer[Config]
+	configMutex           sync.Mutex
+	emptySince            time.Time
+	followTarget          snowflake.ID
+	followedUserGoneSince time.Time
+	moveMutex             sync.Mutex
+	endReason             string
+	endOnce               sync.Once
+	mu                    sync.Mutex
 }
 
 var callsMutex sync.Mutex
@@ -65,9 +86,9 @@ func JoinVoiceChannel(dependencies *deps.Deps) func(w http.ResponseWriter, r *ht
 		if err != nil {
 			var mr *helpers.MalformedRequest
 			if errors.As(err, &mr) {
-				http.Error(w, mr.Msg, mr.Status)
+				helpers.WriteError(w, mr.Msg, mr.Status)
 			} else {
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				helpers.WriteError(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			}
 			return
 		}
@@ -75,44 +96,58 @@ func JoinVoiceChannel(dependencies *deps.Deps) func(w http.ResponseWriter, r *ht
 		// Validate Snowflake IDs
 		guildID, err := snowflake.Parse(joinReq.GuildID)
 		if err != nil {
-			http.Error(w, "Invalid Guild ID", http.StatusBadRequest)
+			helpers.WriteError(w, "Invalid Guild ID", http.StatusBadRequest)
 			return
 		}
 
 		channelID, err := snowflake.Parse(joinReq.ChannelID)
 		if err != nil {
-			http.Error(w, "Invalid Channel ID", http.StatusBadRequest)
+			helpers.WriteError(w, "Invalid Channel ID", http.StatusBadRequest)
 			return
 		}
 
+		var followUserID snowflake.ID
+		if joinReq.FollowUserID != "" {
+			followUserID, err = snowflake.Parse(joinReq.FollowUserID)
+			if err != nil {
+				helpers.WriteError(w, "Invalid Follow User ID", http.StatusBadRequest)
+				return
+			}
+		}
+
 		// Create a new validator instance
 		validate := dependencies.Validate
 
 		// Validate the struct
 		if err := validate.Struct(&joinReq); err != nil {
 			// Return an error to the client if the struct is not valid
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helpers.WriteError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := joinReq.Config.PromptContents.Validate(); err != nil {
+			helpers.WriteError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		// Create tts service
 		tts, err := texttospeech.ParseTTSConfig(*joinReq.Config.TTSConfig)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helpers.WriteError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		// Create llm service
 		llm, err := llm.ParseLLMConfig(*joinReq.Config.LLMConfig)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helpers.WriteError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		// Create discord client
 		discordClient, closeClient, err := discord.NewClient(context.Background(), joinReq.BotToken)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helpers.WriteError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
@@ -124,7 +159,8 @@ func JoinVoiceChannel(dependencies *deps.Deps) func(w http.ResponseWriter, r *ht
 		conn, err := discord.SetupVoiceConnection(joinCtx, &discordClient, guildID, channelID)
 
 		if err != nil {
-			w.Write([]byte(fmt.Sprintf("Could not join voice call: %s", err.Error())))
+			helpers.WriteError(w, fmt.Sprintf("Could not join voice call: %s", err.Error()), http.StatusBadGateway)
+			closeClient()
 			return
 		}
 
@@ -136,6 +172,8 @@ func JoinVoiceChannel(dependencies *deps.Deps) func(w http.ResponseWriter, r *ht
 			panic("error sending silence: " + err.Error())
 		}
 
+		connection := discord.NewConnection(discordClient, conn)
+
 		// Create a channel to wait for a signal to close the connection.
 		closeSignal := make(chan struct{})
 
@@ -159,15 +197,45 @@ func JoinVoiceChannel(dependencies *deps.Deps) func(w http.ResponseWriter, r *ht
 		ongoingCtx, cancel := context.WithCancel(context.Background())
 
 		playAudioChannel := make(chan []byte)
+		framesWritten := &atomic.Int64{}
+
+		Speakers := make(map[snowflake.ID]*discord.Speaker)
+		newSpeakerMutex := &sync.Mutex{}
+
+		if joinReq.Config.LifecycleConfig == nil {
+			lifecycleConfig := defaultLifecycleConfig
+			joinReq.Config.LifecycleConfig = &lifecycleConfig
+		}
+
+		// Create the call before the responder, whose goroutines can end it and list its participants as soon as it starts.
+		// Its responder and transcriber are set before anything else uses them.
+		newCall := &Call{
+			botID:               joinReq.BotID,
+			guildID:             joinReq.GuildID,
+			startTime:           time.Now(),
+			connection:          connection,
+			closeSignalChan:     closeSignal,
+			transcriptSSEChan:   transcriptSSEChannel,
+			toolMessagesSSEChan: toolMessagesSSEChannel,
+			usageSSEChan:        usageSSEChannel,
+			discordClient:       discordClient,
+			speakers:            Speakers,
+			speakersMutex:       newSpeakerMutex,
+		}
+		newCall.config.Store(&joinReq.Config)
+		newCall.tracker = discord.NewVoiceChannelTracker(discordClient, connection, newCall.handlePresenceEvent)
 
 		responderArgs := responder.NewResponderArgs{
-			BotName:                joinReq.Config.BotName,
+			Settings: &responder.Settings{
+				BotName:        joinReq.Config.BotName,
+				VoiceUXConfig:  *joinReq.Config.VoiceUXConfig,
+				PromptContents: *joinReq.Config.PromptContents,
+				TTSService:     tts,
+				LLMService:     llm,
+				AccessPolicy:   joinReq.Config.AccessPolicy,
+			},
 			PlayAudioChannel:       playAudioChannel,
-			Conn:                   &conn,
-			TTSService:             &tts,
-			LLMService:             &llm,
-			VoiceUXConfig:          *joinReq.Config.VoiceUXConfig,
-			PromptContents:         joinReq.Config.PromptContents,
+			Conn:                   connection,
 			TranscriptSSEChannel:   transcriptSSEChannel,
 			ToolMessagesSSEChannel: toolMessagesSSEChannel,
 			UsageSSEChannel:        usageSSEChannel,
@@ -175,25 +243,25 @@ func JoinVoiceChannel(dependencies *deps.Deps) func(w http.ResponseWriter, r *ht
 			RedisTranscriptKey:     joinReq.RedisTranscriptKey,
 			TranscriptConfig:       *joinReq.Config.TranscriptConfig,
 			BotId:                  discordClient.ID(),
+			FramesWritten:          framesWritten,
+			Leave: func() {
+				newCall.End(EndReasonBotLeft)
+			},
+			MemberRoles: connection.MemberRoles,
+			Participants: func() []promptbuilder.Participant {
+				return newCall.promptParticipants()
+			},
+			SettingsChanged: newCall.syncConfig,
 		}
 
 		responder := responder.NewResponder(ongoingCtx, responderArgs)
 
-		transcriber := speechtotext.NewTranscriber(joinReq.Config.BotName, *joinReq.Config.TranscriberConfig, responder)
+		transcriber := speechtotext.NewTranscriber(dependencies.Deepgram, joinReq.Config.BotName, *joinReq.Config.TranscriberConfig, responder)
 
-		Speakers := make(map[snowflake.ID]*discord.Speaker)
+		newCall.responder = responder
+		newCall.transcriber = transcriber
 
-		// Create call
-		newCall := &Call{
-			startTime:           time.Now(),
-			connection:          &conn,
-			closeSignalChan:     closeSignal,
-			transcriptSSEChan:   transcriptSSEChannel,
-			toolMessagesSSEChan: toolMessagesSSEChannel,
-			usageSSEChan:        usageSSEChannel,
-			responder:           responder,
-			transcriber:         transcriber,
-		}
+		stopTrackingParticipants := newCall.trackParticipants()
 
 		callId := joinReq.BotID + "-" + joinReq.GuildID
 
@@ -202,24 +270,46 @@ func JoinVoiceChannel(dependencies *deps.Deps) func(w http.ResponseWriter, r *ht
 		calls[callId] = newCall
 		callsMutex.Unlock()
 
-		go discord.WriteToVoiceConnection(ongoingCtx, &conn, playAudioChannel)
+		go discord.WriteToVoiceConnection(ongoingCtx, connection, playAudioChannel, framesWritten)
 
-		newSpeakerMutex := sync.Mutex{}
-		go discord.HandleIncomingPackets(ongoingCtx, cancel, &discordClient, &conn, Speakers, &newSpeakerMutex, transcriber)
+		go discord.HandleIncomingPackets(ongoingCtx, cancel, &discordClient, connection, Speakers, newSpeakerMutex, transcriber)
+
+		stopFollowingUser := func() {}
+		if followUserID != 0 {
+			stopFollowingUser = newCall.followUser(ongoingCtx, followUserID)
+		}
+
+		go newCall.monitorLifecycle(ongoingCtx)
 
 		go func() {
 			select {
 			case <-closeSignal:
 			case <-ongoingCtx.Done():
+				newCall.End(EndReasonDisconnected)
 			}
 
+			stopTrackingParticipants()
+			stopFollowingUser()
+
+			newCall.mu.Lock()
+			endReason := newCall.endReason
+			newCall.mu.Unlock()
+
+			responder.SendJSONEvent("call-ended", CallEndedEvent{
+				Reason:   endReason,
+				Duration: time.Since(newCall.startTime).Seconds(),
+			})
+
 			responder.Cleanup()
 
 			leaveCtx, leaveCancel := context.WithTimeout(context.Background(), time.Second*10)
 			defer leaveCancel()
-			conn.Close(leaveCtx)
+			connection.Close(leaveCtx)
 			closeClient()
 
+			// Close the Deepgram streams once no more packets can arrive
+			discord.CloseSpeakers(Speakers, newSpeakerMutex)
+
 			// Clean up the call from the calls map.
 			callsMutex.Lock()
 			delete(calls, callId)
@@ -227,9 +317,6 @@ func JoinVoiceChannel(dependencies *deps.Deps) func(w http.ResponseWriter, r *ht
 
 			// Cancel the context.
 			cancel()
-
-			// Close the closeSignal channel.
-			close(closeSignal)
 		}()
 
 		w.Write([]byte("Joined voice channel"))
@@ -250,8 +337,8 @@ func LeaveVoiceChannel(dependencies *deps.Deps) http.HandlerFunc {
 			return
 		}
 
-		// Send a signal on the closeSignal channel.
-		call.closeSignalChan <- struct{}{}
+		// End the call, which closes the closeSignal channel.
+		call.End(EndReasonLeaveRequested)
 
 		// Remove the closeSignal from the closeChannels map.
 		delete(calls, callId)
@@ -264,15 +351,9 @@ func UpdateConfig(dependencies *deps.Deps) func(w http.ResponseWriter, r *http.R
 	return func(w http.ResponseWriter, r *http.Request) {
 		var config Config
 
-		// Create a new validator instance
-		validate := dependencies.Validate
-
-		callId := chi.URLParam(r, "bot_id") + "-" + chi.URLParam(r, "guild_id")
-
-		// Get the call for the given guildID
-		call, ok := calls[callId]
+		call, ok := getCall(r)
 		if !ok {
-			http.Error(w, "Call not found", http.StatusNotFound)
+			helpers.WriteError(w, "Call not found", http.StatusNotFound)
 			return
 		}
 
@@ -280,95 +361,16 @@ func UpdateConfig(dependencies *deps.Deps) func(w http.ResponseWriter, r *http.R
 		if err != nil {
 			var mr *helpers.MalformedRequest
 			if errors.As(err, &mr) {
-				http.Error(w, mr.Msg, mr.Status)
+				helpers.WriteError(w, mr.Msg, mr.Status)
 			} else {
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				helpers.WriteError(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			}
 			return
 		}
 
-		if config.BotName != "" {
-			call.transcriber.BotName = config.BotName
-			call.responder.BotName = config.BotName
-		}
-
-		if config.TranscriberConfig != nil {
-			if err := validate.Struct(config.TranscriberConfig); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			call.transcriber.Config = *config.TranscriberConfig
-
-		}
-
-		if config.VoiceUXConfig != nil {
-			if err := validate.Struct(config.VoiceUXConfig); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			call.responder.VoiceUXConfig = *config.VoiceUXConfig
-		}
-
-		if config.PromptContents != nil {
-			if err := validate.Struct(config.PromptContents); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-
-			// oldNumDocuments := len(call.responder.PromptContents.Documents)
-			// newNumDocuments := len(config.PromptContents.Documents)
-
-			oldNumTasks := len(call.responder.PromptContents.Tasks)
-			newNumTasks := len(config.PromptContents.Tasks)
-
-			shouldRespond := oldNumTasks < newNumTasks
-
-			call.responder.PromptContents = *config.PromptContents
-
-			if shouldRespond && time.Since(call.startTime) > time.Second*3 {
-				// Get names of the new documents
-				// newDocuments := call.responder.PromptContents.Documents[oldNumDocuments:]
-				// newDocumentNames := make([]string, len(newDocuments))
-				// for i, doc := range newDocuments {
-				// 	newDocumentNames[i] = doc.Name
-				// }
-
-				// Get names of the new tasks
-				newTasks := call.responder.PromptContents.Tasks[oldNumTasks:]
-				newTaskNames := make([]string, len(newTasks))
-				for i, task := range newTasks {
-					newTaskNames[i] = task.Name
-				}
-
-				call.responder.Transcript.AddTaskReminderLine(newTaskNames[0])
-				call.responder.AttemptToRespond(false)
-			}
-		}
-
-		if config.TranscriptConfig != nil {
-			if err := validate.Struct(config.TranscriptConfig); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			call.responder.Transcript.Config = *config.TranscriptConfig
-		}
-
-		if config.TTSConfig != nil {
-			tts, err := texttospeech.ParseTTSConfig(*config.TTSConfig)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-			}
-			call.responder.TtsService = tts
-
-		}
-
-		if config.LLMConfig != nil {
-			llm, err := llm.ParseLLMConfig(*config.LLMConfig)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-			}
-
-			call.responder.LlmService = llm
+		if err := call.applyConfig(dependencies.Validate, config); err != nil {
+			helpers.WriteError(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
 		w.WriteHeader(http.StatusOK)
@@ -381,17 +383,13 @@ func TranscriptSSEHandler(dependencies *deps.Deps) http.HandlerFunc {
 
 		callsMutex.Lock()
 		call, ok := calls[callId]
-		if !ok {
-			http.Error(w, "Not in voice call", http.StatusNotFound)
-			return
-		}
-		sseChannelForGuild := call.transcriptSSEChan
 		callsMutex.Unlock()
 
 		if !ok {
-			http.Error(w, "No active SSE channels for this guild", http.StatusNotFound)
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
 			return
 		}
+		sseChannelForGuild := call.transcriptSSEChan
 
 		// Set the necessary headers for SSE
 		w.Header().Set("Content-Type", "text/event-stream")
@@ -402,7 +400,7 @@ func TranscriptSSEHandler(dependencies *deps.Deps) http.HandlerFunc {
 		// Use a flusher to send data immediately to the client
 		flusher, ok := w.(http.Flusher)
 		if !ok {
-			http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+			helpers.WriteError(w, "Streaming unsupported!", http.StatusInternalServerError)
 			return
 		}
 
@@ -429,17 +427,13 @@ func ToolMessagesSSEHandler(dependencies *deps.Deps) http.HandlerFunc {
 
 		callsMutex.Lock()
 		call, ok := calls[callId]
-		if !ok {
-			http.Error(w, "Not in voice call", http.StatusNotFound)
-			return
-		}
-		toolMessagesSSEChannel := call.toolMessagesSSEChan
 		callsMutex.Unlock()
 
 		if !ok {
-			http.Error(w, "No active SSE channels for this guild", http.StatusNotFound)
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
 			return
 		}
+		toolMessagesSSEChannel := call.toolMessagesSSEChan
 
 		// Set the necessary headers for SSE
 		w.Header().Set("Content-Type", "text/event-stream")
@@ -450,7 +444,7 @@ func ToolMessagesSSEHandler(dependencies *deps.Deps) http.HandlerFunc {
 		// Use a flusher to send data immediately to the client
 		flusher, ok := w.(http.Flusher)
 		if !ok {
-			http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+			helpers.WriteError(w, "Streaming unsupported!", http.StatusInternalServerError)
 			return
 		}
 
@@ -484,17 +478,13 @@ func UsageSSEHandler(dependencies *deps.Deps) http.HandlerFunc {
 
 		callsMutex.Lock()
 		call, ok := calls[callId]
-		if !ok {
-			http.Error(w, "Not in voice call", http.StatusNotFound)
-			return
-		}
-		usageSSEChannel := call.usageSSEChan
 		callsMutex.Unlock()
 
 		if !ok {
-			http.Error(w, "No active SSE channels for this guild", http.StatusNotFound)
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
 			return
 		}
+		usageSSEChannel := call.usageSSEChan
 
 		// Set the necessary headers for SSE
 		w.Header().Set("Content-Type", "text/event-stream")
@@ -505,7 +495,7 @@ func UsageSSEHandler(dependencies *deps.Deps) http.HandlerFunc {
 		// Use a flusher to send data immediately to the client
 		flusher, ok := w.(http.Flusher)
 		if !ok {
-			http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+			helpers.WriteError(w, "Streaming unsupported!", http.StatusInternalServerError)
 			return
 		}
 
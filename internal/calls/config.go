@@ -0,0 +1,144 @@
+package calls
+
+import (
+	"time"
+
+	"com.deablabs.teno-voice/internal/llm"
+	"com.deablabs.teno-voice/internal/responder"
+	texttospeech "com.deablabs.teno-voice/internal/textToSpeech"
+	"github.com/go-playground/validator/v10"
+)
+
+// applyConfig replaces the sections of the call's config that are set in update. Every section is validated
+// and parsed before anything changes, so an invalid update leaves the call's config as it was.
+// The call's config is never modified in place: a new snapshot is built and swapped in.
+func (c *Call) applyConfig(validate *validator.Validate, update Config) error {
+	// Updates are built from the current snapshot, so they are applied one at a time
+	c.configMutex.Lock()
+	defer c.configMutex.Unlock()
+
+	current := c.config.Load()
+	next := *current
+
+	if update.BotName != "" {
+		next.BotName = update.BotName
+	}
+
+	if update.TranscriberConfig != nil {
+		if err := validate.Struct(update.TranscriberConfig); err != nil {
+			return err
+		}
+		next.TranscriberConfig = update.TranscriberConfig
+	}
+
+	if update.VoiceUXConfig != nil {
+		if err := validate.Struct(update.VoiceUXConfig); err != nil {
+			return err
+		}
+		next.VoiceUXConfig = update.VoiceUXConfig
+	}
+
+	if update.PromptContents != nil {
+		if err := validate.Struct(update.PromptContents); err != nil {
+			return err
+		}
+		if err := update.PromptContents.Validate(); err != nil {
+			return err
+		}
+		next.PromptContents = update.PromptContents
+	}
+
+	if update.TranscriptConfig != nil {
+		if err := validate.Struct(update.TranscriptConfig); err != nil {
+			return err
+		}
+		next.TranscriptConfig = update.TranscriptConfig
+	}
+
+	if update.LifecycleConfig != nil {
+		if err := validate.Struct(update.LifecycleConfig); err != nil {
+			return err
+		}
+		next.LifecycleConfig = update.LifecycleConfig
+	}
+
+	if update.AccessPolicy != nil {
+		if err := validate.Struct(update.AccessPolicy); err != nil {
+			return err
+		}
+		next.AccessPolicy = update.AccessPolicy
+	}
+
+	var tts texttospeech.TextToSpeechService
+	if update.TTSConfig != nil {
+		var err error
+		tts, err = texttospeech.ParseTTSConfig(*update.TTSConfig)
+		if err != nil {
+			return err
+		}
+		next.TTSConfig = update.TTSConfig
+	}
+
+	var llmService llm.LLMService
+	if update.LLMConfig != nil {
+		var err error
+		llmService, err = llm.ParseLLMConfig(*update.LLMConfig)
+		if err != nil {
+			return err
+		}
+		next.LLMConfig = update.LLMConfig
+	}
+
+	// Everything is valid, so swap in the new snapshots. The responder's settings are changed from its latest ones,
+	// which built-in tools like ChangeVoice may have changed since the call's config was last updated.
+	c.config.Store(&next)
+	c.responder.UpdateSettings(func(settings *responder.Settings) error {
+		if update.BotName != "" {
+			settings.BotName = update.BotName
+		}
+		if update.VoiceUXConfig != nil {
+			settings.VoiceUXConfig = *update.VoiceUXConfig
+		}
+		if update.PromptContents != nil {
+			settings.PromptContents = *update.PromptContents
+		}
+		if update.AccessPolicy != nil {
+			settings.AccessPolicy = update.AccessPolicy
+		}
+		if tts != nil {
+			settings.TTSService = tts
+		}
+		if llmService != nil {
+			settings.LLMService = llmService
+		}
+		return nil
+	})
+	c.transcriber.SetConfig(next.BotName, *next.TranscriberConfig)
+	c.responder.Transcript.SetConfig(*next.TranscriptConfig)
+
+	if update.PromptContents != nil {
+		// New tasks are brought up straight away, except right after joining, where they wait for their reminders like the ones in the join request
+		c.responder.SyncTasks(time.Since(c.startTime) > time.Second*3)
+	}
+
+	return nil
+}
+
+// syncConfig writes the changes built-in tools like SetSpeakingMode and ChangeVoice made to the responder's settings
+// back to the call's config. The settings are read while holding the config mutex, so a config update
+// running at the same time is never undone.
+func (c *Call) syncConfig(r *responder.Responder) {
+	c.configMutex.Lock()
+	defer c.configMutex.Unlock()
+
+	settings := r.Settings()
+	next := *c.config.Load()
+
+	voiceUXConfig := settings.VoiceUXConfig
+	next.VoiceUXConfig = &voiceUXConfig
+	if ttsConfig, ok := texttospeech.ConfigPayload(settings.TTSService); ok {
+		next.TTSConfig = &ttsConfig
+	}
+
+	c.config.Store(&next)
+}
@@ -0,0 +1,309 @@
+package calls
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"com.deablabs.teno-voice/internal/deps"
+	"com.deablabs.teno-voice/internal/responder"
+	"com.deablabs.teno-voice/pkg/helpers"
+	"github.com/go-chi/chi"
+)
+
+type CallInfo struct {
+	BotID        string
+	GuildID      string
+	ChannelID    string
+	BotName      string
+	StartTime    time.Time
+	Participants int
+}
+
+type SayRequest struct {
+	Text string `validate:"required"`
+}
+
+type ResolveConfirmationRequest struct {
+	Confirmed *bool `validate:"required"`
+}
+
+// getCall returns the call for the bot_id and guild_id URL parameters
+func getCall(r *http.Request) (*Call, bool) {
+	callId := chi.URLParam(r, "bot_id") + "-" + chi.URLParam(r, "guild_id")
+
+	callsMutex.Lock()
+	defer callsMutex.Unlock()
+
+	call, ok := calls[callId]
+	return call, ok
+}
+
+func ListCallsHandler(dependencies *deps.Deps) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callsMutex.Lock()
+		callInfos := make([]CallInfo, 0, len(calls))
+		for _, call := range calls {
+			channelID := ""
+			if id := call.connection.ChannelID(); id != nil {
+				channelID = id.String()
+			}
+
+			callInfos = append(callInfos, CallInfo{
+				BotID:        call.botID,
+				GuildID:      call.guildID,
+				ChannelID:    channelID,
+				BotName:      call.config.Load().BotName,
+				StartTime:    call.startTime,
+				Participants: len(call.tracker.Participants()),
+			})
+		}
+		callsMutex.Unlock()
+
+		sort.Slice(callInfos, func(i, j int) bool {
+			return callInfos[i].StartTime.Before(callInfos[j].StartTime)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(callInfos); err != nil {
+			helpers.WriteError(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// SayHandler makes the bot speak the given text, interrupting anything it is saying
+func SayHandler(dependencies *deps.Deps) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, ok := getCall(r)
+		if !ok {
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
+			return
+		}
+
+		var sayReq SayRequest
+		err := helpers.DecodeJSONBody(w, r, &sayReq)
+		if err != nil {
+			var mr *helpers.MalformedRequest
+			if errors.As(err, &mr) {
+				helpers.WriteError(w, mr.Msg, mr.Status)
+			} else {
+				helpers.WriteError(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if err := dependencies.Validate.Struct(&sayReq); err != nil || strings.TrimSpace(sayReq.Text) == "" {
+			helpers.WriteError(w, "Text is required", http.StatusBadRequest)
+			return
+		}
+
+		call.responder.Say(sayReq.Text)
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// RespondHandler asks the bot to respond to the transcript now, as if someone had just spoken
+func RespondHandler(dependencies *deps.Deps) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, ok := getCall(r)
+		if !ok {
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
+			return
+		}
+
+		call.responder.AttemptToRespond(false)
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// InterruptHandler stops the bot's current response
+func InterruptHandler(dependencies *deps.Deps) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, ok := getCall(r)
+		if !ok {
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
+			return
+		}
+
+		call.responder.Interrupt()
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// ConfirmationsHandler returns the tool invocations waiting to be confirmed
+func ConfirmationsHandler(dependencies *deps.Deps) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, ok := getCall(r)
+		if !ok {
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(call.responder.PendingConfirmations()); err != nil {
+			helpers.WriteError(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// SummaryHandler returns the rolling summary of the part of the call that is no longer in the transcript
+func SummaryHandler(dependencies *deps.Deps) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, ok := getCall(r)
+		if !ok {
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(call.responder.Transcript.Summary()); err != nil {
+			helpers.WriteError(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// TasksHandler returns the call's tasks with their statuses
+func TasksHandler(dependencies *deps.Deps) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, ok := getCall(r)
+		if !ok {
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(call.responder.Tasks()); err != nil {
+			helpers.WriteError(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// JobsHandler returns the jobs scheduled in the call, the next to run first
+func JobsHandler(dependencies *deps.Deps) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, ok := getCall(r)
+		if !ok {
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(call.responder.Jobs()); err != nil {
+			helpers.WriteError(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ScheduleJobHandler schedules a job in the call, and returns it
+func ScheduleJobHandler(dependencies *deps.Deps) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, ok := getCall(r)
+		if !ok {
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
+			return
+		}
+
+		var spec responder.JobSpec
+		err := helpers.DecodeJSONBody(w, r, &spec)
+		if err != nil {
+			var mr *helpers.MalformedRequest
+			if errors.As(err, &mr) {
+				helpers.WriteError(w, mr.Msg, mr.Status)
+			} else {
+				helpers.WriteError(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if err := dependencies.Validate.Struct(&spec); err != nil {
+			helpers.WriteError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		job, err := call.responder.Schedule(spec)
+		if err != nil {
+			helpers.WriteError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(job); err != nil {
+			helpers.WriteError(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// CancelJobHandler removes a scheduled job
+func CancelJobHandler(dependencies *deps.Deps) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, ok := getCall(r)
+		if !ok {
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
+			return
+		}
+
+		if err := call.responder.CancelJob(chi.URLParam(r, "job_id")); err != nil {
+			helpers.WriteError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// PromptHandler renders the prompt the bot would respond to now, without calling the LLM
+func PromptHandler(dependencies *deps.Deps) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, ok := getCall(r)
+		if !ok {
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(call.responder.RenderPrompt(r.Context())); err != nil {
+			helpers.WriteError(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ResolveConfirmationHandler confirms or declines a pending tool invocation
+func ResolveConfirmationHandler(dependencies *deps.Deps) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, ok := getCall(r)
+		if !ok {
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
+			return
+		}
+
+		var resolveReq ResolveConfirmationRequest
+		err := helpers.DecodeJSONBody(w, r, &resolveReq)
+		if err != nil {
+			var mr *helpers.MalformedRequest
+			if errors.As(err, &mr) {
+				helpers.WriteError(w, mr.Msg, mr.Status)
+			} else {
+				helpers.WriteError(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if err := dependencies.Validate.Struct(&resolveReq); err != nil {
+			helpers.WriteError(w, "Confirmed is required", http.StatusBadRequest)
+			return
+		}
+
+		if err := call.responder.ResolveConfirmation(chi.URLParam(r, "confirmation_id"), *resolveReq.Confirmed); err != nil {
+			helpers.WriteError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
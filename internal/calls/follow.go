@@ -0,0 +1,69 @@
+package calls
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"com.deablabs.teno-voice/internal/discord"
+	"github.com/disgoorg/snowflake/v2"
+)
+
+type MovedEvent struct {
+	ChannelID string
+}
+
+// followUser moves the call to whichever voice channel the user joins, and returns a function that stops following
+func (c *Call) followUser(ctx context.Context, userID snowflake.ID) func() {
+	return discord.WatchUser(c.discordClient, c.connection.GuildID(), userID, func(channelID *snowflake.ID) {
+		c.mu.Lock()
+		if channelID == nil {
+			if c.followedUserGoneSince.IsZero() {
+				c.followedUserGoneSince = time.Now()
+			}
+			c.mu.Unlock()
+			return
+		}
+		c.followedUserGoneSince = time.Time{}
+		c.followTarget = *channelID
+		c.mu.Unlock()
+
+		// Moving waits on gateway events, so it can't block the event listener
+		go c.moveToFollowTarget(ctx)
+	})
+}
+
+// moveToFollowTarget moves the call to the latest channel the followed user joined
+func (c *Call) moveToFollowTarget(ctx context.Context) {
+	c.moveMutex.Lock()
+	defer c.moveMutex.Unlock()
+
+	c.mu.Lock()
+	target := c.followTarget
+	c.mu.Unlock()
+
+	if current := c.connection.ChannelID(); current != nil && *current == target {
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+		return
+	default:
+	}
+
+	moveCtx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+
+	if err := c.connection.MoveTo(moveCtx, target); err != nil {
+		log.Printf("Error following user to channel %s: %s", target, err)
+		return
+	}
+
+	c.tracker.Reset()
+	c.updateOccupancy(c.tracker.HumanCount())
+
+	c.responder.SendJSONEvent("moved", MovedEvent{
+		ChannelID: target.String(),
+	})
+}
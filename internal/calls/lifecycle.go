@@ -0,0 +1,114 @@
+package calls
+
+import (
+	"context"
+	"time"
+)
+
+// Reasons sent with the call-ended event
+const (
+	EndReasonLeaveRequested   = "leave-requested"
+	EndReasonBotLeft          = "bot-left"
+	EndReasonChannelEmpty     = "channel-empty"
+	EndReasonMaxDuration      = "max-duration"
+	EndReasonSilenceTimeout   = "silence-timeout"
+	EndReasonFollowedUserLeft = "followed-user-left"
+	EndReasonDisconnected     = "disconnected"
+)
+
+// LifecycleConfig controls when a call ends on its own. All values are in seconds, and 0 disables the corresponding check.
+type LifecycleConfig struct {
+	// How long to stay in the channel after the last human leaves
+	EmptyChannelGracePeriod int `validate:"min=0"`
+	// Maximum length of the call
+	MaxCallDuration int `validate:"min=0"`
+	// How long to stay in the call without any transcribed speech
+	SilenceTimeout int `validate:"min=0"`
+	// How long to stay in the call after the followed user disconnects, when following a user
+	FollowedUserGracePeriod int `validate:"min=0"`
+}
+
+// Used when a join request doesn't include a LifecycleConfig, so calls don't stay alive forever in an empty channel
+var defaultLifecycleConfig = LifecycleConfig{
+	EmptyChannelGracePeriod: 30,
+	FollowedUserGracePeriod: 30,
+}
+
+type CallEndedEvent struct {
+	Reason   string
+	Duration float64
+}
+
+// End ends the call with the given reason. Only the first reason is kept if End is called more than once.
+func (c *Call) End(reason string) {
+	c.endOnce.Do(func() {
+		c.mu.Lock()
+		c.endReason = reason
+		c.mu.Unlock()
+
+		close(c.closeSignalChan)
+	})
+}
+
+func (c *Call) updateOccupancy(humans int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if humans > 0 {
+		c.emptySince = time.Time{}
+	} else if c.emptySince.IsZero() {
+		c.emptySince = time.Now()
+	}
+}
+
+// monitorLifecycle ends the call once one of the limits in its LifecycleConfig is reached
+func (c *Call) monitorLifecycle(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.closeSignalChan:
+			return
+		case <-ticker.C:
+			if reason := c.checkLifecycle(); reason != "" {
+				c.End(reason)
+				return
+			}
+		}
+	}
+}
+
+// checkLifecycle returns the reason the call should end, or an empty string if it should keep going
+func (c *Call) checkLifecycle() string {
+	config := *c.config.Load().LifecycleConfig
+
+	c.mu.Lock()
+	emptySince := c.emptySince
+	followedUserGoneSince := c.followedUserGoneSince
+	c.mu.Unlock()
+
+	if config.EmptyChannelGracePeriod > 0 && !emptySince.IsZero() && time.Since(emptySince) >= seconds(config.EmptyChannelGracePeriod) {
+		return EndReasonChannelEmpty
+	}
+
+	if config.FollowedUserGracePeriod > 0 && !followedUserGoneSince.IsZero() && time.Since(followedUserGoneSince) >= seconds(config.FollowedUserGracePeriod) {
+		return EndReasonFollowedUserLeft
+	}
+
+	if config.MaxCallDuration > 0 && time.Since(c.startTime) >= seconds(config.MaxCallDuration) {
+		return EndReasonMaxDuration
+	}
+
+	if config.SilenceTimeout > 0 && time.Since(c.responder.LastTranscription()) >= seconds(config.SilenceTimeout) {
+		return EndReasonSilenceTimeout
+	}
+
+	return ""
+}
+
+func seconds(s int) time.Duration {
+	return time.Duration(s) * time.Second
+}
@@ -0,0 +1,71 @@
+package calls
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"com.deablabs.teno-voice/internal/deps"
+	"com.deablabs.teno-voice/internal/discord"
+	"com.deablabs.teno-voice/internal/llm/promptbuilder"
+
+	"com.deablabs.teno-voice/pkg/helpers"
+)
+
+// trackParticipants starts keeping the call's roster up to date, and returns a function that stops tracking.
+// The call's tracker must be set, and its responder too, since presence events go to it.
+func (c *Call) trackParticipants() func() {
+	c.updateOccupancy(c.tracker.HumanCount())
+	c.tracker.Start()
+
+	return c.tracker.Stop
+}
+
+func (c *Call) handlePresenceEvent(event discord.PresenceEvent) {
+	c.updateOccupancy(c.tracker.HumanCount())
+
+	c.responder.SendJSONEvent("presence", event)
+
+	if !c.responder.Transcript.Config().PresenceLines || event.Participant.Bot {
+		return
+	}
+
+	switch event.Type {
+	case discord.PresenceJoin:
+		c.responder.Transcript.AddPresenceLine(event.Participant.DisplayName, true)
+	case discord.PresenceLeave:
+		c.responder.Transcript.AddPresenceLine(event.Participant.DisplayName, false)
+	}
+}
+
+// promptParticipants lists the participants for prompt templates
+func (c *Call) promptParticipants() []promptbuilder.Participant {
+	if c == nil || c.tracker == nil {
+		return nil
+	}
+
+	var participants []promptbuilder.Participant
+	for _, participant := range c.tracker.Participants() {
+		participants = append(participants, promptbuilder.Participant{
+			UserID:      participant.UserID,
+			Username:    participant.Username,
+			DisplayName: participant.DisplayName,
+			Bot:         participant.Bot,
+		})
+	}
+	return participants
+}
+
+func ParticipantsHandler(dependencies *deps.Deps) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, ok := getCall(r)
+		if !ok {
+			helpers.WriteError(w, "Not in voice call", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(call.tracker.Participants()); err != nil {
+			helpers.WriteError(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package deps
 
 import (
+	"com.deablabs.teno-voice/pkg/deepgram"
 	"github.com/go-playground/validator/v10"
 	"github.com/redis/go-redis/v9"
 )
@@ -8,4 +9,5 @@ import (
 type Deps struct {
 	RedisClient *redis.Client
 	Validate    *validator.Validate
+	Deepgram    *deepgram.Client
 }
@@ -0,0 +1,153 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/voice"
+	"github.com/disgoorg/snowflake/v2"
+)
+
+// Connection holds the voice connection of a call. The underlying voice.Conn is replaced
+// when the bot moves to another channel, so long-running readers and writers should call Conn() on every use.
+type Connection struct {
+	client bot.Client
+	conn   voice.Conn
+	moving bool
+	mu     sync.RWMutex
+}
+
+func NewConnection(client bot.Client, conn voice.Conn) *Connection {
+	return &Connection{
+		client: client,
+		conn:   conn,
+	}
+}
+
+// Conn returns the current voice connection
+func (c *Connection) Conn() voice.Conn {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.conn
+}
+
+// Moving reports whether the connection is being replaced, in which case errors from the old connection are expected
+func (c *Connection) Moving() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.moving
+}
+
+func (c *Connection) GuildID() snowflake.ID {
+	return c.Conn().GuildID()
+}
+
+func (c *Connection) ChannelID() *snowflake.ID {
+	return c.Conn().ChannelID()
+}
+
+func (c *Connection) SetSpeaking(ctx context.Context, flags voice.SpeakingFlags) error {
+	return c.Conn().SetSpeaking(ctx, flags)
+}
+
+// MoveTo leaves the current voice channel and joins the given one in the same guild. The bot can only be in one
+// voice channel of a guild, so the old channel is left first, and rejoined if the new one can't be joined.
+func (c *Connection) MoveTo(ctx context.Context, channelID snowflake.ID) error {
+	c.mu.Lock()
+	if c.moving {
+		c.mu.Unlock()
+		return fmt.Errorf("already moving to another channel")
+	}
+	c.moving = true
+	oldConn := c.conn
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.moving = false
+		c.mu.Unlock()
+	}()
+
+	guildID := oldConn.GuildID()
+	var oldChannelID *snowflake.ID
+	if channelID := oldConn.ChannelID(); channelID != nil {
+		id := *channelID
+		oldChannelID = &id
+	}
+	oldConn.Close(ctx)
+
+	newConn, err := c.join(ctx, guildID, channelID)
+	if err == nil {
+		c.mu.Lock()
+		c.conn = newConn
+		c.mu.Unlock()
+		return nil
+	}
+
+	if oldChannelID == nil {
+		return err
+	}
+	// The join may have failed because ctx ran out, which shouldn't keep the bot out of the call
+	rejoinedConn, rejoinErr := c.join(context.Background(), guildID, *oldChannelID)
+	if rejoinErr != nil {
+		return fmt.Errorf("%s, and rejoining the previous channel failed: %s", err, rejoinErr)
+	}
+
+	c.mu.Lock()
+	c.conn = rejoinedConn
+	c.mu.Unlock()
+
+	return err
+}
+
+// join connects to a voice channel and gets it ready to play audio, closing the connection if that fails
+func (c *Connection) join(ctx context.Context, guildID snowflake.ID, channelID snowflake.ID) (voice.Conn, error) {
+	conn, err := SetupVoiceConnection(ctx, &c.client, guildID, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetSpeaking(ctx, voice.SpeakingFlagMicrophone); err != nil {
+		conn.Close(ctx)
+		return nil, fmt.Errorf("error setting speaking flag: %s", err)
+	}
+
+	if _, err := conn.UDP().Write(voice.SilenceAudioFrame); err != nil {
+		conn.Close(ctx)
+		return nil, fmt.Errorf("error sending silence: %s", err)
+	}
+
+	return conn, nil
+}
+
+func (c *Connection) Close(ctx context.Context) {
+	c.Conn().Close(ctx)
+}
+
+// MemberRoles returns the IDs of the roles a member of the guild has, or nil if the member can't be found
+func (c *Connection) MemberRoles(userID string) []string {
+	id, err := snowflake.Parse(userID)
+	if err != nil {
+		return nil
+	}
+
+	member, ok := c.client.Caches().Member(c.GuildID(), id)
+	if !ok {
+		fetched, err := c.client.Rest().GetMember(c.GuildID(), id)
+		if err != nil {
+			fmt.Printf("Error getting member %s: %v\n", userID, err)
+			return nil
+		}
+		member = *fetched
+	}
+
+	roles := make([]string, 0, len(member.RoleIDs))
+	for _, role := range member.RoleIDs {
+		roles = append(roles, role.String())
+	}
+	return roles
+}
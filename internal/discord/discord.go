@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	speechtotext "com.deablabs.teno-voice/internal/speechToText"
@@ -74,9 +75,8 @@ func SetupVoiceConnection(ctx context.Context, clientAdress *bot.Client, guildID
 	return conn, nil
 }
 
-func WriteToVoiceConnection(ctx context.Context, connection *voice.Conn, playAudioChannel chan []byte) {
-	conn := *connection
-
+// WriteToVoiceConnection plays the opus frames sent on playAudioChannel, and counts them in framesWritten
+func WriteToVoiceConnection(ctx context.Context, connection *Connection, playAudioChannel chan []byte, framesWritten *atomic.Int64) {
 	lastFrameSent := time.Now()
 
 	for {
@@ -87,9 +87,10 @@ func WriteToVoiceConnection(ctx context.Context, connection *voice.Conn, playAud
 			}
 
 			// Write audio bytes to UDP connection
-			if _, err := conn.UDP().Write(audioBytes); err != nil {
+			if _, err := connection.Conn().UDP().Write(audioBytes); err != nil {
 				fmt.Printf("error sending audio bytes: %s\n", err)
 			}
+			framesWritten.Add(1)
 
 			// Calculate sleep time
 			sleepTime := 20*time.Millisecond - time.Since(lastFrameSent)
@@ -106,8 +107,7 @@ func WriteToVoiceConnection(ctx context.Context, connection *voice.Conn, playAud
 	}
 }
 
-func HandleIncomingPackets(ctx context.Context, cancelFunc context.CancelFunc, clientAdress *bot.Client, connection *voice.Conn, speakers map[snowflake.ID]*Speaker, newSpeakerMutex *sync.Mutex, transcriber *speechtotext.Transcriber) {
-	conn := *connection
+func HandleIncomingPackets(ctx context.Context, cancelFunc context.CancelFunc, clientAdress *bot.Client, connection *Connection, speakers map[snowflake.ID]*Speaker, newSpeakerMutex *sync.Mutex, transcriber *speechtotext.Transcriber) {
 	client := *clientAdress
 
 	for {
@@ -115,8 +115,14 @@ func HandleIncomingPackets(ctx context.Context, cancelFunc context.CancelFunc, c
 		case <-ctx.Done():
 			return
 		default:
+			conn := connection.Conn()
 			packet, err := conn.UDP().ReadPacket()
 			if err != nil {
+				// The old connection is closed while moving to another channel, wait for the new one
+				if connection.Moving() {
+					time.Sleep(100 * time.Millisecond)
+					continue
+				}
 				if errors.Is(err, net.ErrClosed) {
 					println("connection closed")
 					cancelFunc()
@@ -167,3 +173,17 @@ func HandleIncomingPackets(ctx context.Context, cancelFunc context.CancelFunc, c
 		}
 	}
 }
+
+// CloseSpeakers closes the transcription streams of every active speaker so no Deepgram streams outlive the call
+func CloseSpeakers(speakers map[snowflake.ID]*Speaker, newSpeakerMutex *sync.Mutex) {
+	newSpeakerMutex.Lock()
+	defer newSpeakerMutex.Unlock()
+
+	for _, speaker := range speakers {
+		speaker.Mu.Lock()
+		if speaker.StreamActive {
+			speaker.Close()
+		}
+		speaker.Mu.Unlock()
+	}
+}
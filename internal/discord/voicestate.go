@@ -0,0 +1,220 @@
+package discord
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/disgoorg/disgo/bot"
+	disgoDiscord "github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/snowflake/v2"
+)
+
+// Types of presence events emitted by the VoiceChannelTracker
+const (
+	PresenceJoin     = "join"
+	PresenceLeave    = "leave"
+	PresenceMute     = "mute"
+	PresenceUnmute   = "unmute"
+	PresenceDeafen   = "deafen"
+	PresenceUndeafen = "undeafen"
+)
+
+type Participant struct {
+	UserID      string
+	Username    string
+	DisplayName string
+	Bot         bool
+	Muted       bool
+	Deafened    bool
+}
+
+type PresenceEvent struct {
+	Type        string
+	Participant Participant
+}
+
+// VoiceChannelTracker keeps a live roster of the users connected to the voice channel of a connection,
+// and reports joins, leaves, mutes and deafens as they happen
+type VoiceChannelTracker struct {
+	client         bot.Client
+	conn           *Connection
+	participants   map[snowflake.ID]Participant
+	onEvent        func(PresenceEvent)
+	removeListener func()
+	mu             sync.Mutex
+}
+
+func NewVoiceChannelTracker(client bot.Client, conn *Connection, onEvent func(PresenceEvent)) *VoiceChannelTracker {
+	tracker := &VoiceChannelTracker{
+		client:  client,
+		conn:    conn,
+		onEvent: onEvent,
+	}
+
+	tracker.Reset()
+
+	return tracker
+}
+
+// Reset rebuilds the roster from the users currently in the connection's voice channel, without emitting any events.
+// It is called when the tracker starts and after the bot moves to another channel.
+func (t *VoiceChannelTracker) Reset() {
+	participants := make(map[snowflake.ID]Participant)
+
+	if channelID := t.conn.ChannelID(); channelID != nil {
+		t.client.Caches().VoiceStatesForEach(t.conn.GuildID(), func(state disgoDiscord.VoiceState) {
+			if state.ChannelID == nil || *state.ChannelID != *channelID || state.UserID == t.client.ID() {
+				return
+			}
+			member, _ := t.client.Caches().Member(t.conn.GuildID(), state.UserID)
+			participants[state.UserID] = newParticipant(state, member)
+		})
+	}
+
+	t.mu.Lock()
+	t.participants = participants
+	t.mu.Unlock()
+}
+
+// Start starts listening for voice state updates
+func (t *VoiceChannelTracker) Start() {
+	listener := bot.NewListenerFunc(t.handleVoiceStateUpdate)
+	t.client.AddEventListeners(listener)
+	t.removeListener = func() {
+		t.client.RemoveEventListeners(listener)
+	}
+}
+
+// Stop stops listening for voice state updates
+func (t *VoiceChannelTracker) Stop() {
+	if t.removeListener != nil {
+		t.removeListener()
+	}
+}
+
+// Participants returns the users currently in the voice channel, sorted by display name
+func (t *VoiceChannelTracker) Participants() []Participant {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	participants := make([]Participant, 0, len(t.participants))
+	for _, participant := range t.participants {
+		participants = append(participants, participant)
+	}
+
+	sort.Slice(participants, func(i, j int) bool {
+		return participants[i].DisplayName < participants[j].DisplayName
+	})
+
+	return participants
+}
+
+// HumanCount returns the number of non-bot users in the voice channel
+func (t *VoiceChannelTracker) HumanCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	humans := 0
+	for _, participant := range t.participants {
+		if !participant.Bot {
+			humans++
+		}
+	}
+	return humans
+}
+
+func (t *VoiceChannelTracker) handleVoiceStateUpdate(e *events.GuildVoiceStateUpdate) {
+	state := e.VoiceState
+	if state.GuildID != t.conn.GuildID() || state.UserID == t.client.ID() {
+		return
+	}
+
+	channelID := t.conn.ChannelID()
+	inChannel := channelID != nil && state.ChannelID != nil && *state.ChannelID == *channelID
+
+	participant := newParticipant(state, e.Member)
+
+	t.mu.Lock()
+	previous, wasInChannel := t.participants[state.UserID]
+	if inChannel {
+		t.participants[state.UserID] = participant
+	} else {
+		delete(t.participants, state.UserID)
+	}
+	t.mu.Unlock()
+
+	switch {
+	case inChannel && !wasInChannel:
+		t.emit(PresenceJoin, participant)
+	case !inChannel && wasInChannel:
+		t.emit(PresenceLeave, previous)
+	case inChannel:
+		if participant.Muted != previous.Muted {
+			t.emit(toggleEvent(participant.Muted, PresenceMute, PresenceUnmute), participant)
+		}
+		if participant.Deafened != previous.Deafened {
+			t.emit(toggleEvent(participant.Deafened, PresenceDeafen, PresenceUndeafen), participant)
+		}
+	}
+}
+
+func (t *VoiceChannelTracker) emit(eventType string, participant Participant) {
+	if t.onEvent == nil {
+		return
+	}
+	t.onEvent(PresenceEvent{
+		Type:        eventType,
+		Participant: participant,
+	})
+}
+
+func toggleEvent(on bool, onEvent string, offEvent string) string {
+	if on {
+		return onEvent
+	}
+	return offEvent
+}
+
+func newParticipant(state disgoDiscord.VoiceState, member disgoDiscord.Member) Participant {
+	username := member.User.Username
+	displayName := member.EffectiveName()
+	if username == "" {
+		username = "User"
+		displayName = "User"
+	}
+
+	return Participant{
+		UserID:      state.UserID.String(),
+		Username:    username,
+		DisplayName: displayName,
+		Bot:         member.User.Bot,
+		Muted:       state.SelfMute || state.GuildMute,
+		Deafened:    state.SelfDeaf || state.GuildDeaf,
+	}
+}
+
+// WatchUser calls onChange with the user's new voice channel, or nil if they disconnected, every time
+// the user joins, leaves or switches voice channels in the guild. The returned function removes the listener.
+func WatchUser(client bot.Client, guildID snowflake.ID, userID snowflake.ID, onChange func(channelID *snowflake.ID)) func() {
+	listener := bot.NewListenerFunc(func(e *events.GuildVoiceStateUpdate) {
+		if e.VoiceState.GuildID != guildID || e.VoiceState.UserID != userID {
+			return
+		}
+
+		oldChannelID := e.OldVoiceState.ChannelID
+		newChannelID := e.VoiceState.ChannelID
+		if oldChannelID != nil && newChannelID != nil && *oldChannelID == *newChannelID {
+			// Only the mute or deafen state changed
+			return
+		}
+
+		onChange(newChannelID)
+	})
+
+	client.AddEventListeners(listener)
+
+	return func() {
+		client.RemoveEventListeners(listener)
+	}
+}
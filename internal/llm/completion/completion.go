@@ -0,0 +1,106 @@
+package completion
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Stream is a streamed chat completion, read one delta at a time until Recv returns io.EOF
+type Stream interface {
+	Recv() (Delta, error)
+	Close()
+}
+
+// Delta is one chunk of a streamed completion. Content is text to be spoken, and ToolCalls are fragments
+// of native function calls, which only services using native function calling produce.
+type Delta struct {
+	Content   string
+	ToolCalls []ToolCallDelta
+}
+
+// ToolCallDelta is a fragment of a streamed tool call. The first fragment of a call has its ID and name,
+// and the arguments JSON arrives in pieces that are joined in order. Index tells which call a fragment belongs to.
+type ToolCallDelta struct {
+	Index     int
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// ToolCall is a complete tool call, assembled from its fragments
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// ToolCallAccumulator assembles tool calls from the fragments in a stream, and hands each one out once it is complete
+type ToolCallAccumulator struct {
+	calls map[int]*ToolCall
+	taken map[int]bool
+}
+
+func NewToolCallAccumulator() *ToolCallAccumulator {
+	return &ToolCallAccumulator{
+		calls: make(map[int]*ToolCall),
+		taken: make(map[int]bool),
+	}
+}
+
+func (a *ToolCallAccumulator) Add(delta ToolCallDelta) {
+	call, ok := a.calls[delta.Index]
+	if !ok {
+		call = &ToolCall{}
+		a.calls[delta.Index] = call
+	}
+
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Name != "" {
+		call.Name = delta.Name
+	}
+	call.Arguments += delta.Arguments
+}
+
+// TakeCompleted returns the tool calls that are complete and weren't taken yet, in the order the model made them.
+// A call is complete once its arguments are a whole JSON object, or once a later call starts.
+// With streamEnded, every call left is complete.
+func (a *ToolCallAccumulator) TakeCompleted(streamEnded bool) []ToolCall {
+	indexes := make([]int, 0, len(a.calls))
+	for index := range a.calls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	var calls []ToolCall
+	for i, index := range indexes {
+		if a.taken[index] {
+			continue
+		}
+
+		call := a.calls[index]
+		laterCallStarted := i < len(indexes)-1
+		if !streamEnded && !laterCallStarted && !isJSONObject(call.Arguments) {
+			continue
+		}
+
+		a.taken[index] = true
+		calls = append(calls, *call)
+	}
+	return calls
+}
+
+func isJSONObject(text string) bool {
+	var object map[string]json.RawMessage
+	return json.Unmarshal([]byte(text), &object) == nil
+}
+
+// Argument returns an argument of the call as JSON, or nil if the arguments don't have it
+func (c ToolCall) Argument(name string) json.RawMessage {
+	var arguments map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(c.Arguments), &arguments); err != nil {
+		return nil
+	}
+	return arguments[name]
+}
@@ -1,15 +1,16 @@
 package llm
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
+	"com.deablabs.teno-voice/internal/llm/completion"
 	"com.deablabs.teno-voice/internal/llm/openai"
 	"com.deablabs.teno-voice/internal/llm/promptbuilder"
 	"com.deablabs.teno-voice/internal/transcript"
 	"com.deablabs.teno-voice/internal/usage"
 	"github.com/go-playground/validator/v10"
-	goOpenai "github.com/sashabaranov/go-openai"
 )
 
 type LLMConfigPayload struct {
@@ -18,7 +19,23 @@ type LLMConfigPayload struct {
 }
 
 type LLMService interface {
-	GetTranscriptResponseStream(transcript *transcript.Transcript, botName string, promptContents *promptbuilder.PromptContents) (*goOpenai.ChatCompletionStream, usage.LLMEvent, error)
+	// GetTranscriptResponseStream streams a response to the transcript. The stream ends early once ctx is cancelled.
+	GetTranscriptResponseStream(ctx context.Context, transcript *transcript.Transcript, promptContents *promptbuilder.PromptContents, data promptbuilder.TemplateData) (completion.Stream, usage.LLMEvent, error)
+	// RenderPrompt builds the prompt GetTranscriptResponseStream would send, without calling the model
+	RenderPrompt(transcript *transcript.Transcript, promptContents *promptbuilder.PromptContents, data promptbuilder.TemplateData) promptbuilder.RenderedPrompt
+	// NativeToolCalls reports whether the service returns tool calls as native function calls
+	// instead of after a '|' in the response text
+	NativeToolCalls() bool
+	// IsAddressedToBot asks a model if the last of the lines is addressed to the bot, for the DirectAddress speaking mode.
+	// The call's model is used if model is empty.
+	IsAddressedToBot(ctx context.Context, lines []transcript.Line, botName string, model string) (bool, usage.LLMEvent, error)
+	// Summarize folds transcript lines into a running summary of the call, for the rolling summary.
+	// The call's model is used if model is empty.
+	Summarize(ctx context.Context, summary string, lines []transcript.Line, botName string, model string, maxWords int) (string, usage.LLMEvent, error)
+	// TokenCount counts the tokens of text with the encoding of the call's model
+	TokenCount(text string) int
+	// Embed turns texts into embedding vectors with an embedding model, for embedding based document retrieval
+	Embed(ctx context.Context, texts []string, model string) ([][]float32, usage.LLMEvent, error)
 }
 
 func LLMConfigValidation(fl validator.FieldLevel) bool {
@@ -0,0 +1,57 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"com.deablabs.teno-voice/internal/transcript"
+	"com.deablabs.teno-voice/internal/usage"
+	goOpenai "github.com/sashabaranov/go-openai"
+)
+
+const addresseePrompt = `You are given the latest lines of a voice call transcript. %s is an AI voice assistant taking part in the call.
+Decide if the last line is addressed to %s, either by name or as a reply or follow-up question to something %s just said.
+Lines where people talk to each other, or talk about %s without talking to it, are not addressed to it.
+Answer with only "yes" or "no".`
+
+// IsAddressedToBot asks a model if the last of the lines is addressed to the bot. The call's model is used if model is empty.
+func (o *OpenAILLM) IsAddressedToBot(ctx context.Context, lines []transcript.Line, botName string, model string) (bool, usage.LLMEvent, error) {
+	if model == "" {
+		model = o.Config.Model
+	}
+
+	var conversation strings.Builder
+	for _, line := range lines {
+		username := line.Username
+		if line.Type == "assistant" {
+			username = botName
+		}
+		if username == "" {
+			continue
+		}
+		fmt.Fprintf(&conversation, "%s: %s\n", username, strings.TrimSpace(strings.TrimPrefix(line.Text, botName+": ")))
+	}
+
+	systemContent := fmt.Sprintf(addresseePrompt, botName, botName, botName, botName)
+	resp, err := o.client.CreateChatCompletion(ctx, goOpenai.ChatCompletionRequest{
+		Model:     model,
+		MaxTokens: 1,
+		Messages: []goOpenai.ChatCompletionMessage{
+			{Role: goOpenai.ChatMessageRoleSystem, Content: systemContent},
+			{Role: goOpenai.ChatMessageRoleUser, Content: conversation.String()},
+		},
+	})
+	if err != nil {
+		return false, usage.LLMEvent{}, err
+	}
+
+	usageEvent := usage.NewLLMEvent("service", usage.PurposeAddressee, model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	if len(resp.Choices) == 0 {
+		return false, *usageEvent, errors.New("no choices in response")
+	}
+
+	answer := strings.ToLower(strings.TrimSpace(resp.Choices[0].Message.Content))
+	return strings.HasPrefix(answer, "yes"), *usageEvent, nil
+}
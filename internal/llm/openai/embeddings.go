@@ -0,0 +1,36 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+
+	"com.deablabs.teno-voice/internal/usage"
+	goOpenai "github.com/sashabaranov/go-openai"
+)
+
+// Embed turns texts into embedding vectors with an embedding model, like text-embedding-ada-002
+func (o *OpenAILLM) Embed(ctx context.Context, texts []string, model string) ([][]float32, usage.LLMEvent, error) {
+	var embeddingModel goOpenai.EmbeddingModel
+	if err := embeddingModel.UnmarshalText([]byte(model)); err != nil || embeddingModel == goOpenai.Unknown {
+		return nil, usage.LLMEvent{}, fmt.Errorf("unknown embedding model: %s", model)
+	}
+
+	resp, err := o.client.CreateEmbeddings(ctx, goOpenai.EmbeddingRequestStrings{
+		Input: texts,
+		Model: embeddingModel,
+	})
+	if err != nil {
+		return nil, usage.LLMEvent{}, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, embedding := range resp.Data {
+		if embedding.Index < 0 || embedding.Index >= len(vectors) {
+			return nil, usage.LLMEvent{}, fmt.Errorf("embedding index %d out of range", embedding.Index)
+		}
+		vectors[embedding.Index] = embedding.Embedding
+	}
+
+	usageEvent := usage.NewLLMEvent("service", usage.PurposeEmbeddings, model, resp.Usage.PromptTokens, 0)
+	return vectors, *usageEvent, nil
+}
@@ -0,0 +1,214 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"com.deablabs.teno-voice/internal/llm/completion"
+	"com.deablabs.teno-voice/internal/llm/promptbuilder"
+	"com.deablabs.teno-voice/internal/responder/tools"
+	goOpenai "github.com/sashabaranov/go-openai"
+)
+
+// go-openai doesn't support the tools API yet, so requests with native function calling are made directly,
+// with the same base URL, organization and HTTP client as the go-openai client
+const chatCompletionsPath = "/chat/completions"
+
+type toolDefinition struct {
+	Type     string                      `json:"type"`
+	Function goOpenai.FunctionDefinition `json:"function"`
+}
+
+type toolsRequest struct {
+	Model     string                           `json:"model"`
+	Messages  []goOpenai.ChatCompletionMessage `json:"messages"`
+	MaxTokens int                              `json:"max_tokens,omitempty"`
+	Stream    bool                             `json:"stream"`
+	Tools     []toolDefinition                 `json:"tools,omitempty"`
+}
+
+type toolsStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+// toolDefinitions turns tools into function definitions. A tool with an object input schema uses it as its parameters,
+// and other tools take a single input parameter, described by the input guide or schema.
+func toolDefinitions(availableTools []tools.Tool) []toolDefinition {
+	definitions := make([]toolDefinition, 0, len(availableTools))
+	for _, tool := range availableTools {
+		description := tool.Description
+		if tool.OutputGuide != "" {
+			description += " Output: " + tool.OutputGuide
+		}
+
+		var input interface{} = map[string]interface{}{
+			"type":        "string",
+			"description": tool.InputGuide,
+		}
+		if len(tool.InputSchema) > 0 {
+			input = tool.InputSchema
+		}
+
+		var parameters interface{} = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"input": input,
+			},
+			"required": []string{"input"},
+		}
+		if tool.ObjectInput() {
+			parameters = tool.InputSchema
+			if tool.InputGuide != "" {
+				description += " Input: " + tool.InputGuide
+			}
+		}
+
+		definitions = append(definitions, toolDefinition{
+			Type: "function",
+			Function: goOpenai.FunctionDefinition{
+				Name:        tools.FunctionName(tool.Name),
+				Description: description,
+				Parameters:  parameters,
+			},
+		})
+	}
+	return definitions
+}
+
+// createToolsStream starts a streamed chat completion with the tools available as functions
+func (o *OpenAILLM) createToolsStream(ctx context.Context, messages []goOpenai.ChatCompletionMessage, availableTools []tools.Tool) (*toolsStream, error) {
+	body, err := json.Marshal(toolsRequest{
+		Model:     o.Config.Model,
+		Messages:  messages,
+		MaxTokens: promptbuilder.MaxResponseTokens,
+		Stream:    true,
+		Tools:     toolDefinitions(availableTools),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := strings.TrimSuffix(o.clientConfig.BaseURL, "/") + chatCompletionsPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+o.Config.ApiKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if o.clientConfig.OrgID != "" {
+		req.Header.Set("OpenAI-Organization", o.clientConfig.OrgID)
+	}
+
+	resp, err := o.clientConfig.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		errorBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(errorBody)))
+	}
+
+	return &toolsStream{
+		body:   resp.Body,
+		reader: bufio.NewReader(resp.Body),
+	}, nil
+}
+
+// toolsStream reads the server-sent events of a streamed chat completion with tools
+type toolsStream struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+}
+
+func (s *toolsStream) Recv() (completion.Delta, error) {
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) && strings.TrimSpace(line) == "" {
+				return completion.Delta{}, io.EOF
+			}
+			if !errors.Is(err, io.EOF) {
+				return completion.Delta{}, err
+			}
+		}
+
+		line = strings.TrimSpace(line)
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+
+		if data == "[DONE]" {
+			return completion.Delta{}, io.EOF
+		}
+
+		var chunk toolsStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return completion.Delta{}, fmt.Errorf("error parsing stream chunk: %s", err)
+		}
+
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		delta := completion.Delta{Content: chunk.Choices[0].Delta.Content}
+		for _, toolCall := range chunk.Choices[0].Delta.ToolCalls {
+			delta.ToolCalls = append(delta.ToolCalls, completion.ToolCallDelta{
+				Index:     toolCall.Index,
+				ID:        toolCall.ID,
+				Name:      toolCall.Function.Name,
+				Arguments: toolCall.Function.Arguments,
+			})
+		}
+		return delta, nil
+	}
+}
+
+func (s *toolsStream) Close() {
+	s.body.Close()
+}
+
+// legacyStream adapts go-openai's stream, for requests without native function calling
+type legacyStream struct {
+	stream *goOpenai.ChatCompletionStream
+}
+
+func (s *legacyStream) Recv() (completion.Delta, error) {
+	for {
+		response, err := s.stream.Recv()
+		if err != nil {
+			return completion.Delta{}, err
+		}
+		if len(response.Choices) == 0 {
+			continue
+		}
+		return completion.Delta{Content: response.Choices[0].Delta.Content}, nil
+	}
+}
+
+func (s *legacyStream) Close() {
+	s.stream.Close()
+}
@@ -2,8 +2,10 @@ package openai
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 
+	"com.deablabs.teno-voice/internal/llm/completion"
 	"com.deablabs.teno-voice/internal/llm/promptbuilder"
 	"com.deablabs.teno-voice/internal/llm/tiktoken"
 	"com.deablabs.teno-voice/internal/transcript"
@@ -14,28 +16,92 @@ import (
 type OpenAIConfig struct {
 	ApiKey string `validate:"required"`
 	Model  string `validate:"required"`
+	// FunctionCalling gives the model tools as native functions instead of the pipe-delimited tool protocol.
+	// Only enable it for models that support function calling.
+	FunctionCalling bool
 }
 
 type OpenAILLM struct {
 	Config OpenAIConfig
 	client *goOpenai.Client
+	// clientConfig is the client's config, for the requests go-openai can't make
+	clientConfig goOpenai.ClientConfig
 }
 
 func NewOpenAILLM(config OpenAIConfig) *OpenAILLM {
+	clientConfig := goOpenai.DefaultConfig(config.ApiKey)
 	return &OpenAILLM{
-		Config: config,
-		client: goOpenai.NewClient(config.ApiKey),
+		Config:       config,
+		client:       goOpenai.NewClientWithConfig(clientConfig),
+		clientConfig: clientConfig,
 	}
 }
 
-func (o *OpenAILLM) GetTranscriptResponseStream(transcript *transcript.Transcript, botName string, promptContents *promptbuilder.PromptContents) (*goOpenai.ChatCompletionStream, usage.LLMEvent, error) {
-	pb := promptbuilder.NewPromptBuilder(botName, transcript, promptContents)
+func (o *OpenAILLM) GetTranscriptResponseStream(ctx context.Context, transcript *transcript.Transcript, promptContents *promptbuilder.PromptContents, data promptbuilder.TemplateData) (completion.Stream, usage.LLMEvent, error) {
+	pb, messages := o.buildPrompt(transcript, promptContents, data)
+	nativeTools := o.Config.FunctionCalling && len(promptContents.AllTools()) > 0
+
+	var stream completion.Stream
+	if nativeTools {
+		toolsStream, err := o.createToolsStream(ctx, messages, promptContents.AllTools())
+		if err != nil {
+			return nil, usage.LLMEvent{}, errors.New("ChatCompletionStream error: " + err.Error())
+		}
+		stream = toolsStream
+	} else {
+		req := goOpenai.ChatCompletionRequest{
+			Model:     o.Config.Model,
+			MaxTokens: promptbuilder.MaxResponseTokens,
+			Messages:  messages,
+			Stream:    true,
+		}
+
+		// Log prompt
+		// for _, message := range messages {
+		// 	log.Print("[" + message.Role + "] " + message.Content)
+		// }
+
+		chatStream, err := o.client.CreateChatCompletionStream(ctx, req)
+		if err != nil {
+			return nil, usage.LLMEvent{}, errors.New("ChatCompletionStream error: " + err.Error())
+		}
+		stream = &legacyStream{stream: chatStream}
+	}
+
+	usageEvent := usage.NewLLMEvent("service", usage.PurposeResponse, o.Config.Model, pb.PromptTokens(), 0)
+	usageEvent.PromptTokensBySection = pb.TokenBreakdown()
+
+	return stream, *usageEvent, nil
+}
+
+// RenderPrompt builds the messages a response would be given, without calling the model
+func (o *OpenAILLM) RenderPrompt(transcript *transcript.Transcript, promptContents *promptbuilder.PromptContents, data promptbuilder.TemplateData) promptbuilder.RenderedPrompt {
+	pb, messages := o.buildPrompt(transcript, promptContents, data)
+
+	rendered := promptbuilder.RenderedPrompt{
+		Messages:              make([]promptbuilder.Message, len(messages)),
+		PromptTokensBySection: pb.TokenBreakdown(),
+	}
+	for i, message := range messages {
+		rendered.Messages[i] = promptbuilder.Message{Role: message.Role, Content: message.Content}
+	}
+	return rendered
+}
+
+// buildPrompt builds the system prompt and fits the transcript after it
+func (o *OpenAILLM) buildPrompt(transcript *transcript.Transcript, promptContents *promptbuilder.PromptContents, data promptbuilder.TemplateData) (*promptbuilder.PromptBuilder, []goOpenai.ChatCompletionMessage) {
+	pb := promptbuilder.NewPromptBuilder(transcript, promptContents, data).ForModel(o.Config.Model)
 
 	pb.AddBotPrimer()
 
-	if promptContents.Tools != nil {
-		pb.AddToolPrimer()
-		pb.AddTools()
+	if promptContents.Tools != nil || promptContents.BuiltinTools != nil {
+		if o.Config.FunctionCalling {
+			// The tools themselves are sent as functions
+			pb.AddFunctionToolPrimer()
+		} else {
+			pb.AddToolPrimer()
+			pb.AddTools()
+		}
 	}
 
 	if promptContents.Documents != nil {
@@ -48,6 +114,8 @@ func (o *OpenAILLM) GetTranscriptResponseStream(transcript *transcript.Transcrip
 		pb.AddTasks()
 	}
 
+	pb.AddSummary()
+
 	pb.AddTranscriptPrimer()
 
 	systemContent := pb.Build()
@@ -61,33 +129,24 @@ func (o *OpenAILLM) GetTranscriptResponseStream(transcript *transcript.Transcrip
 
 	messages = append(messages, systemMessage)
 
-	transcriptMessages, transcriptString := transcript.ToChatCompletionMessages()
-
-	// log.Print("Transcript: " + transcriptString)
-
-	messages = append(messages, transcriptMessages...)
-
-	c := o.client
-	ctx := context.Background()
-
-	req := goOpenai.ChatCompletionRequest{
-		Model:     o.Config.Model,
-		MaxTokens: 1000,
-		Messages:  messages,
-		Stream:    true,
+	if o.Config.FunctionCalling && len(promptContents.AllTools()) > 0 {
+		// Function definitions count towards the prompt tokens too, and have to be counted before the transcript is fitted
+		if definitions, err := json.Marshal(toolDefinitions(promptContents.AllTools())); err == nil {
+			pb.CountTokens(promptbuilder.SectionTools, string(definitions))
+		}
 	}
 
-	// Log prompt
-	// for _, message := range messages {
-	// 	log.Print("[" + message.Role + "] " + message.Content)
-	// }
+	transcriptMessages, _ := transcript.ToChatCompletionMessages()
 
-	stream, err := c.CreateChatCompletionStream(ctx, req)
-	if err != nil {
-		return nil, usage.LLMEvent{}, errors.New("ChatCompletionStream error: " + err.Error())
-	}
+	return pb, append(messages, pb.FitTranscript(transcriptMessages)...)
+}
 
-	usageEvent := usage.NewLLMEvent("service", o.Config.Model, tiktoken.TokenCount(systemContent+transcriptString, o.Config.Model), 0)
+// NativeToolCalls reports whether tool calls come as function calls in the stream rather than in its text
+func (o *OpenAILLM) NativeToolCalls() bool {
+	return o.Config.FunctionCalling
+}
 
-	return stream, *usageEvent, err
+// TokenCount counts the tokens of text for the configured model
+func (o *OpenAILLM) TokenCount(text string) int {
+	return tiktoken.TokenCount(text, o.Config.Model)
 }
@@ -0,0 +1,56 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"com.deablabs.teno-voice/internal/transcript"
+	"com.deablabs.teno-voice/internal/usage"
+	goOpenai "github.com/sashabaranov/go-openai"
+)
+
+const summaryPrompt = `You keep a running summary of a voice call that %s, an AI voice assistant, is taking part in.
+You are given the current summary, which may be empty, and the next lines of the call's transcript.
+Rewrite the summary so it also covers the new lines. Keep the facts, decisions, open questions, requests made to %s and what it did about them, and who said what when it matters.
+Leave out small talk. Write at most %d words, in plain prose, and answer with only the summary.`
+
+// Summarize folds transcript lines into a running summary of the call. The call's model is used if model is empty.
+func (o *OpenAILLM) Summarize(ctx context.Context, summary string, lines []transcript.Line, botName string, model string, maxWords int) (string, usage.LLMEvent, error) {
+	if model == "" {
+		model = o.Config.Model
+	}
+
+	var newLines strings.Builder
+	for _, line := range lines {
+		switch line.Type {
+		case "assistant":
+			fmt.Fprintf(&newLines, "%s: %s\n", botName, strings.TrimSpace(strings.TrimPrefix(line.Text, botName+": ")))
+		case "user":
+			fmt.Fprintf(&newLines, "%s: %s\n", line.Username, strings.TrimSpace(line.Text))
+		default:
+			fmt.Fprintf(&newLines, "%s\n", strings.TrimSpace(line.Text))
+		}
+	}
+
+	resp, err := o.client.CreateChatCompletion(ctx, goOpenai.ChatCompletionRequest{
+		Model: model,
+		// Words are usually less than two tokens each
+		MaxTokens: maxWords * 2,
+		Messages: []goOpenai.ChatCompletionMessage{
+			{Role: goOpenai.ChatMessageRoleSystem, Content: fmt.Sprintf(summaryPrompt, botName, botName, maxWords)},
+			{Role: goOpenai.ChatMessageRoleUser, Content: "Current summary:\n" + summary + "\n\nNew lines:\n" + newLines.String()},
+		},
+	})
+	if err != nil {
+		return "", usage.LLMEvent{}, err
+	}
+
+	usageEvent := usage.NewLLMEvent("service", usage.PurposeSummary, model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	if len(resp.Choices) == 0 {
+		return "", *usageEvent, errors.New("no choices in response")
+	}
+
+	return strings.TrimSpace(resp.Choices[0].Message.Content), *usageEvent, nil
+}
@@ -0,0 +1,228 @@
+package promptbuilder
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"com.deablabs.teno-voice/internal/llm/tiktoken"
+	goOpenai "github.com/sashabaranov/go-openai"
+)
+
+// Sections of the prompt, as reported in the token breakdown of LLM usage events
+const (
+	SectionPrimers    = "primers"
+	SectionTools      = "tools"
+	SectionDocuments  = "documents"
+	SectionSummary    = "summary"
+	SectionTranscript = "transcript"
+)
+
+// MaxResponseTokens is the longest a response can be. It is reserved out of the context when sizing the prompt.
+const MaxResponseTokens = 1000
+
+// TokenBudget sizes the prompt to fit the model's context. Each section gets a share of the context left once the
+// response is reserved. Documents are dropped from the lowest priority, the summary is cut to its share, and the oldest
+// transcript lines are dropped to fit its share. Primers and tools are never trimmed, so when they go over their share
+// it comes out of the transcript's.
+type TokenBudget struct {
+	// ContextTokens is the size of the model's context. It defaults to the known size for the model, or 4096.
+	// It must be more than MaxResponseTokens, which is reserved out of it.
+	ContextTokens int `validate:"omitempty,gt=1000"`
+	// Shares of the context for each section. They are scaled down if they add up to more than 1.
+	// If none are set, primers get 0.15, tools 0.15, documents 0.3, the summary 0.1 and the transcript 0.3.
+	Primers    float64 `validate:"min=0,max=1"`
+	Tools      float64 `validate:"min=0,max=1"`
+	Documents  float64 `validate:"min=0,max=1"`
+	Summary    float64 `validate:"min=0,max=1"`
+	Transcript float64 `validate:"min=0,max=1"`
+}
+
+var defaultTokenBudget = TokenBudget{
+	Primers:    0.15,
+	Tools:      0.15,
+	Documents:  0.3,
+	Summary:    0.1,
+	Transcript: 0.3,
+}
+
+// Context sizes of known models. Models not listed here match the longest name they start with.
+var modelContextTokens = map[string]int{
+	"gpt-3.5-turbo":      4096,
+	"gpt-3.5-turbo-16k":  16385,
+	"gpt-3.5-turbo-1106": 16385,
+	"gpt-4":              8192,
+	"gpt-4-32k":          32768,
+	"gpt-4-1106-preview": 128000,
+	"gpt-4-turbo":        128000,
+	"gpt-4o":             128000,
+}
+
+func contextTokens(model string) int {
+	if tokens, ok := modelContextTokens[model]; ok {
+		return tokens
+	}
+
+	tokens, longest := 4096, 0
+	for name, size := range modelContextTokens {
+		if strings.HasPrefix(model, name) && len(name) > longest {
+			tokens, longest = size, len(name)
+		}
+	}
+	return tokens
+}
+
+// ForModel counts the tokens of each section for the model, and sizes the prompt with the TokenBudget of the prompt contents if it has one
+func (pb *PromptBuilder) ForModel(model string) *PromptBuilder {
+	pb.model = model
+	pb.tokens = make(map[string]int)
+
+	budget := pb.promptContents.TokenBudget
+	if budget == nil {
+		return pb
+	}
+
+	plan := *budget
+	total := plan.Primers + plan.Tools + plan.Documents + plan.Summary + plan.Transcript
+	if total == 0 {
+		plan = defaultTokenBudget
+		total = 1
+	}
+	if plan.ContextTokens == 0 {
+		plan.ContextTokens = contextTokens(model)
+	}
+
+	scale := 1.0
+	if total > 1 {
+		scale = total
+	}
+
+	pb.available = plan.ContextTokens - MaxResponseTokens
+	pb.shares = map[string]float64{
+		SectionPrimers:    plan.Primers / scale,
+		SectionTools:      plan.Tools / scale,
+		SectionDocuments:  plan.Documents / scale,
+		SectionSummary:    plan.Summary / scale,
+		SectionTranscript: plan.Transcript / scale,
+	}
+	return pb
+}
+
+// CountTokens counts text towards a section without adding it to the prompt, like tools sent as native functions
+func (pb *PromptBuilder) CountTokens(section string, text string) {
+	if pb.tokens != nil {
+		pb.tokens[section] += tiktoken.TokenCount(text, pb.model)
+	}
+}
+
+// TokenBreakdown returns how many tokens each section of the prompt takes
+func (pb *PromptBuilder) TokenBreakdown() map[string]int {
+	return pb.tokens
+}
+
+// PromptTokens is the total of the token breakdown
+func (pb *PromptBuilder) PromptTokens() int {
+	total := 0
+	for _, tokens := range pb.tokens {
+		total += tokens
+	}
+	return total
+}
+
+// addSection adds text to the prompt, counting it towards a section
+func (pb *PromptBuilder) addSection(section string, text string) {
+	pb.sections = append(pb.sections, text)
+	pb.CountTokens(section, text)
+}
+
+func (pb *PromptBuilder) budgeted() bool {
+	return pb.shares != nil
+}
+
+// allocation is how many tokens a section can take
+func (pb *PromptBuilder) allocation(section string) int {
+	return int(float64(pb.available) * pb.shares[section])
+}
+
+// fitDocuments keeps the documents with the highest priority that fit in the documents' share, in their original order
+func (pb *PromptBuilder) fitDocuments(documents []Document) []Document {
+	order := make([]int, len(documents))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return documents[order[a]].Priority > documents[order[b]].Priority
+	})
+
+	allocation := pb.allocation(SectionDocuments) - pb.tokens[SectionDocuments]
+	keep := make([]bool, len(documents))
+	var dropped []string
+	for _, i := range order {
+		documentJson, err := json.Marshal(documents[i])
+		if err != nil {
+			continue
+		}
+
+		tokens := tiktoken.TokenCount(string(documentJson), pb.model)
+		if tokens > allocation {
+			dropped = append(dropped, documents[i].Name)
+			continue
+		}
+		allocation -= tokens
+		keep[i] = true
+	}
+
+	if len(dropped) > 0 {
+		fmt.Printf("Documents left out of the prompt to fit the token budget: %s\n", strings.Join(dropped, ", "))
+	}
+
+	fitted := make([]Document, 0, len(documents))
+	for i, document := range documents {
+		if keep[i] {
+			fitted = append(fitted, document)
+		}
+	}
+	return fitted
+}
+
+// FitTranscript drops the oldest transcript messages that don't fit in the transcript's share, or in what is left of the
+// context if that is less, and counts the rest towards the transcript section. The latest message is always kept, cut to
+// fit if needed.
+func (pb *PromptBuilder) FitTranscript(messages []goOpenai.ChatCompletionMessage) []goOpenai.ChatCompletionMessage {
+	if pb.tokens == nil {
+		return messages
+	}
+
+	counts := make([]int, len(messages))
+	total := 0
+	for i, message := range messages {
+		counts[i] = tiktoken.TokenCount(message.Role+": "+message.Content, pb.model)
+		total += counts[i]
+	}
+
+	if pb.budgeted() {
+		budget := pb.available - pb.PromptTokens()
+		if allocation := pb.allocation(SectionTranscript); allocation < budget {
+			budget = allocation
+		}
+
+		start := 0
+		for start < len(messages)-1 && total > budget {
+			total -= counts[start]
+			start++
+		}
+		messages = messages[start:]
+
+		if len(messages) == 1 && total > budget && budget > 0 {
+			content, err := tiktoken.TruncateString(messages[0].Content, budget, pb.model)
+			if err == nil {
+				messages = []goOpenai.ChatCompletionMessage{{Role: messages[0].Role, Content: content}}
+				total = tiktoken.TokenCount(messages[0].Role+": "+content, pb.model)
+			}
+		}
+	}
+
+	pb.tokens[SectionTranscript] += total
+	return messages
+}
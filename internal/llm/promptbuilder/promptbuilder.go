@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
+	"com.deablabs.teno-voice/internal/llm/tiktoken"
 	"com.deablabs.teno-voice/internal/responder/tools"
+	"com.deablabs.teno-voice/internal/retrieval"
 	"com.deablabs.teno-voice/internal/transcript"
 )
 
@@ -15,39 +18,108 @@ type PromptContents struct {
 	CustomToolPrimer       string
 	CustomDocumentPrimer   string
 	CustomTaskPrimer       string
-	Tools                  []tools.Tool
-	Documents              []Document
-	Tasks                  []Task
+	CustomSummaryPrimer    string
+	// Template replaces the default layout of the system prompt. Like the primers, it is a Go template that can use
+	// the TemplateData, including the default Sections.
+	Template string
+	// Timezone is the IANA name of the time zone templates see the time in, like "Europe/London". UTC by default.
+	Timezone string
+	// RepairToolCalls tells the model why a tool call was rejected and lets it try once more
+	RepairToolCalls bool
+	Tools           []tools.Tool `validate:"dive"`
+	// BuiltinTools enables tools the bot executes itself, like leaving the call
+	BuiltinTools *tools.BuiltinToolsConfig
+	Documents    []Document
+	// Retrieval adds only the chunks of the documents most relevant to the conversation to the prompt, instead of every document
+	Retrieval *retrieval.Config
+	Tasks     []Task `validate:"dive"`
+	// TokenBudget sizes the prompt to fit the model's context. Without it, the prompt isn't limited.
+	TokenBudget *TokenBudget
+}
+
+// AllTools returns the tools the bot can use: the enabled built-in tools, then the custom tools
+func (p *PromptContents) AllTools() []tools.Tool {
+	return append(p.BuiltinTools.Tools(), p.Tools...)
 }
 
 type PromptBuilder struct {
-	botName        string
+	data           TemplateData
+	transcript     *transcript.Transcript
 	promptContents *PromptContents
 	sections       []string
+	// model, tokens, available and shares are set by ForModel
+	model     string
+	tokens    map[string]int
+	available int
+	shares    map[string]float64
 }
 
 type Document struct {
 	Name    string `validate:"required"`
 	Content string `validate:"required"`
+	// Priority decides which documents are kept first when they don't all fit in the token budget, higher first
+	Priority int `json:",omitempty"`
 }
 
+// Statuses of a task
+const (
+	TaskPending    = "pending"
+	TaskInProgress = "in-progress"
+	TaskDone       = "done"
+	TaskFailed     = "failed"
+)
+
 type Task struct {
+	// ID identifies the task in tool calls and task events. It defaults to the Name.
+	ID               string `json:",omitempty"`
 	Name             string `validate:"required"`
 	Description      string `validate:"required"`
 	DeliverableGuide string `validate:"required"`
+	// Status is pending by default. Setting it in a config update changes the task's status, like when it was done
+	// outside the call. Done and failed tasks are left out of the prompt.
+	Status string `json:",omitempty" validate:"omitempty,oneof=pending in-progress done failed"`
+	// Due is when the task should be done by. A task event is sent when it passes and the task isn't done.
+	Due *time.Time `json:",omitempty"`
+	// ReminderInterval is how many seconds of silence pass before the bot is reminded of the task again.
+	// It defaults to VoiceUXConfig.AutoRespondInterval.
+	ReminderInterval int `json:",omitempty" validate:"min=0"`
+}
+
+// TaskID returns the ID of the task, or its Name if it has none
+func (t Task) TaskID() string {
+	if t.ID == "" {
+		return t.Name
+	}
+	return t.ID
 }
 
 var defaultTranscriptPrimer = "Below is the transcript of the voice channel, up to the current moment. It may include transcription errors or dropped words (especially at the beginnings of lines), if you think a transcription was incorrect, infer the true words from context. The first sentence of your response should be as short as possible within reason. The transcript may also include information like your previous tool uses, and mark when others interrupted you to stop your words from playing (which may mean they want you to stop talking). If the last person to speak doesn't expect or want a response from you, or they are explicitly asking you to stop speaking, your response should only be the single character '^' with no spaces."
 
-var defaultToolPrimer = "Below is a list of available tools you can use. These are your tools, and they aren't visible to anyone else in the voice channel. Each tool has four attributes: `Name`: the tool's identifier, `Description`: explains the tool's purpose and when to use it, `Input Guide`: advises on how to format the input string, `Output Guide`: describes the tool's return value, if any. To use a tool, you will append a tool message at the end of your normal spoken response, separated by a pipe ('|'). The spoken response is a string of text to be read aloud via TTS. You don't need to write a spoken response to use a tool, your response can simply be a | and then a tool command, in which case your tool command will be processed without any speech playing in the voice channel. Write all tool commands in the form of a JSON array. Each array element is a JSON object representing a tool command, with two properties: `name` and `input`. You shouldn't explain to the other voice call members how you use the tools unless someone asks. Here's an example of a response that uses a tool:\n\nSure thing, I will send a message to the general channel. |[{ \"name\": \"SendMessageToGeneralChannel\", \"input\": \"Hello!\" }]\n\nRemember to write a '|' before writing your tool message. Review the `description`, `input guide`, and `output guide` of each tool carefully to use them effectively."
+var defaultToolPrimer = "Below is a list of available tools you can use. These are your tools, and they aren't visible to anyone else in the voice channel. Each tool has four attributes: `Name`: the tool's identifier, `Description`: explains the tool's purpose and when to use it, `Input Guide`: advises on how to format the input string, `Output Guide`: describes the tool's return value, if any. To use a tool, you will append a tool message at the end of your normal spoken response, separated by a pipe ('|'). The spoken response is a string of text to be read aloud via TTS. You don't need to write a spoken response to use a tool, your response can simply be a | and then a tool command, in which case your tool command will be processed without any speech playing in the voice channel. Write all tool commands in the form of a JSON array. Each array element is a JSON object representing a tool command, with two properties: `name` and `input`. You shouldn't explain to the other voice call members how you use the tools unless someone asks. Here's an example of a response that uses a tool:\n\nSure thing, I will send a message to the general channel. |[{ \"name\": \"SendMessageToGeneralChannel\", \"input\": \"Hello!\" }]\n\nRemember to write a '|' before writing your tool message. If a tool has an `inputSchema`, its input must be a JSON value matching that JSON Schema instead of a string. Review the `description`, `input guide`, and `output guide` of each tool carefully to use them effectively."
+
+var defaultFunctionToolPrimer = "You have tools available as functions. These are your tools, and they aren't visible to anyone else in the voice channel. Your spoken response is read aloud via TTS, and you can call tools alongside it or without saying anything, in which case the tool call is processed without any speech playing in the voice channel. Never read tool calls or their inputs aloud, and you shouldn't explain to the other voice call members how you use the tools unless someone asks. Review the description of each tool carefully to use them effectively."
+
+var defaultTaskPrimer = "Below is a list of pending tasks. Each task is represented by its `ID`, `Name`, `Description`, `DeliverableGuide` and `Status`, and may have a `Due` time it should be done by. The `Description` details the task at hand, and the `DeliverableGuide` how to complete the task, whether its the use of a specific tool and/or relaying particular information to someone in the call. These are your tasks, but you may need to ask people in the call for information to complete them. Always take your pending tasks into account when responding, and make every effort to complete them. If the last line of the transcript is telling you to complete pending tasks, attempt to complete them, or mark them done using the associated tools if they are already complete. Do not talk about your tasks in the voice call unless people explicitly ask about them. If you are completing a task, you can simply write the tool message, you don't need to mention it in the voice channel."
 
-var defaultTaskPrimer = "Below is a list of pending tasks. Each task is represented by its `Name`, `Description`, and `DeliverableGuide`. The `Description` details the task at hand, and the `DeliverableGuide` how to complete the task, whether its the use of a specific tool and/or relaying particular information to someone in the call. These are your tasks, but you may need to ask people in the call for information to complete them. Always take your pending tasks into account when responding, and make every effort to complete them. If the last line of the transcript is telling you to complete pending tasks, attempt to complete them, or mark them done using the associated tools if they are already complete. Do not talk about your tasks in the voice call unless people explicitly ask about them. If you are completing a task, you can simply write the tool message, you don't need to mention it in the voice channel."
+var defaultSummaryPrimer = "Below is a summary of the earlier part of the call, which is no longer in the transcript. Use it to remember what was said and decided before."
 
 var defaultDocumentPrimer = "Below is a list of documents for you to reference when responding in the voice channel."
 
-func NewPromptBuilder(botName string, transcript *transcript.Transcript, promptContents *PromptContents) *PromptBuilder {
+// NewPromptBuilder starts a prompt. The tasks, tools, documents and summary of the template data are filled in from the
+// prompt contents and the transcript, and its time is put in the prompt contents' Timezone.
+func NewPromptBuilder(transcript *transcript.Transcript, promptContents *PromptContents, data TemplateData) *PromptBuilder {
+	if location, err := time.LoadLocation(promptContents.Timezone); err == nil {
+		data.Now = data.Now.In(location)
+		data.Timezone = location.String()
+	}
+	data.Tasks = promptContents.Tasks
+	data.Tools = promptContents.AllTools()
+	data.Documents = promptContents.Documents
+	data.Summary = transcript.Summary().Text
+
 	return &PromptBuilder{
-		botName:        botName,
+		data:           data,
+		transcript:     transcript,
 		promptContents: promptContents,
 		sections:       make([]string, 0, 10),
 	}
@@ -55,60 +127,79 @@ func NewPromptBuilder(botName string, transcript *transcript.Transcript, promptC
 
 // AddBotPrimer adds the bot primer section to the prompt
 func (pb *PromptBuilder) AddBotPrimer() *PromptBuilder {
-	pb.sections = append(pb.sections, pb.promptContents.BotPrimer)
+	primer := pb.render("BotPrimer", pb.promptContents.BotPrimer)
+	pb.addSection(SectionPrimers, primer)
+	addPart(&pb.data.Sections.BotPrimer, primer)
 	return pb
 }
 
 // AddTranscriptPrimer adds the transcript primer section to the prompt
 func (pb *PromptBuilder) AddTranscriptPrimer() *PromptBuilder {
-	if pb.promptContents.CustomTranscriptPrimer == "" {
-		pb.sections = append(pb.sections, defaultTranscriptPrimer)
-	} else {
-		pb.sections = append(pb.sections, pb.promptContents.CustomTranscriptPrimer)
+	primer := defaultTranscriptPrimer
+	if pb.promptContents.CustomTranscriptPrimer != "" {
+		primer = pb.render("CustomTranscriptPrimer", pb.promptContents.CustomTranscriptPrimer)
 	}
+	pb.addSection(SectionPrimers, primer)
+	addPart(&pb.data.Sections.Transcript, primer)
 
 	silenceInstruction := "If you don't want to say anything, respond with the single character '^'."
-	pb.sections = append(pb.sections, silenceInstruction)
+	pb.addSection(SectionPrimers, silenceInstruction)
+	addPart(&pb.data.Sections.Transcript, silenceInstruction)
 	return pb
 }
 
 // AddToolPrimer adds the tool primer section to the prompt
 func (pb *PromptBuilder) AddToolPrimer() *PromptBuilder {
-	if pb.promptContents.CustomToolPrimer == "" {
-		pb.sections = append(pb.sections, defaultToolPrimer)
-	} else {
-		pb.sections = append(pb.sections, pb.promptContents.CustomToolPrimer)
+	primer := defaultToolPrimer
+	if pb.promptContents.CustomToolPrimer != "" {
+		primer = pb.render("CustomToolPrimer", pb.promptContents.CustomToolPrimer)
 	}
+	pb.addSection(SectionPrimers, primer)
+	addPart(&pb.data.Sections.Tools, primer)
+	return pb
+}
+
+// AddFunctionToolPrimer adds the tool primer section for tools given to the model as native functions
+func (pb *PromptBuilder) AddFunctionToolPrimer() *PromptBuilder {
+	primer := defaultFunctionToolPrimer
+	if pb.promptContents.CustomToolPrimer != "" {
+		primer = pb.render("CustomToolPrimer", pb.promptContents.CustomToolPrimer)
+	}
+	pb.addSection(SectionPrimers, primer)
+	addPart(&pb.data.Sections.Tools, primer)
 	return pb
 }
 
 // AddDocumentPrimer adds the document primer section to the prompt
 func (pb *PromptBuilder) AddDocumentPrimer() *PromptBuilder {
-	if pb.promptContents.CustomDocumentPrimer == "" {
-		pb.sections = append(pb.sections, defaultDocumentPrimer)
-	} else {
-		pb.sections = append(pb.sections, pb.promptContents.CustomDocumentPrimer)
+	primer := defaultDocumentPrimer
+	if pb.promptContents.CustomDocumentPrimer != "" {
+		primer = pb.render("CustomDocumentPrimer", pb.promptContents.CustomDocumentPrimer)
 	}
+	pb.addSection(SectionPrimers, primer)
+	addPart(&pb.data.Sections.Documents, primer)
 	return pb
 }
 
 // AddTaskPrimer adds the task primer section to the prompt
 func (pb *PromptBuilder) AddTaskPrimer() *PromptBuilder {
-	if pb.promptContents.CustomTaskPrimer == "" {
-		pb.sections = append(pb.sections, defaultTaskPrimer)
-	} else {
-		pb.sections = append(pb.sections, pb.promptContents.CustomTaskPrimer)
+	primer := defaultTaskPrimer
+	if pb.promptContents.CustomTaskPrimer != "" {
+		primer = pb.render("CustomTaskPrimer", pb.promptContents.CustomTaskPrimer)
 	}
+	pb.addSection(SectionPrimers, primer)
+	addPart(&pb.data.Sections.Tasks, primer)
 	return pb
 }
 
 // AddTools adds the tool primer and tool list sections to the prompt
 func (pb *PromptBuilder) AddTools() *PromptBuilder {
 	var toolsString string
-	if len(pb.promptContents.Tools) == 0 {
+	availableTools := pb.promptContents.AllTools()
+	if len(availableTools) == 0 {
 		toolsString = "[No tools available]"
 	} else {
-		toolsJson, err := json.Marshal(pb.promptContents.Tools)
+		toolsJson, err := json.Marshal(availableTools)
 
 		if err != nil {
 			fmt.Printf("Error marshalling tools: %s", err)
@@ -120,17 +211,23 @@ func (pb *PromptBuilder) AddTools() *PromptBuilder {
 
 	tools := "Tools:\n" + toolsString + "\n"
 
-	pb.sections = append(pb.sections, tools)
+	pb.addSection(SectionTools, tools)
+	addPart(&pb.data.Sections.Tools, tools)
 	return pb
 }
 
 // AddDocs adds the documents section to the prompt
 func (pb *PromptBuilder) AddDocs() *PromptBuilder {
+	documents := pb.promptContents.Documents
+	if pb.budgeted() {
+		documents = pb.fitDocuments(documents)
+	}
+
 	var docString string
-	if len(pb.promptContents.Documents) == 0 {
+	if len(documents) == 0 {
 		docString = "[No documents available]"
 	} else {
-		docJson, err := json.Marshal(pb.promptContents.Documents)
+		docJson, err := json.Marshal(documents)
 		if err != nil {
 			fmt.Printf("Error marshalling documents: %s", err)
 			docString = "[No documents available]"
@@ -138,8 +235,9 @@ func (pb *PromptBuilder) AddDocs() *PromptBuilder {
 			docString = string(docJson)
 		}
 	}
-	documents := fmt.Sprintf("\n\nDocuments:\n%s", docString)
-	pb.sections = append(pb.sections, documents)
+	docs := fmt.Sprintf("\n\nDocuments:\n%s", docString)
+	pb.addSection(SectionDocuments, docs)
+	addPart(&pb.data.Sections.Documents, docs)
 	return pb
 }
 
@@ -160,11 +258,60 @@ func (pb *PromptBuilder) AddTasks() *PromptBuilder {
 
 	tasks := "Tasks:\n" + tasksString + "\n"
 
-	pb.sections = append(pb.sections, tasks)
+	pb.addSection(SectionPrimers, tasks)
+	addPart(&pb.data.Sections.Tasks, tasks)
 	return pb
 }
 
-// Build concatenates all sections and returns the final prompt
+// AddSummary adds the rolling summary of the call to the prompt, if there is one yet
+func (pb *PromptBuilder) AddSummary() *PromptBuilder {
+	summary := pb.transcript.Summary().Text
+	if summary == "" {
+		return pb
+	}
+
+	primer := defaultSummaryPrimer
+	if pb.promptContents.CustomSummaryPrimer != "" {
+		primer = pb.render("CustomSummaryPrimer", pb.promptContents.CustomSummaryPrimer)
+	}
+	pb.addSection(SectionPrimers, primer)
+	addPart(&pb.data.Sections.Summary, primer)
+	if pb.budgeted() {
+		// The end of the summary covers the most recent part of the call
+		if truncated, err := tiktoken.TruncateString(summary, pb.allocation(SectionSummary), pb.model); err == nil {
+			summary = truncated
+		}
+	}
+	pb.addSection(SectionSummary, "Summary:\n"+summary+"\n")
+	addPart(&pb.data.Sections.Summary, "Summary:\n"+summary+"\n")
+	return pb
+}
+
+// Build concatenates all sections and returns the final prompt, or renders the Template of the prompt contents if it has one
 func (pb *PromptBuilder) Build() string {
-	return strings.Join(pb.sections, "\n\n")
+	if pb.promptContents.Template == "" {
+		return strings.Join(pb.sections, "\n\n")
+	}
+
+	prompt := pb.render("Template", pb.promptContents.Template)
+	if pb.tokens != nil {
+		// Sections the template leaves out don't count, and whatever else it writes counts as primers
+		parts := map[string]string{
+			SectionTools:     pb.data.Sections.Tools,
+			SectionDocuments: pb.data.Sections.Documents,
+			SectionSummary:   pb.data.Sections.Summary,
+		}
+		primers := tiktoken.TokenCount(prompt, pb.model)
+		for section, part := range parts {
+			if !strings.Contains(prompt, part) {
+				delete(pb.tokens, section)
+			}
+			primers -= pb.tokens[section]
+		}
+		if primers < 0 {
+			primers = 0
+		}
+		pb.tokens[SectionPrimers] = primers
+	}
+	return prompt
 }
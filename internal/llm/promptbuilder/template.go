@@ -0,0 +1,156 @@
+package promptbuilder
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"com.deablabs.teno-voice/internal/responder/tools"
+)
+
+// TemplateData is what prompt templates can use. The BotPrimer, the custom primers and the Template of the prompt
+// contents are Go templates (text/template), so they can write things like {{.BotName}} or {{.Now.Format "15:04"}}.
+type TemplateData struct {
+	BotName string
+	// Now is the current time in the prompt contents' Timezone
+	Now      time.Time
+	Timezone string
+	// CallDuration is how long the call has been going, to the second
+	CallDuration time.Duration
+	Participants []Participant
+	// Awake is false while the bot is asleep in the AutoSleep speaking mode
+	Awake     bool
+	Tasks     []Task
+	Tools     []tools.Tool
+	Documents []Document
+	// Summary is the rolling summary of the call, empty if there is none yet
+	Summary string
+	// Sections are the default sections of the prompt, for a Template that only changes their order or what surrounds them
+	Sections Sections
+}
+
+// Participant is someone in the call's voice channel
+type Participant struct {
+	UserID      string
+	Username    string
+	DisplayName string
+	Bot         bool
+}
+
+// Sections are the parts of the default prompt, each with its primer. Parts that aren't in the prompt are empty.
+type Sections struct {
+	BotPrimer  string
+	Tools      string
+	Documents  string
+	Tasks      string
+	Summary    string
+	Transcript string
+}
+
+// RenderedPrompt is the prompt as it would be sent to the LLM. Tools sent as native functions aren't included.
+type RenderedPrompt struct {
+	Messages              []Message
+	PromptTokensBySection map[string]int
+}
+
+type Message struct {
+	Role    string
+	Content string
+}
+
+// Functions templates can use besides the built-in ones
+var templateFuncs = template.FuncMap{
+	// json writes a value as JSON, like {{json .Tasks}}
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	// join joins strings, like {{join .Names ", "}}
+	"join": strings.Join,
+}
+
+func parseTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// templates returns the fields of the prompt contents that are templates, by field name
+func (p *PromptContents) templates() map[string]string {
+	return map[string]string{
+		"BotPrimer":              p.BotPrimer,
+		"CustomTranscriptPrimer": p.CustomTranscriptPrimer,
+		"CustomToolPrimer":       p.CustomToolPrimer,
+		"CustomDocumentPrimer":   p.CustomDocumentPrimer,
+		"CustomTaskPrimer":       p.CustomTaskPrimer,
+		"CustomSummaryPrimer":    p.CustomSummaryPrimer,
+		"Template":               p.Template,
+	}
+}
+
+// Validate checks what struct validation can't: that task IDs are unique, that the Timezone exists, and that every
+// template parses and renders, so mistakes like unknown fields are reported when the config is set instead of when the bot responds
+func (p *PromptContents) Validate() error {
+	ids := make(map[string]bool, len(p.Tasks))
+	for _, task := range p.Tasks {
+		if ids[task.TaskID()] {
+			return fmt.Errorf("duplicate task ID in PromptContents.Tasks: %s", task.TaskID())
+		}
+		ids[task.TaskID()] = true
+	}
+
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return fmt.Errorf("invalid PromptContents.Timezone: %w", err)
+	}
+
+	sample := TemplateData{
+		BotName:      "Teno",
+		Now:          time.Now(),
+		Timezone:     p.Timezone,
+		CallDuration: 5 * time.Minute,
+		Participants: []Participant{{UserID: "1", Username: "someone", DisplayName: "Someone"}},
+		Awake:        true,
+		Tasks:        p.Tasks,
+		Tools:        p.AllTools(),
+		Documents:    p.Documents,
+		Summary:      "The call so far.",
+	}
+
+	for name, text := range p.templates() {
+		tmpl, err := parseTemplate(name, text)
+		if err != nil {
+			return fmt.Errorf("invalid PromptContents.%s template: %w", name, err)
+		}
+		if err := tmpl.Execute(&strings.Builder{}, sample); err != nil {
+			return fmt.Errorf("invalid PromptContents.%s template: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// render executes a template of the prompt contents. Templates are validated with the config,
+// so if one still fails it is used as it is.
+func (pb *PromptBuilder) render(name string, text string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+
+	var rendered strings.Builder
+	tmpl, err := parseTemplate(name, text)
+	if err == nil {
+		err = tmpl.Execute(&rendered, pb.data)
+	}
+	if err != nil {
+		fmt.Printf("Error rendering %s template: %v\n", name, err)
+		return text
+	}
+	return rendered.String()
+}
+
+// addPart adds text to one of the default sections, for the Template
+func addPart(part *string, text string) {
+	if *part != "" {
+		*part += "\n\n"
+	}
+	*part += text
+}
@@ -0,0 +1,430 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"com.deablabs.teno-voice/internal/calls"
+	"com.deablabs.teno-voice/internal/discord"
+	"com.deablabs.teno-voice/internal/llm/promptbuilder"
+	"com.deablabs.teno-voice/internal/responder"
+	"com.deablabs.teno-voice/internal/responder/tools"
+	"com.deablabs.teno-voice/internal/transcript"
+	"com.deablabs.teno-voice/internal/usage"
+	"com.deablabs.teno-voice/pkg/helpers"
+)
+
+// eventPayloads maps the Type of each message on the tool-messages stream to the JSON encoded in its Data field.
+// A nil payload means Data is a plain string.
+var eventPayloads = map[string]interface{}{
+	"tool-message":          []tools.ToolMessage{},
+	"tool-rejected":         tools.ToolRejection{},
+	"builtin-tool":          tools.BuiltinToolExecution{},
+	"confirmation-pending":  responder.PendingConfirmation{},
+	"confirmation-resolved": responder.ConfirmationResolution{},
+	"state":                 nil,
+	"call-ended":            calls.CallEndedEvent{},
+	"presence":              discord.PresenceEvent{},
+	"moved":                 calls.MovedEvent{},
+	"responder-state":       responder.StateChange{},
+	"wake":                  responder.WakeEvent{},
+	"summary":               transcript.Summary{},
+	"retrieval":             responder.RetrievalEvent{},
+	"task":                  responder.TaskUpdate{},
+	"job":                   responder.JobUpdate{},
+}
+
+// Spec builds the OpenAPI document for the REST API from the types the handlers decode and encode
+func Spec() Object {
+	g := newSchemaGenerator()
+
+	errorResponse := func(description string) Object {
+		return Object{
+			"description": description,
+			"content": Object{
+				"application/json": Object{"schema": g.Ref(helpers.ErrorResponse{})},
+			},
+		}
+	}
+	textResponse := func(description string) Object {
+		return Object{
+			"description": description,
+			"content": Object{
+				"text/plain": Object{"schema": Object{"type": "string"}},
+			},
+		}
+	}
+	streamResponse := func(description string, schema Object) Object {
+		return Object{
+			"description": description,
+			"content": Object{
+				"text/event-stream": Object{"schema": schema},
+			},
+		}
+	}
+	callParameters := []Object{
+		{"name": "bot_id", "in": "path", "required": true, "schema": Object{"type": "string"}, "description": "Discord ID of the bot user"},
+		{"name": "guild_id", "in": "path", "required": true, "schema": Object{"type": "string"}, "description": "Discord ID of the guild the call is in"},
+	}
+	commonErrors := func(responses Object) Object {
+		responses["401"] = errorResponse("Missing or invalid API key")
+		responses["404"] = errorResponse("The bot is not in a call in this guild")
+		return responses
+	}
+
+	// Register the payload of every event type and describe them on the tool-messages stream
+	eventTypes := make([]string, 0, len(eventPayloads))
+	for eventType := range eventPayloads {
+		eventTypes = append(eventTypes, eventType)
+	}
+	sort.Strings(eventTypes)
+
+	var eventDescriptions strings.Builder
+	eventDescriptions.WriteString("Each event is an SSEMessage whose Data field depends on its Type:\n")
+	for _, eventType := range eventTypes {
+		payload := eventPayloads[eventType]
+		if payload == nil {
+			fmt.Fprintf(&eventDescriptions, "- `%s`: a plain string\n", eventType)
+			continue
+		}
+		ref := g.Ref(payload)
+		if items, ok := ref["items"].(Object); ok {
+			ref = items
+			fmt.Fprintf(&eventDescriptions, "- `%s`: a JSON encoded array of %s\n", eventType, ref["$ref"])
+		} else {
+			fmt.Fprintf(&eventDescriptions, "- `%s`: a JSON encoded %s\n", eventType, ref["$ref"])
+		}
+	}
+
+	actionResponses := func(description string) Object {
+		return commonErrors(Object{"200": Object{"description": description}})
+	}
+
+	paths := Object{
+		"/calls": Object{
+			"get": Object{
+				"summary":     "List the ongoing calls",
+				"operationId": "listCalls",
+				"responses": Object{
+					"200": Object{
+						"description": "The calls",
+						"content": Object{
+							"application/json": Object{"schema": g.Ref([]calls.CallInfo{})},
+						},
+					},
+					"401": errorResponse("Missing or invalid API key"),
+				},
+			},
+		},
+		"/join": Object{
+			"post": Object{
+				"summary":     "Join a voice channel",
+				"operationId": "join",
+				"requestBody": Object{
+					"required": true,
+					"content":  Object{"application/json": Object{"schema": g.Ref(calls.JoinRequest{})}},
+				},
+				"responses": Object{
+					"200": textResponse("Joined the voice channel"),
+					"400": errorResponse("The request body is invalid"),
+					"401": errorResponse("Missing or invalid API key"),
+					"502": errorResponse("Discord refused the voice connection"),
+				},
+			},
+		},
+		"/{bot_id}/{guild_id}/leave": Object{
+			"post": Object{
+				"summary":     "Leave the voice channel",
+				"operationId": "leave",
+				"parameters":  callParameters,
+				"responses": Object{
+					"200": textResponse("Left the call, or the bot was not in a call"),
+					"401": errorResponse("Missing or invalid API key"),
+				},
+			},
+		},
+		"/{bot_id}/{guild_id}/config": Object{
+			"post": Object{
+				"summary":     "Update the config of an ongoing call",
+				"description": "Only the sections present in the body are replaced.",
+				"operationId": "updateConfig",
+				"parameters":  callParameters,
+				"requestBody": Object{
+					"required": true,
+					"content":  Object{"application/json": Object{"schema": g.Partial("ConfigUpdate", calls.Config{})}},
+				},
+				"responses": commonErrors(Object{
+					"200": Object{"description": "The config was updated"},
+					"400": errorResponse("The request body is invalid"),
+				}),
+			},
+		},
+		"/{bot_id}/{guild_id}/say": Object{
+			"post": Object{
+				"summary":     "Speak the given text, interrupting the current response",
+				"operationId": "say",
+				"parameters":  callParameters,
+				"requestBody": Object{
+					"required": true,
+					"content":  Object{"application/json": Object{"schema": g.Ref(calls.SayRequest{})}},
+				},
+				"responses": actionResponses("The text is being spoken"),
+			},
+		},
+		"/{bot_id}/{guild_id}/respond": Object{
+			"post": Object{
+				"summary":     "Respond to the transcript now, unless the bot is already responding or can't speak",
+				"operationId": "respond",
+				"parameters":  callParameters,
+				"responses":   actionResponses("The response was requested"),
+			},
+		},
+		"/{bot_id}/{guild_id}/interrupt": Object{
+			"post": Object{
+				"summary":     "Stop the current response",
+				"operationId": "interrupt",
+				"parameters":  callParameters,
+				"responses":   actionResponses("The response was stopped"),
+			},
+		},
+		"/{bot_id}/{guild_id}/confirmations": Object{
+			"get": Object{
+				"summary":     "List the tool invocations waiting to be confirmed",
+				"operationId": "confirmations",
+				"parameters":  callParameters,
+				"responses": commonErrors(Object{
+					"200": Object{
+						"description": "The pending confirmations",
+						"content": Object{
+							"application/json": Object{"schema": g.Ref([]responder.PendingConfirmation{})},
+						},
+					},
+				}),
+			},
+		},
+		"/{bot_id}/{guild_id}/confirmations/{confirmation_id}": Object{
+			"post": Object{
+				"summary":     "Confirm or decline a tool invocation waiting to be confirmed",
+				"operationId": "resolveConfirmation",
+				"parameters": append(append([]Object{}, callParameters...), Object{
+					"name": "confirmation_id", "in": "path", "required": true, "schema": Object{"type": "string"}, "description": "ID of the pending confirmation",
+				}),
+				"requestBody": Object{
+					"required": true,
+					"content":  Object{"application/json": Object{"schema": g.Ref(calls.ResolveConfirmationRequest{})}},
+				},
+				"responses": commonErrors(Object{
+					"200": Object{"description": "The confirmation was resolved"},
+					"400": errorResponse("The request body is invalid"),
+					"404": errorResponse("The bot is not in a call in this guild, or the confirmation was not found"),
+				}),
+			},
+		},
+		"/{bot_id}/{guild_id}/summary": Object{
+			"get": Object{
+				"summary":     "Get the rolling summary of the part of the call that is no longer in the transcript",
+				"description": "The summary is empty unless the TranscriptConfig has a Summary section.",
+				"operationId": "summary",
+				"parameters":  callParameters,
+				"responses": commonErrors(Object{
+					"200": Object{
+						"description": "The rolling summary",
+						"content": Object{
+							"application/json": Object{"schema": g.Ref(transcript.Summary{})},
+						},
+					},
+				}),
+			},
+		},
+		"/{bot_id}/{guild_id}/tasks": Object{
+			"get": Object{
+				"summary":     "List the call's tasks with their statuses",
+				"description": "Task changes are also sent on the tool-messages stream as task events.",
+				"operationId": "tasks",
+				"parameters":  callParameters,
+				"responses": commonErrors(Object{
+					"200": Object{
+						"description": "The tasks",
+						"content": Object{
+							"application/json": Object{"schema": g.Ref([]promptbuilder.Task{})},
+						},
+					},
+				}),
+			},
+		},
+		"/{bot_id}/{guild_id}/jobs": Object{
+			"get": Object{
+				"summary":     "List the jobs scheduled in the call, the next to run first",
+				"operationId": "jobs",
+				"parameters":  callParameters,
+				"responses": commonErrors(Object{
+					"200": Object{
+						"description": "The scheduled jobs",
+						"content": Object{
+							"application/json": Object{"schema": g.Ref([]responder.Job{})},
+						},
+					},
+				}),
+			},
+			"post": Object{
+				"summary":     "Schedule a job that says fixed text or gives the bot an instruction, once or repeatedly",
+				"description": "A job runs once At a time or In some seconds, or Every some seconds. It needs either Say or Instruction. Jobs are also sent on the tool-messages stream as job events.",
+				"operationId": "scheduleJob",
+				"parameters":  callParameters,
+				"requestBody": Object{
+					"required": true,
+					"content":  Object{"application/json": Object{"schema": g.Ref(responder.JobSpec{})}},
+				},
+				"responses": commonErrors(Object{
+					"200": Object{
+						"description": "The scheduled job",
+						"content": Object{
+							"application/json": Object{"schema": g.Ref(responder.Job{})},
+						},
+					},
+					"400": errorResponse("The job is invalid, or the call has too many jobs scheduled"),
+				}),
+			},
+		},
+		"/{bot_id}/{guild_id}/jobs/{job_id}": Object{
+			"delete": Object{
+				"summary":     "Cancel a scheduled job",
+				"operationId": "cancelJob",
+				"parameters": append(append([]Object{}, callParameters...), Object{
+					"name": "job_id", "in": "path", "required": true, "schema": Object{"type": "string"}, "description": "ID of the job",
+				}),
+				"responses": commonErrors(Object{
+					"200": Object{"description": "The job was cancelled"},
+					"404": errorResponse("The bot is not in a call in this guild, or the job was not found"),
+				}),
+			},
+		},
+		"/{bot_id}/{guild_id}/prompt": Object{
+			"get": Object{
+				"summary":     "Render the prompt the bot would respond to now, without calling the LLM",
+				"description": "Templates are rendered with the current time, participants and state, and retrieval and the token budget are applied.",
+				"operationId": "prompt",
+				"parameters":  callParameters,
+				"responses": commonErrors(Object{
+					"200": Object{
+						"description": "The rendered prompt",
+						"content": Object{
+							"application/json": Object{"schema": g.Ref(promptbuilder.RenderedPrompt{})},
+						},
+					},
+				}),
+			},
+		},
+		"/{bot_id}/{guild_id}/participants": Object{
+			"get": Object{
+				"summary":     "List the participants in the call's voice channel",
+				"operationId": "participants",
+				"parameters":  callParameters,
+				"responses": commonErrors(Object{
+					"200": Object{
+						"description": "The participants",
+						"content": Object{
+							"application/json": Object{"schema": g.Ref([]discord.Participant{})},
+						},
+					},
+				}),
+			},
+		},
+		"/{bot_id}/{guild_id}/transcript": Object{
+			"get": Object{
+				"summary":     "Stream transcript lines",
+				"description": "Each event's data is a formatted transcript line: `[15:04:05] Username: text`.",
+				"operationId": "transcript",
+				"parameters":  callParameters,
+				"responses": commonErrors(Object{
+					"200": streamResponse("Server-sent events", Object{"type": "string"}),
+				}),
+			},
+		},
+		"/{bot_id}/{guild_id}/tool-messages": Object{
+			"get": Object{
+				"summary":     "Stream tool messages and call events",
+				"description": eventDescriptions.String(),
+				"operationId": "toolMessages",
+				"parameters":  callParameters,
+				"responses": commonErrors(Object{
+					"200": streamResponse("Server-sent events with JSON encoded data", g.Ref(responder.SSEMessage{})),
+				}),
+			},
+		},
+		"/{bot_id}/{guild_id}/service-usages": Object{
+			"get": Object{
+				"summary":     "Stream service usage events",
+				"description": "Each event's data is a JSON encoded usage event, whose UsageType tells which kind it is.",
+				"operationId": "serviceUsages",
+				"parameters":  callParameters,
+				"responses": commonErrors(Object{
+					"200": streamResponse("Server-sent events with JSON encoded data", Object{
+						"oneOf": []Object{
+							withUsageType(g, usage.TextToSpeechEvent{}),
+							withUsageType(g, usage.TranscriptionEvent{}),
+							withUsageType(g, usage.LLMEvent{}),
+						},
+					}),
+				}),
+			},
+		},
+		"/openapi.json": Object{
+			"get": Object{
+				"summary":     "This document",
+				"operationId": "openapi",
+				"security":    []Object{},
+				"responses": Object{
+					"200": Object{"description": "The OpenAPI document"},
+				},
+			},
+		},
+	}
+
+	return Object{
+		"openapi": "3.0.3",
+		"info": Object{
+			"title":       "Teno Voice",
+			"description": "REST API that connects a bot to a Discord voice channel, transcribes the conversation and responds with an LLM and text to speech.",
+			"version":     "1.0.0",
+		},
+		"security": []Object{{"bearerAuth": []string{}}},
+		"paths":    paths,
+		"components": Object{
+			"schemas": g.schemas,
+			"securitySchemes": Object{
+				"bearerAuth": Object{"type": "http", "scheme": "bearer", "description": "The API_KEY the server was started with"},
+			},
+		},
+	}
+}
+
+// withUsageType references a usage event schema, adding the UsageType property that usage.UsageEventToJSON includes
+func withUsageType(g *schemaGenerator, event usage.UsageEvent) Object {
+	return Object{
+		"allOf": []Object{
+			g.Ref(event),
+			{
+				"type":       "object",
+				"properties": Object{"UsageType": Object{"type": "string", "enum": []string{event.UsageType()}}},
+				"required":   []string{"UsageType"},
+			},
+		},
+	}
+}
+
+// Handler serves the OpenAPI document as JSON
+func Handler() http.HandlerFunc {
+	spec, err := json.Marshal(Spec())
+	if err != nil {
+		panic("error marshalling OpenAPI spec: " + err.Error())
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	})
+}
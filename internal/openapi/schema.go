@@ -0,0 +1,214 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/disgoorg/snowflake/v2"
+)
+
+// Object is a generic JSON object in the OpenAPI document
+type Object map[string]interface{}
+
+// schemaGenerator builds JSON schemas for Go types by reflection, following the same rules encoding/json uses
+// to marshal them, and registers every named struct as a reusable component
+type schemaGenerator struct {
+	schemas map[string]Object
+	names   map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		schemas: make(map[string]Object),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	snowflakeType = reflect.TypeOf(snowflake.ID(0))
+	rawJSONType   = reflect.TypeOf(json.RawMessage{})
+)
+
+// Ref returns a reference to the component schema for the type of v, generating it if needed
+func (g *schemaGenerator) Ref(v interface{}) Object {
+	return g.schemaFor(reflect.TypeOf(v))
+}
+
+// Partial registers a copy of the schema for the struct type of v, under the given name, with no required properties.
+// It is used for request bodies that accept any subset of a struct's fields.
+func (g *schemaGenerator) Partial(name string, v interface{}) Object {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	schema := g.structSchema(t)
+	delete(schema, "required")
+	g.schemas[name] = schema
+
+	return Object{"$ref": "#/components/schemas/" + name}
+}
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) Object {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return Object{"type": "string", "format": "date-time"}
+	case snowflakeType:
+		return Object{"type": "string", "description": "Discord snowflake ID"}
+	case rawJSONType:
+		return Object{"description": "Any JSON value"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Object{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Object{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Object{"type": "number"}
+	case reflect.String:
+		return Object{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Object{"type": "string", "format": "byte"}
+		}
+		return Object{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return Object{"type": "object", "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.Interface:
+		return Object{"description": "Any JSON value"}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return Object{"$ref": "#/components/schemas/" + g.register(t)}
+	}
+
+	return Object{}
+}
+
+// register generates the component schema for a named struct and returns its component name
+func (g *schemaGenerator) register(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		// Two packages use the same type name, qualify the second one with its package
+		pkgPath := strings.Split(t.PkgPath(), "/")
+		name = pkgPath[len(pkgPath)-1] + "." + name
+	}
+
+	// Register the name before generating the schema so recursive types terminate
+	g.names[t] = name
+	g.schemas[name] = Object{}
+	g.schemas[name] = g.structSchema(t)
+
+	return name
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) Object {
+	properties := Object{}
+	required := []string{}
+
+	g.addFields(t, properties, &required)
+
+	schema := Object{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+func (g *schemaGenerator) addFields(t reflect.Type, properties Object, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		jsonTag := field.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+
+		// Embedded structs without a json name have their fields promoted
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && jsonTag == "" && fieldType.Kind() == reflect.Struct {
+			g.addFields(fieldType, properties, required)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tagName := strings.Split(jsonTag, ",")[0]; tagName != "" {
+			name = tagName
+		}
+
+		schema := g.schemaFor(field.Type)
+		isRequired := applyValidation(schema, field.Tag.Get("validate"))
+		if isRequired {
+			*required = append(*required, name)
+		}
+
+		properties[name] = schema
+	}
+}
+
+// applyValidation adds the constraints from a validator tag that map onto JSON schema keywords,
+// and reports whether the field is required
+func applyValidation(schema Object, tag string) bool {
+	isRequired := false
+
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			isRequired = true
+		case "min", "max":
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil || schema["$ref"] != nil {
+				continue
+			}
+			switch schema["type"] {
+			case "integer", "number":
+				schema[map[string]string{"min": "minimum", "max": "maximum"}[key]] = number
+			case "array":
+				schema[key+"Items"] = int(number)
+			case "string":
+				schema[key+"Length"] = int(number)
+			}
+		case "gt", "lt":
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil || (schema["type"] != "integer" && schema["type"] != "number") {
+				continue
+			}
+			bound := map[string]string{"gt": "Minimum", "lt": "Maximum"}[key]
+			schema[strings.ToLower(bound)] = number
+			schema["exclusive"+bound] = true
+		case "oneof":
+			if schema["type"] == "string" {
+				schema["enum"] = strings.Fields(value)
+			}
+		}
+	}
+
+	return isRequired
+}
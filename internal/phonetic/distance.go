@@ -0,0 +1,67 @@
+package phonetic
+
+// EditDistance is the Levenshtein distance between two strings: how many runes must be inserted,
+// deleted or substituted to turn one into the other
+func EditDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+// Similarity turns the edit distance between two strings into a score from 0 to 1, where 1 means they are equal
+func Similarity(a string, b string) float64 {
+	longest := len([]rune(a))
+	if length := len([]rune(b)); length > longest {
+		longest = length
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(EditDistance(a, b))/float64(longest)
+}
+
+// Match scores how much two words sound alike from 0 to 1, from the similarity of their spelling
+// and of their Double Metaphone codes
+func Match(a string, b string) float64 {
+	if a == b {
+		return 1
+	}
+
+	primaryA, alternateA := DoubleMetaphone(a)
+	primaryB, alternateB := DoubleMetaphone(b)
+
+	sounds := 0.0
+	for _, codeA := range []string{primaryA, alternateA} {
+		for _, codeB := range []string{primaryB, alternateB} {
+			if codeA != "" && codeB != "" && Similarity(codeA, codeB) > sounds {
+				sounds = Similarity(codeA, codeB)
+			}
+		}
+	}
+
+	return (Similarity(a, b) + sounds) / 2
+}
@@ -0,0 +1,74 @@
+package phonetic
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"teno", "teno", 0},
+		{"teno", "", 4},
+		{"", "teno", 4},
+		{"teno", "tenno", 1},
+		{"teno", "tina", 2},
+		{"kitten", "sitting", 3},
+		// Runes are compared, not bytes
+		{"héllo", "hello", 1},
+	}
+
+	for _, test := range tests {
+		if distance := EditDistance(test.a, test.b); distance != test.distance {
+			t.Errorf("EditDistance(%q, %q) = %d, want %d", test.a, test.b, distance, test.distance)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b       string
+		similarity float64
+	}{
+		{"", "", 1},
+		{"teno", "teno", 1},
+		{"teno", "tina", 0.5},
+		{"teno", "tenno", 0.8},
+		{"teno", "", 0},
+	}
+
+	for _, test := range tests {
+		if similarity := Similarity(test.a, test.b); math.Abs(similarity-test.similarity) > 1e-9 {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", test.a, test.b, similarity, test.similarity)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		score float64
+	}{
+		{"teno", "teno", 1},
+		{"teno", "tenno", 0.9},
+		{"teno", "tino", 0.875},
+		{"teno", "tina", 0.75},
+		{"teno", "tanner", 0.5},
+		{"teno", "hello", 0.2},
+		{"teno", "smith", 0},
+	}
+
+	for _, test := range tests {
+		if score := Match(test.a, test.b); math.Abs(score-test.score) > 1e-9 {
+			t.Errorf("Match(%q, %q) = %v, want %v", test.a, test.b, score, test.score)
+		}
+	}
+
+	// Sounding alike ranks near misses above other words
+	if Match("teno", "tina") <= Match("teno", "tanner") {
+		t.Errorf("Match ranks tanner above tina for teno")
+	}
+}
@@ -0,0 +1,601 @@
+package phonetic
+
+import "strings"
+
+// DoubleMetaphone returns the primary and alternate Double Metaphone codes of a word. Words that sound alike
+// share a code, like "Teno" and "Tenno". Anything but the letters A to Z is ignored.
+func DoubleMetaphone(word string) (string, string) {
+	m := newMetaphone(word)
+	if m.length == 0 {
+		return "", ""
+	}
+	m.encode()
+	return m.primary.String(), m.alternate.String()
+}
+
+type metaphone struct {
+	// word is padded with spaces, so looking ahead never goes past its end
+	word          string
+	length        int
+	last          int
+	slavoGermanic bool
+	primary       strings.Builder
+	alternate     strings.Builder
+}
+
+func newMetaphone(word string) *metaphone {
+	var letters strings.Builder
+	for _, r := range strings.ToUpper(word) {
+		if r >= 'A' && r <= 'Z' {
+			letters.WriteRune(r)
+		}
+	}
+
+	m := &metaphone{
+		word:   letters.String() + "     ",
+		length: letters.Len(),
+		last:   letters.Len() - 1,
+	}
+	m.slavoGermanic = strings.ContainsAny(m.word, "WK") || strings.Contains(m.word, "CZ") || strings.Contains(m.word, "WITZ")
+	return m
+}
+
+func (m *metaphone) at(i int) byte {
+	if i < 0 || i >= len(m.word) {
+		return 0
+	}
+	return m.word[i]
+}
+
+// stringAt checks if one of the options is found at start
+func (m *metaphone) stringAt(start int, length int, options ...string) bool {
+	if start < 0 || start+length > len(m.word) {
+		return false
+	}
+
+	substring := m.word[start : start+length]
+	for _, option := range options {
+		if substring == option {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *metaphone) isVowel(i int) bool {
+	return strings.IndexByte("AEIOUY", m.at(i)) >= 0 && m.at(i) != 0
+}
+
+func (m *metaphone) add(primary string, alternate string) {
+	m.primary.WriteString(primary)
+	m.alternate.WriteString(alternate)
+}
+
+// skipDouble moves past a letter, and the next one if it is the same
+func (m *metaphone) skipDouble(i int) int {
+	if m.at(i+1) == m.at(i) {
+		return i + 2
+	}
+	return i + 1
+}
+
+func (m *metaphone) encode() {
+	current := 0
+
+	// Skip these when at the start of a word
+	if m.stringAt(0, 2, "GN", "KN", "PN", "WR", "PS") {
+		current = 1
+	}
+
+	// An initial X is pronounced Z, which maps to S
+	if m.at(0) == 'X' {
+		m.add("S", "S")
+		current = 1
+	}
+
+	for current < m.length {
+		switch m.at(current) {
+		case 'A', 'E', 'I', 'O', 'U', 'Y':
+			// Only initial vowels are encoded
+			if current == 0 {
+				m.add("A", "A")
+			}
+			current++
+
+		case 'B':
+			m.add("P", "P")
+			current = m.skipDouble(current)
+
+		case 'C':
+			current = m.encodeC(current)
+
+		case 'D':
+			switch {
+			case m.stringAt(current, 2, "DG"):
+				if m.stringAt(current+2, 1, "I", "E", "Y") {
+					m.add("J", "J")
+					current += 3
+				} else {
+					m.add("TK", "TK")
+					current += 2
+				}
+			case m.stringAt(current, 2, "DT", "DD"):
+				m.add("T", "T")
+				current += 2
+			default:
+				m.add("T", "T")
+				current++
+			}
+
+		case 'F':
+			m.add("F", "F")
+			current = m.skipDouble(current)
+
+		case 'G':
+			current = m.encodeG(current)
+
+		case 'H':
+			// Only keep an H between vowels, or at the start before a vowel
+			if (current == 0 || m.isVowel(current-1)) && m.isVowel(current+1) {
+				m.add("H", "H")
+				current += 2
+			} else {
+				current++
+			}
+
+		case 'J':
+			current = m.encodeJ(current)
+
+		case 'K':
+			m.add("K", "K")
+			current = m.skipDouble(current)
+
+		case 'L':
+			if m.at(current+1) == 'L' {
+				// Spanish, like "cabrillo" and "gallegos"
+				if (current == m.length-3 && m.stringAt(current-1, 4, "ILLO", "ILLA", "ALLE")) ||
+					((m.stringAt(m.last-1, 2, "AS", "OS") || m.stringAt(m.last, 1, "A", "O")) && m.stringAt(current-1, 4, "ALLE")) {
+					m.add("L", "")
+				} else {
+					m.add("L", "L")
+				}
+				current += 2
+			} else {
+				m.add("L", "L")
+				current++
+			}
+
+		case 'M':
+			m.add("M", "M")
+			if (m.stringAt(current-1, 3, "UMB") && (current+1 == m.last || m.stringAt(current+2, 2, "ER"))) || m.at(current+1) == 'M' {
+				current += 2
+			} else {
+				current++
+			}
+
+		case 'N':
+			m.add("N", "N")
+			current = m.skipDouble(current)
+
+		case 'P':
+			if m.at(current+1) == 'H' {
+				m.add("F", "F")
+				current += 2
+			} else {
+				m.add("P", "P")
+				if m.stringAt(current+1, 1, "P", "B") {
+					current += 2
+				} else {
+					current++
+				}
+			}
+
+		case 'Q':
+			m.add("K", "K")
+			current = m.skipDouble(current)
+
+		case 'R':
+			// French, like "rogier"
+			if current == m.last && !m.slavoGermanic && m.stringAt(current-2, 2, "IE") && !m.stringAt(current-4, 2, "ME", "MA") {
+				m.add("", "R")
+			} else {
+				m.add("R", "R")
+			}
+			current = m.skipDouble(current)
+
+		case 'S':
+			current = m.encodeS(current)
+
+		case 'T':
+			current = m.encodeT(current)
+
+		case 'V':
+			m.add("F", "F")
+			current = m.skipDouble(current)
+
+		case 'W':
+			current = m.encodeW(current)
+
+		case 'X':
+			// French, like "breaux"
+			if !(current == m.last && (m.stringAt(current-3, 3, "IAU", "EAU") || m.stringAt(current-2, 2, "AU", "OU"))) {
+				m.add("KS", "KS")
+			}
+			if m.stringAt(current+1, 1, "C", "X") {
+				current += 2
+			} else {
+				current++
+			}
+
+		case 'Z':
+			if m.at(current+1) == 'H' {
+				// Chinese, like "zhao"
+				m.add("J", "J")
+				current += 2
+				continue
+			}
+			if m.stringAt(current+1, 2, "ZO", "ZI", "ZA") || (m.slavoGermanic && current > 0 && m.at(current-1) != 'T') {
+				m.add("S", "TS")
+			} else {
+				m.add("S", "S")
+			}
+			current = m.skipDouble(current)
+
+		default:
+			current++
+		}
+	}
+}
+
+func (m *metaphone) encodeC(current int) int {
+	// Various Germanic, like "bacher" and "macher"
+	if current > 1 && !m.isVowel(current-2) && m.stringAt(current-1, 3, "ACH") && m.at(current+2) != 'I' &&
+		(m.at(current+2) != 'E' || m.stringAt(current-2, 6, "BACHER", "MACHER")) {
+		m.add("K", "K")
+		return current + 2
+	}
+
+	if current == 0 && m.stringAt(current, 6, "CAESAR") {
+		m.add("S", "S")
+		return current + 2
+	}
+
+	// Italian, like "chianti"
+	if m.stringAt(current, 4, "CHIA") {
+		m.add("K", "K")
+		return current + 2
+	}
+
+	if m.stringAt(current, 2, "CH") {
+		// Like "michael"
+		if current > 0 && m.stringAt(current, 4, "CHAE") {
+			m.add("K", "X")
+			return current + 2
+		}
+
+		// Greek roots, like "chemistry" and "chorus"
+		if current == 0 && (m.stringAt(current+1, 5, "HARAC", "HARIS") || m.stringAt(current+1, 3, "HOR", "HYM", "HIA", "HEM")) &&
+			!m.stringAt(0, 5, "CHORE") {
+			m.add("K", "K")
+			return current + 2
+		}
+
+		// Germanic, Greek, or otherwise "ch" pronounced "kh"
+		if m.stringAt(0, 4, "VAN ", "VON ") || m.stringAt(0, 3, "SCH") ||
+			m.stringAt(current-2, 6, "ORCHES", "ARCHIT", "ORCHID") || m.stringAt(current+2, 1, "T", "S") ||
+			((m.stringAt(current-1, 1, "A", "O", "U", "E") || current == 0) &&
+				m.stringAt(current+2, 1, "L", "R", "N", "M", "B", "H", "F", "V", "W", " ")) {
+			m.add("K", "K")
+		} else if current > 0 {
+			if m.stringAt(0, 2, "MC") {
+				m.add("K", "K")
+			} else {
+				m.add("X", "K")
+			}
+		} else {
+			m.add("X", "X")
+		}
+		return current + 2
+	}
+
+	// Like "czerny"
+	if m.stringAt(current, 2, "CZ") && !m.stringAt(current-2, 4, "WICZ") {
+		m.add("S", "X")
+		return current + 2
+	}
+
+	// Like "focaccia"
+	if m.stringAt(current+1, 3, "CIA") {
+		m.add("X", "X")
+		return current + 3
+	}
+
+	// Double C, but not if like "mcclellan"
+	if m.stringAt(current, 2, "CC") && !(current == 1 && m.at(0) == 'M') {
+		if m.stringAt(current+2, 1, "I", "E", "H") && !m.stringAt(current+2, 2, "HU") {
+			// Like "accident", "accede" and "succeed"
+			if (current == 1 && m.at(current-1) == 'A') || m.stringAt(current-1, 5, "UCCEE", "UCCES") {
+				m.add("KS", "KS")
+			} else {
+				// Like "bacci" and "bertucci"
+				m.add("X", "X")
+			}
+			return current + 3
+		}
+
+		m.add("K", "K")
+		return current + 2
+	}
+
+	if m.stringAt(current, 2, "CK", "CG", "CQ") {
+		m.add("K", "K")
+		return current + 2
+	}
+
+	if m.stringAt(current, 2, "CI", "CE", "CY") {
+		// Italian or not
+		if m.stringAt(current, 3, "CIO", "CIE", "CIA") {
+			m.add("S", "X")
+		} else {
+			m.add("S", "S")
+		}
+		return current + 2
+	}
+
+	m.add("K", "K")
+
+	// Like "mac caffrey" and "mac gregor"
+	if m.stringAt(current+1, 2, " C", " Q", " G") {
+		return current + 3
+	}
+	if m.stringAt(current+1, 1, "C", "K", "Q") && !m.stringAt(current+1, 2, "CE", "CI") {
+		return current + 2
+	}
+	return current + 1
+}
+
+func (m *metaphone) encodeG(current int) int {
+	if m.at(current+1) == 'H' {
+		if current > 0 && !m.isVowel(current-1) {
+			m.add("K", "K")
+			return current + 2
+		}
+
+		// Like "ghislane" and "ghiradelli"
+		if current == 0 {
+			if m.at(current+2) == 'I' {
+				m.add("J", "J")
+			} else {
+				m.add("K", "K")
+			}
+			return current + 2
+		}
+
+		// Parker's rule, like "hugh", "bough" and "broughton"
+		if (current > 1 && m.stringAt(current-2, 1, "B", "H", "D")) ||
+			(current > 2 && m.stringAt(current-3, 1, "B", "H", "D")) ||
+			(current > 3 && m.stringAt(current-4, 1, "B", "H")) {
+			return current + 2
+		}
+
+		// Like "laugh", "cough" and "tough"
+		if current > 2 && m.at(current-1) == 'U' && m.stringAt(current-3, 1, "C", "G", "L", "R", "T") {
+			m.add("F", "F")
+		} else if current > 0 && m.at(current-1) != 'I' {
+			m.add("K", "K")
+		}
+		return current + 2
+	}
+
+	if m.at(current+1) == 'N' {
+		if current == 1 && m.isVowel(0) && !m.slavoGermanic {
+			m.add("KN", "N")
+		} else if !m.stringAt(current+2, 2, "EY") && m.at(current+1) != 'Y' && !m.slavoGermanic {
+			// Not like "cagney"
+			m.add("N", "KN")
+		} else {
+			m.add("KN", "KN")
+		}
+		return current + 2
+	}
+
+	// Like "tagliaro"
+	if m.stringAt(current+1, 2, "LI") && !m.slavoGermanic {
+		m.add("KL", "L")
+		return current + 2
+	}
+
+	// Like "ges", "gep" and "gel" at the start
+	if current == 0 && (m.at(current+1) == 'Y' ||
+		m.stringAt(current+1, 2, "ES", "EP", "EB", "EL", "EY", "IB", "IL", "IN", "IE", "EI", "ER")) {
+		m.add("K", "J")
+		return current + 2
+	}
+
+	// Like "-ger-" and "-gy-"
+	if (m.stringAt(current+1, 2, "ER") || m.at(current+1) == 'Y') &&
+		!m.stringAt(0, 6, "DANGER", "RANGER", "MANGER") &&
+		!m.stringAt(current-1, 1, "E", "I") && !m.stringAt(current-1, 3, "RGY", "OGY") {
+		m.add("K", "J")
+		return current + 2
+	}
+
+	// Italian, like "biaggi"
+	if m.stringAt(current+1, 1, "E", "I", "Y") || m.stringAt(current-1, 4, "AGGI", "OGGI") {
+		if m.stringAt(0, 4, "VAN ", "VON ") || m.stringAt(0, 3, "SCH") || m.stringAt(current+1, 2, "ET") {
+			// Germanic
+			m.add("K", "K")
+		} else if m.stringAt(current+1, 4, "IER ") {
+			m.add("J", "J")
+		} else {
+			m.add("J", "K")
+		}
+		return current + 2
+	}
+
+	m.add("K", "K")
+	return m.skipDouble(current)
+}
+
+func (m *metaphone) encodeJ(current int) int {
+	// Spanish, like "jose" and "san jacinto"
+	if m.stringAt(current, 4, "JOSE") || m.stringAt(0, 4, "SAN ") {
+		if (current == 0 && m.at(current+4) == ' ') || m.stringAt(0, 4, "SAN ") {
+			m.add("H", "H")
+		} else {
+			m.add("J", "H")
+		}
+		return current + 1
+	}
+
+	if current == 0 {
+		// Like "yankelovich" and "jankelowicz"
+		m.add("J", "A")
+	} else if m.isVowel(current-1) && !m.slavoGermanic && (m.at(current+1) == 'A' || m.at(current+1) == 'O') {
+		// Spanish, like "bajador"
+		m.add("J", "H")
+	} else if current == m.last {
+		m.add("J", "")
+	} else if !m.stringAt(current+1, 1, "L", "T", "K", "S", "N", "M", "B", "Z") && !m.stringAt(current-1, 1, "S", "K", "L") {
+		m.add("J", "J")
+	}
+
+	return m.skipDouble(current)
+}
+
+func (m *metaphone) encodeS(current int) int {
+	// Special cases, like "island", "isle" and "carlisle"
+	if m.stringAt(current-1, 3, "ISL", "YSL") {
+		return current + 1
+	}
+
+	if current == 0 && m.stringAt(current, 5, "SUGAR") {
+		m.add("X", "S")
+		return current + 1
+	}
+
+	if m.stringAt(current, 2, "SH") {
+		// Germanic
+		if m.stringAt(current+1, 4, "HEIM", "HOEK", "HOLM", "HOLZ") {
+			m.add("S", "S")
+		} else {
+			m.add("X", "X")
+		}
+		return current + 2
+	}
+
+	// Italian and Armenian
+	if m.stringAt(current, 3, "SIO", "SIA") || m.stringAt(current, 4, "SIAN") {
+		if !m.slavoGermanic {
+			m.add("S", "X")
+		} else {
+			m.add("S", "S")
+		}
+		return current + 3
+	}
+
+	// German and anglicisations, like "smith" matching "schmidt" and "snider" matching "schneider"
+	if (current == 0 && m.stringAt(current+1, 1, "M", "N", "L", "W")) || m.stringAt(current+1, 1, "Z") {
+		m.add("S", "X")
+		if m.stringAt(current+1, 1, "Z") {
+			return current + 2
+		}
+		return current + 1
+	}
+
+	if m.stringAt(current, 2, "SC") {
+		// Schlesinger's rule
+		if m.at(current+2) == 'H' {
+			// Dutch origin, like "school" and "schooner"
+			if m.stringAt(current+3, 2, "OO", "ER", "EN", "UY", "ED", "EM") {
+				// Like "schermerhorn" and "schenker"
+				if m.stringAt(current+3, 2, "ER", "EN") {
+					m.add("X", "SK")
+				} else {
+					m.add("SK", "SK")
+				}
+				return current + 3
+			}
+
+			if current == 0 && !m.isVowel(3) && m.at(3) != 'W' {
+				m.add("X", "S")
+			} else {
+				m.add("X", "X")
+			}
+			return current + 3
+		}
+
+		if m.stringAt(current+2, 1, "I", "E", "Y") {
+			m.add("S", "S")
+		} else {
+			m.add("SK", "SK")
+		}
+		return current + 3
+	}
+
+	// French, like "resnais" and "artois"
+	if current == m.last && m.stringAt(current-2, 2, "AI", "OI") {
+		m.add("", "S")
+	} else {
+		m.add("S", "S")
+	}
+
+	if m.stringAt(current+1, 1, "S", "Z") {
+		return current + 2
+	}
+	return current + 1
+}
+
+func (m *metaphone) encodeT(current int) int {
+	if m.stringAt(current, 4, "TION") || m.stringAt(current, 3, "TIA", "TCH") {
+		m.add("X", "X")
+		return current + 3
+	}
+
+	if m.stringAt(current, 2, "TH") || m.stringAt(current, 3, "TTH") {
+		// Like "thomas" and "thames", or Germanic
+		if m.stringAt(current+2, 2, "OM", "AM") || m.stringAt(0, 4, "VAN ", "VON ") || m.stringAt(0, 3, "SCH") {
+			m.add("T", "T")
+		} else {
+			m.add("0", "T")
+		}
+		return current + 2
+	}
+
+	m.add("T", "T")
+	if m.stringAt(current+1, 1, "T", "D") {
+		return current + 2
+	}
+	return current + 1
+}
+
+func (m *metaphone) encodeW(current int) int {
+	// Can also be in the middle of a word
+	if m.stringAt(current, 2, "WR") {
+		m.add("R", "R")
+		return current + 2
+	}
+
+	if current == 0 && (m.isVowel(current+1) || m.stringAt(current, 2, "WH")) {
+		// Like "wasserman" matching "vasserman"
+		if m.isVowel(current + 1) {
+			m.add("A", "F")
+		} else {
+			m.add("A", "A")
+		}
+	}
+
+	// Like "arnow", or Polish, like "filipowicz"
+	if (current == m.last && m.isVowel(current-1)) || m.stringAt(current-1, 5, "EWSKI", "EWSKY", "OWSKI", "OWSKY") || m.stringAt(0, 3, "SCH") {
+		m.add("", "F")
+		return current + 1
+	}
+
+	// Polish, like "filipowicz"
+	if m.stringAt(current, 4, "WICZ", "WITZ") {
+		m.add("TS", "FX")
+		return current + 4
+	}
+
+	return current + 1
+}
@@ -0,0 +1,33 @@
+package phonetic
+
+import "testing"
+
+func TestDoubleMetaphone(t *testing.T) {
+	tests := []struct {
+		word      string
+		primary   string
+		alternate string
+	}{
+		{"teno", "TN", "TN"},
+		{"tina", "TN", "TN"},
+		{"tenno", "TN", "TN"},
+		{"tino", "TN", "TN"},
+		{"tanner", "TNR", "TNR"},
+		{"thomas", "TMS", "TMS"},
+		{"smith", "SM0", "XMT"},
+		{"schmidt", "XMT", "SMT"},
+		{"knight", "NT", "NT"},
+		{"xavier", "SF", "SFR"},
+		{"caesar", "SSR", "SSR"},
+		{"phone", "FN", "FN"},
+		{"judge", "JJ", "AJ"},
+		{"", "", ""},
+	}
+
+	for _, test := range tests {
+		primary, alternate := DoubleMetaphone(test.word)
+		if primary != test.primary || alternate != test.alternate {
+			t.Errorf("DoubleMetaphone(%q) = %q, %q, want %q, %q", test.word, primary, alternate, test.primary, test.alternate)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package responder
+
+// AccessPolicy controls which participants can wake the bot, trigger its responses, and have it use tools.
+// A rule that isn't set allows everyone.
+type AccessPolicy struct {
+	// Wake is who can wake the bot by saying its name in AutoSleep mode
+	Wake *AccessRule
+	// Respond is whose lines can trigger a response. Lines of other participants are still added to the transcript.
+	Respond *AccessRule
+	// Tools are the rules for invoking tools, by tool name, checked against the participant who triggered the response.
+	// The rule named "*" applies to the tools that don't have a rule of their own. Responses that no one triggered
+	// can only use the tools whose rule allows everyone.
+	Tools map[string]AccessRule
+}
+
+// AccessRule allows or denies participants by Discord user ID and role ID. Denials win over allowances,
+// and a rule without any allowance allows everyone who isn't denied.
+type AccessRule struct {
+	AllowUsers []string
+	DenyUsers  []string
+	AllowRoles []string
+	DenyRoles  []string
+}
+
+// speaker is the participant who triggered a response. Responses that no one triggered, like the ones requested
+// through the API or by the task loop, have an empty speaker.
+type speaker struct {
+	userID string
+	roles  []string
+}
+
+// Allows checks if a participant with the given user ID and roles passes the rule
+func (rule *AccessRule) Allows(userID string, roles []string) bool {
+	if rule == nil {
+		return true
+	}
+
+	if contains(rule.DenyUsers, userID) {
+		return false
+	}
+	for _, role := range roles {
+		if contains(rule.DenyRoles, role) {
+			return false
+		}
+	}
+
+	if len(rule.AllowUsers) == 0 && len(rule.AllowRoles) == 0 {
+		return true
+	}
+
+	if contains(rule.AllowUsers, userID) {
+		return true
+	}
+	for _, role := range roles {
+		if contains(rule.AllowRoles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *AccessPolicy) canWake(s speaker) bool {
+	return p == nil || p.Wake.Allows(s.userID, s.roles)
+}
+
+func (p *AccessPolicy) canTriggerResponse(s speaker) bool {
+	return p == nil || p.Respond.Allows(s.userID, s.roles)
+}
+
+// canUseTool checks if a tool can be invoked in a response triggered by the speaker.
+// An empty speaker isn't anyone a rule allows, so a response no one triggered can't use a tool
+// whose rule only allows some participants.
+func (p *AccessPolicy) canUseTool(name string, s speaker) bool {
+	if p == nil {
+		return true
+	}
+
+	if rule, ok := p.Tools[name]; ok {
+		return rule.Allows(s.userID, s.roles)
+	}
+	if rule, ok := p.Tools["*"]; ok {
+		return rule.Allows(s.userID, s.roles)
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
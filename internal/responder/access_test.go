@@ -0,0 +1,39 @@
+package responder
+
+import "testing"
+
+func TestCanUseTool(t *testing.T) {
+	policy := &AccessPolicy{
+		Tools: map[string]AccessRule{
+			"deleteTicket": {AllowRoles: []string{"admin"}},
+			"getWeather":   {},
+			"*":            {DenyUsers: []string{"2"}},
+		},
+	}
+	admin := speaker{userID: "1", roles: []string{"admin"}}
+	denied := speaker{userID: "2"}
+	untriggered := speaker{}
+
+	tests := []struct {
+		policy *AccessPolicy
+		tool   string
+		s      speaker
+		want   bool
+	}{
+		{nil, "deleteTicket", untriggered, true},
+		{policy, "deleteTicket", admin, true},
+		{policy, "deleteTicket", denied, false},
+		{policy, "deleteTicket", untriggered, false},
+		{policy, "getWeather", denied, true},
+		{policy, "getWeather", untriggered, true},
+		{policy, "createTicket", admin, true},
+		{policy, "createTicket", denied, false},
+		{policy, "createTicket", untriggered, true},
+	}
+
+	for _, test := range tests {
+		if got := test.policy.canUseTool(test.tool, test.s); got != test.want {
+			t.Errorf("canUseTool(%q, %+v) = %v, want %v", test.tool, test.s, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package responder
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// BargeInConfig decides when a user speaking over the bot interrupts it.
+// Speech interrupts once it reaches MinWords words or lasts MinDuration milliseconds, whichever comes first.
+// A zero threshold is not used, and with both at zero any speech interrupts.
+type BargeInConfig struct {
+	MinWords    int `validate:"min=0"`
+	MinDuration int `validate:"min=0"`
+	// Backchannels are words like "mm-hmm" or "yeah" that never interrupt when they are all the user says
+	Backchannels []string
+	// PauseAndResume pauses the bot while the user's speech is too short to count as an interruption,
+	// and resumes the current sentence if it never does
+	PauseAndResume bool
+	// ResumeTimeout is how many milliseconds a pause lasts without more speech before the bot resumes
+	ResumeTimeout int `validate:"min=0"`
+}
+
+// isBargeIn checks if speech of the given length is an interruption under the barge-in policy
+func (c BargeInConfig) isBargeIn(text string, duration time.Duration) bool {
+	words := normalizedWords(text)
+	if len(words) == 0 || c.isBackchannel(words) {
+		return false
+	}
+
+	if c.MinWords == 0 && c.MinDuration == 0 {
+		return true
+	}
+
+	return (c.MinWords > 0 && len(words) >= c.MinWords) ||
+		(c.MinDuration > 0 && duration >= time.Duration(c.MinDuration)*time.Millisecond)
+}
+
+// isBackchannel checks if all the words are backchannel words
+func (c BargeInConfig) isBackchannel(words []string) bool {
+	for _, word := range words {
+		found := false
+		for _, backchannel := range c.Backchannels {
+			if strings.EqualFold(word, backchannel) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizedWords splits text into lowercase words without surrounding punctuation
+func normalizedWords(text string) []string {
+	var words []string
+	for _, field := range strings.Fields(text) {
+		word := strings.ToLower(strings.TrimFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		}))
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// playbackGate lets playback be paused between opus packets and resumed where it stopped
+type playbackGate struct {
+	resumeChan  chan struct{}
+	resumeTimer *time.Timer
+	mu          sync.Mutex
+}
+
+// pause pauses playback until resume is called or the timeout passes. Pausing again extends the timeout.
+func (g *playbackGate) pause(timeout time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.resumeChan == nil {
+		g.resumeChan = make(chan struct{})
+	}
+
+	if g.resumeTimer != nil {
+		g.resumeTimer.Stop()
+	}
+	g.resumeTimer = time.AfterFunc(timeout, g.resume)
+}
+
+func (g *playbackGate) resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.resumeTimer != nil {
+		g.resumeTimer.Stop()
+		g.resumeTimer = nil
+	}
+
+	if g.resumeChan != nil {
+		close(g.resumeChan)
+		g.resumeChan = nil
+	}
+}
+
+// paused returns the channel closed on resume, or nil if playback isn't paused
+func (g *playbackGate) paused() chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.resumeChan
+}
+
+// waitWhilePaused blocks while playback is paused or until ctx is cancelled,
+// and returns how many silent frames it played
+func (r *Responder) waitWhilePaused(ctx context.Context) int {
+	resumeChan := r.playback.paused()
+	if resumeChan == nil {
+		return 0
+	}
+
+	// Silence frames stop the decoder from stretching the last packet over the pause
+	r.sendSilentFrames(5)
+
+	select {
+	case <-ctx.Done():
+	case <-resumeChan:
+	}
+	return 5
+}
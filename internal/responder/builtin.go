@@ -0,0 +1,184 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"com.deablabs.teno-voice/internal/responder/tools"
+	texttospeech "com.deablabs.teno-voice/internal/textToSpeech"
+	"com.deablabs.teno-voice/internal/textToSpeech/azure"
+)
+
+// executeBuiltinTool runs a built-in tool, records it in the transcript like any other tool message,
+// and reports it on the event stream. Only the event loop calls it.
+func (r *Responder) executeBuiltinTool(resp *response, toolMessage tools.ToolMessage) {
+	execution := tools.BuiltinToolExecution{
+		Name:  toolMessage.Name,
+		Input: toolMessage.Input,
+	}
+
+	if err := r.runBuiltinTool(resp, toolMessage); err != nil {
+		fmt.Printf("Error executing built-in tool %s: %v\n", toolMessage.Name, err)
+		execution.Error = err.Error()
+	}
+
+	toolMessageJson, err := json.Marshal([]tools.ToolMessage{toolMessage})
+	if err == nil {
+		r.Transcript.AddToolMessageLine(string(toolMessageJson))
+	}
+
+	r.SendJSONEvent("builtin-tool", execution)
+}
+
+func (r *Responder) runBuiltinTool(resp *response, toolMessage tools.ToolMessage) error {
+	settings := resp.settings
+
+	var input struct {
+		Minutes      float64
+		Voice        string
+		Mode         string
+		Clip         string
+		Task         string
+		Status       string
+		Instruction  string
+		At           string
+		EveryMinutes float64
+		Job          string
+	}
+	if err := json.Unmarshal(toolMessage.Input, &input); err != nil {
+		return fmt.Errorf("invalid input: %s", err)
+	}
+
+	switch toolMessage.Name {
+	case tools.BuiltinLeaveCall:
+		if r.leave == nil {
+			return errors.New("leaving the call isn't supported")
+		}
+		r.leave()
+
+	case tools.BuiltinGoToSleep:
+		r.sleep()
+		if r.response == nil && r.State() == StateIdle {
+			r.transition(r.restingState(), "went to sleep")
+		}
+
+	case tools.BuiltinMuteSelf:
+		r.mutedUntil = r.clock.Now().Add(time.Duration(input.Minutes * float64(time.Minute)))
+
+	case tools.BuiltinChangeVoice:
+		// Changes are made to the latest settings, since a config update may have replaced the response's snapshot
+		err := r.UpdateSettings(func(settings *Settings) error {
+			tts, err := texttospeech.WithVoice(settings.TTSService, input.Voice)
+			if err != nil {
+				return err
+			}
+			settings.TTSService = tts
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		r.notifySettingsChanged()
+
+	case tools.BuiltinSetSpeakingMode:
+		r.UpdateSettings(func(settings *Settings) error {
+			settings.VoiceUXConfig.SpeakingMode = input.Mode
+			return nil
+		})
+		r.notifySettingsChanged()
+
+		switch input.Mode {
+		case "AlwaysSpeak":
+			r.wakeUp()
+		case "AlwaysSleep", "DirectAddress":
+			r.sleep()
+		}
+		if r.response == nil && r.State() != StateListening {
+			r.transition(r.restingState(), "speaking mode changed")
+		}
+
+	case tools.BuiltinPlayClip:
+		clip, ok := settings.PromptContents.BuiltinTools.Clip(input.Clip)
+		if !ok {
+			return fmt.Errorf("clip '%s' is not available", input.Clip)
+		}
+		r.stopResponse()
+		r.startClip(clip)
+
+	case tools.BuiltinSetTaskStatus:
+		return r.setTaskStatus(input.Task, input.Status, "tool")
+
+	case tools.BuiltinScheduleJob:
+		return r.scheduleJobByTool(settings, resp.triggeredBy, input.Instruction, input.Minutes, input.At, input.EveryMinutes)
+
+	case tools.BuiltinCancelJob:
+		// The bot can only cancel the jobs it scheduled, not the ones scheduled through the API
+		return r.cancelJob(input.Job, JobSourceTool)
+
+	default:
+		return fmt.Errorf("unknown built-in tool '%s'", toolMessage.Name)
+	}
+
+	return nil
+}
+
+// notifySettingsChanged lets the call know a built-in tool changed the settings
+func (r *Responder) notifySettingsChanged() {
+	if r.settingsChanged != nil {
+		r.settingsChanged(r)
+	}
+}
+
+// startClip plays a clip as a response of its own, so it can be interrupted like speech. Only the event loop calls it.
+func (r *Responder) startClip(clip tools.Clip) {
+	resp := r.newResponse("clip", speaker{})
+	audioStreamChan := make(chan audioStreamWithIndex, 1)
+
+	r.spawn(func() {
+		defer close(audioStreamChan)
+
+		audio, err := fetchClip(resp.ctx, clip)
+		if err != nil {
+			fmt.Printf("Error fetching clip %s: %v\n", clip.Name, err)
+			return
+		}
+
+		audioStreamChan <- audioStreamWithIndex{
+			index:       0,
+			opusPackets: audio,
+		}
+	})
+
+	r.spawn(func() {
+		r.playSynthesizedSentences(resp.ctx, resp, time.Now(), audioStreamChan)
+
+		r.post(responseDoneEvent{
+			response: resp,
+		})
+	})
+}
+
+// fetchClip downloads a clip and returns its opus packets
+func fetchClip(ctx context.Context, clip tools.Clip) (*azure.OpusPacketReader, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, clip.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	// Clips are Ogg Opus, like the audio synthesized by Azure
+	return azure.NewOpusPacketReader(resp.Body), nil
+}
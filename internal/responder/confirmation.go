@@ -0,0 +1,306 @@
+package responder
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"com.deablabs.teno-voice/internal/responder/tools"
+)
+
+// Outcomes of a confirmation
+const (
+	ConfirmationConfirmed = "confirmed"
+	ConfirmationDeclined  = "declined"
+	// ConfirmationUnanswered is when the first full turn after the read back is neither a yes nor a no
+	ConfirmationUnanswered = "unanswered"
+	ConfirmationTimedOut   = "timed-out"
+)
+
+// How long a confirmation waits for an answer when VoiceUXConfig.ConfirmationTimeout isn't set
+const defaultConfirmationTimeout = 20 * time.Second
+
+var ErrConfirmationNotFound = errors.New("confirmation not found")
+
+// PendingConfirmation is an invocation of a tool that requires confirmation, held until a participant confirms it.
+// It is sent on the event stream as a "confirmation-pending" event.
+type PendingConfirmation struct {
+	ID          string
+	ToolMessage tools.ToolMessage
+	ExpiresAt   time.Time
+}
+
+// ConfirmationResolution is sent on the event stream as a "confirmation-resolved" event once a confirmation is answered
+type ConfirmationResolution struct {
+	ID          string
+	ToolMessage tools.ToolMessage
+	Outcome     string
+	// ResolvedBy is "voice", "api" or "timeout"
+	ResolvedBy string
+}
+
+type pendingConfirmation struct {
+	PendingConfirmation
+	response *response
+	// readBack is the response reading the confirmation back, nil if the bot was muted and couldn't
+	readBack *response
+	// answerable is set once the read back is over, so only the turns after it can answer by voice
+	answerable bool
+}
+
+// confirmationsEvent asks for the pending confirmations
+type confirmationsEvent struct {
+	reply chan []PendingConfirmation
+}
+
+// resolveConfirmationEvent confirms or declines a pending confirmation
+type resolveConfirmationEvent struct {
+	id        string
+	confirmed bool
+	reply     chan error
+}
+
+func (confirmationsEvent) isEvent()       {}
+func (resolveConfirmationEvent) isEvent() {}
+
+// PendingConfirmations returns the tool invocations waiting to be confirmed
+func (r *Responder) PendingConfirmations() []PendingConfirmation {
+	reply := make(chan []PendingConfirmation, 1)
+	r.post(confirmationsEvent{reply: reply})
+
+	select {
+	case confirmations := <-reply:
+		return confirmations
+	case <-r.loopDone:
+		return nil
+	}
+}
+
+// ResolveConfirmation confirms or declines a pending confirmation, as if a participant had answered it
+func (r *Responder) ResolveConfirmation(id string, confirmed bool) error {
+	reply := make(chan error, 1)
+	r.post(resolveConfirmationEvent{
+		id:        id,
+		confirmed: confirmed,
+		reply:     reply,
+	})
+
+	select {
+	case err := <-reply:
+		return err
+	case <-r.loopDone:
+		return ErrConfirmationNotFound
+	}
+}
+
+func (r *Responder) handleConfirmations(e confirmationsEvent) {
+	confirmations := make([]PendingConfirmation, 0, len(r.confirmations))
+	for _, confirmation := range r.confirmations {
+		confirmations = append(confirmations, confirmation.PendingConfirmation)
+	}
+	e.reply <- confirmations
+}
+
+func (r *Responder) handleResolveConfirmation(e resolveConfirmationEvent) {
+	outcome := ConfirmationDeclined
+	if e.confirmed {
+		outcome = ConfirmationConfirmed
+	}
+
+	for _, confirmation := range r.confirmations {
+		if confirmation.ID == e.id {
+			r.resolveConfirmation(confirmation, outcome, "api")
+			e.reply <- nil
+			return
+		}
+	}
+	e.reply <- ErrConfirmationNotFound
+}
+
+// requestConfirmations holds tool invocations until they are confirmed, and reads them back to the call
+func (r *Responder) requestConfirmations(resp *response, toolMessages []tools.ToolMessage) {
+	if len(toolMessages) == 0 {
+		return
+	}
+
+	timeout := defaultConfirmationTimeout
+	if resp.settings.VoiceUXConfig.ConfirmationTimeout > 0 {
+		timeout = time.Duration(resp.settings.VoiceUXConfig.ConfirmationTimeout) * time.Second
+	}
+
+	readBacks := make([]string, 0, len(toolMessages))
+	confirmations := make([]*pendingConfirmation, 0, len(toolMessages))
+	for _, toolMessage := range toolMessages {
+		confirmation := &pendingConfirmation{
+			PendingConfirmation: PendingConfirmation{
+				ID:          newID(),
+				ToolMessage: toolMessage,
+				ExpiresAt:   r.clock.Now().Add(timeout),
+			},
+			response: resp,
+		}
+		r.confirmations = append(r.confirmations, confirmation)
+		confirmations = append(confirmations, confirmation)
+		r.SendJSONEvent("confirmation-pending", confirmation.PendingConfirmation)
+
+		readBacks = append(readBacks, readBack(toolMessage))
+	}
+
+	// A muted bot doesn't read them back, so they can only be resolved through the API or time out
+	if r.clock.Now().Before(r.mutedUntil) {
+		return
+	}
+
+	r.say(fmt.Sprintf("Just to confirm, you want me to %s. Should I go ahead?", strings.Join(readBacks, ", and ")), "confirmation")
+	for _, confirmation := range confirmations {
+		confirmation.readBack = r.response
+	}
+}
+
+// readBackDone lets the confirmations read back by the response be answered by voice
+func (r *Responder) readBackDone(resp *response) {
+	for _, confirmation := range r.confirmations {
+		if confirmation.readBack == resp {
+			confirmation.answerable = true
+		}
+	}
+}
+
+// resolveConfirmationsByVoice answers the pending confirmations with the first full turn after their read back finished.
+// Only the participant whose request led to the tool call can answer it, or anyone if the response wasn't
+// triggered by anyone, and only if the access policy lets them use the tool. Backchannels don't count as a turn.
+func (r *Responder) resolveConfirmationsByVoice(text string, s speaker) {
+	if len(r.confirmations) == 0 {
+		return
+	}
+
+	settings := r.Settings()
+	answer := confirmationAnswer(text)
+	words := normalizedWords(text)
+	if answer == "" && (len(words) == 0 || settings.VoiceUXConfig.bargeInConfig().isBackchannel(words)) {
+		return
+	}
+
+	outcome := ConfirmationUnanswered
+	switch answer {
+	case "yes":
+		outcome = ConfirmationConfirmed
+	case "no":
+		outcome = ConfirmationDeclined
+	}
+
+	for _, confirmation := range append([]*pendingConfirmation(nil), r.confirmations...) {
+		requestedBy := confirmation.response.triggeredBy.userID
+		if !confirmation.answerable || (requestedBy != "" && requestedBy != s.userID) {
+			continue
+		}
+		if settings.AccessPolicy.canUseTool(confirmation.ToolMessage.Name, s) {
+			r.resolveConfirmation(confirmation, outcome, "voice")
+		}
+	}
+}
+
+// expireConfirmations cancels the confirmations that weren't answered in time
+func (r *Responder) expireConfirmations() {
+	now := r.clock.Now()
+	for _, confirmation := range append([]*pendingConfirmation(nil), r.confirmations...) {
+		if !now.Before(confirmation.ExpiresAt) {
+			r.resolveConfirmation(confirmation, ConfirmationTimedOut, "timeout")
+		}
+	}
+}
+
+// resolveConfirmation sends the tool invocation if it was confirmed, and otherwise tells the model it was cancelled
+func (r *Responder) resolveConfirmation(confirmation *pendingConfirmation, outcome string, resolvedBy string) {
+	for i, pending := range r.confirmations {
+		if pending == confirmation {
+			r.confirmations = append(r.confirmations[:i], r.confirmations[i+1:]...)
+			break
+		}
+	}
+
+	if outcome == ConfirmationConfirmed {
+		r.sendToolMessage(confirmation.response, confirmation.ToolMessage)
+	} else {
+		r.Transcript.AddToolCancelledLine(confirmation.ToolMessage.Name, "it was "+outcome)
+	}
+
+	r.SendJSONEvent("confirmation-resolved", ConfirmationResolution{
+		ID:          confirmation.ID,
+		ToolMessage: confirmation.ToolMessage,
+		Outcome:     outcome,
+		ResolvedBy:  resolvedBy,
+	})
+}
+
+var (
+	affirmativeWords = []string{"yes", "yeah", "yep", "yup", "sure", "correct", "confirm", "confirmed", "affirmative", "ok", "okay", "proceed", "absolutely", "definitely"}
+	negativeWords    = []string{"no", "nope", "nah", "cancel", "stop", "don't", "dont", "negative", "wait", "never"}
+)
+
+// confirmationAnswer detects if text is a "yes" or a "no". Anything negative wins, so "yes, no wait" is a no.
+func confirmationAnswer(text string) string {
+	words := normalizedWords(text)
+
+	answer := ""
+	for _, word := range words {
+		for _, negative := range negativeWords {
+			if word == negative {
+				return "no"
+			}
+		}
+		for _, affirmative := range affirmativeWords {
+			if word == affirmative {
+				answer = "yes"
+			}
+		}
+	}
+
+	if answer == "" && strings.Contains(strings.ToLower(text), "go ahead") {
+		answer = "yes"
+	}
+	return answer
+}
+
+// readBack describes a tool invocation in words that can be spoken
+func readBack(toolMessage tools.ToolMessage) string {
+	var input interface{}
+	if err := json.Unmarshal(toolMessage.Input, &input); err != nil {
+		return "use " + toolMessage.Name
+	}
+
+	switch input := input.(type) {
+	case string:
+		return fmt.Sprintf("use %s with %s", toolMessage.Name, input)
+	case map[string]interface{}:
+		if len(input) == 0 {
+			return "use " + toolMessage.Name
+		}
+
+		names := make([]string, 0, len(input))
+		for name := range input {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		parameters := make([]string, 0, len(names))
+		for _, name := range names {
+			parameters = append(parameters, fmt.Sprintf("%s %v", name, input[name]))
+		}
+		return fmt.Sprintf("use %s with %s", toolMessage.Name, strings.Join(parameters, ", "))
+	default:
+		return fmt.Sprintf("use %s with %v", toolMessage.Name, input)
+	}
+}
+
+// newID returns a random ID, for confirmations and jobs
+func newID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
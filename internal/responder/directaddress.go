@@ -0,0 +1,119 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"com.deablabs.teno-voice/internal/transcript"
+)
+
+// DirectAddressConfig tunes the DirectAddress speaking mode, where the bot only answers the lines addressed to it
+type DirectAddressConfig struct {
+	// FollowUpWindow is how many seconds after the bot speaks a question counts as addressed to it, 10 by default
+	FollowUpWindow int `validate:"min=0"`
+	// Classifier has a model decide if each line is addressed to the bot. It falls back to the name and follow-up rules
+	// if the model doesn't answer in time.
+	Classifier bool
+	// ClassifierModel is the model of the classifier, the call's model by default. A small, fast model keeps the bot responsive.
+	ClassifierModel string
+	// ClassifierTimeout is how many milliseconds to wait for the classifier, 1500 by default
+	ClassifierTimeout int `validate:"min=0"`
+}
+
+// How many of the latest transcript lines the classifier sees
+const classifierLines = 8
+
+// addresseeEvent reports if a line was addressed to the bot, as decided by the classifier
+type addresseeEvent struct {
+	// turn is the turn the line ended, so decisions about older lines are ignored
+	turn      int
+	speaker   speaker
+	addressed bool
+}
+
+func (addresseeEvent) isEvent() {}
+
+func (c VoiceUXConfig) directAddressConfig() DirectAddressConfig {
+	config := DirectAddressConfig{}
+	if c.DirectAddress != nil {
+		config = *c.DirectAddress
+	}
+
+	if config.FollowUpWindow == 0 {
+		config.FollowUpWindow = 10
+	}
+	if config.ClassifierTimeout == 0 {
+		config.ClassifierTimeout = 1500
+	}
+	return config
+}
+
+// addressedByRule checks if a line is addressed to the bot, either because it says the bot's name
+// or because it is a question asked shortly after the bot spoke
+func (r *Responder) addressedByRule(e transcriptionEvent, s speaker, settings *Settings) bool {
+	if _, ok := detectWake(e.line, e.botNameConfidence, settings); ok && settings.AccessPolicy.canWake(s) {
+		return true
+	}
+
+	window := time.Duration(settings.VoiceUXConfig.directAddressConfig().FollowUpWindow) * time.Second
+	return !r.lastBotLine.IsZero() && r.clock.Now().Sub(r.lastBotLine) <= window && isQuestion(e.line)
+}
+
+// classifyAddressee has the classifier decide if the line is addressed to the bot, and reports back with an addresseeEvent.
+// Only the event loop calls it.
+func (r *Responder) classifyAddressee(e transcriptionEvent, s speaker, settings *Settings) {
+	config := settings.VoiceUXConfig.directAddressConfig()
+	fallback := r.addressedByRule(e, s, settings)
+	turn := r.turns
+
+	allLines := r.Transcript.GetTranscript()
+	lines := make([]transcript.Line, 0, classifierLines)
+	if len(allLines) > classifierLines {
+		allLines = allLines[len(allLines)-classifierLines:]
+	}
+	lines = append(lines, allLines...)
+
+	r.spawn(func() {
+		ctx, cancel := context.WithTimeout(r.ctx, time.Duration(config.ClassifierTimeout)*time.Millisecond)
+		defer cancel()
+
+		addressed, usageEvent, err := settings.LLMService.IsAddressedToBot(ctx, lines, settings.BotName, config.ClassifierModel)
+		if err != nil {
+			fmt.Printf("Addressee classifier error, falling back to the name and follow-up rules: %v\n", err)
+			addressed = fallback
+		} else {
+			r.sendUsageEvent(usageEvent)
+		}
+
+		r.post(addresseeEvent{
+			turn:      turn,
+			speaker:   s,
+			addressed: addressed,
+		})
+	})
+}
+
+func (r *Responder) handleAddressee(e addresseeEvent) {
+	// Someone spoke again since, so the decision is about a line that isn't the latest anymore
+	if e.turn != r.turns || !e.addressed {
+		return
+	}
+
+	if r.Settings().AccessPolicy.canTriggerResponse(e.speaker) {
+		r.attemptToRespond(true, "addressed", e.speaker)
+	}
+}
+
+var questionWords = []string{"what", "why", "how", "when", "where", "who", "which", "can", "could", "would", "will", "is", "are", "do", "does", "did", "should"}
+
+// isQuestion checks if a line is a question, by its punctuation or its first word
+func isQuestion(text string) bool {
+	if strings.HasSuffix(strings.TrimSpace(text), "?") {
+		return true
+	}
+
+	words := normalizedWords(text)
+	return len(words) > 0 && contains(questionWords, words[0])
+}
@@ -0,0 +1,100 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"com.deablabs.teno-voice/internal/responder/tools"
+)
+
+// EarconConfig has the sounds played when the bot wakes up or falls asleep. The URLs must point to Ogg Opus files.
+type EarconConfig struct {
+	Wake  string `validate:"omitempty,url"`
+	Sleep string `validate:"omitempty,url"`
+}
+
+// How long an earcon can take to download
+const earconTimeout = 10 * time.Second
+
+// earconCache keeps the opus packets of the earcons played in the call by URL, so each is only downloaded once.
+// Failed downloads aren't cached, so they are tried again the next time.
+type earconCache struct {
+	packets map[string][][]byte
+	mu      sync.Mutex
+}
+
+// earcon returns the opus packets of an earcon, downloading it the first time
+func (r *Responder) earcon(ctx context.Context, name string, url string) ([][]byte, error) {
+	cache := &r.earcons
+	cache.mu.Lock()
+	packets, ok := cache.packets[url]
+	cache.mu.Unlock()
+	if ok {
+		return packets, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, earconTimeout)
+	defer cancel()
+
+	audio, err := fetchClip(ctx, tools.Clip{Name: name, URL: url})
+	if err != nil {
+		return nil, err
+	}
+	defer audio.Close()
+
+	buf := make([]byte, 8192)
+	for {
+		n, err := audio.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, append([]byte(nil), buf[:n]...))
+	}
+
+	cache.mu.Lock()
+	if cache.packets == nil {
+		cache.packets = make(map[string][][]byte)
+	}
+	cache.packets[url] = packets
+	cache.mu.Unlock()
+
+	return packets, nil
+}
+
+// playEarcon plays a short sound without going through the response pipeline, so it doesn't interrupt the bot.
+// Speech waits for the earcon to finish, since both are written to the same audio output.
+func (r *Responder) playEarcon(name string, url string) {
+	if url == "" {
+		return
+	}
+
+	r.spawn(func() {
+		packets, err := r.earcon(r.ctx, name, url)
+		if err != nil {
+			fmt.Printf("Error fetching %s earcon: %v\n", name, err)
+			return
+		}
+
+		r.audioOutput.Lock()
+		defer r.audioOutput.Unlock()
+
+		r.setSpeaking(true)
+		for _, packet := range packets {
+			if !r.writeAudio(packet) {
+				return
+			}
+		}
+		r.sendSilentFrames(5)
+
+		// A response may have started speaking while the earcon played
+		if r.State() != StateSpeaking {
+			r.setSpeaking(false)
+		}
+	})
+}
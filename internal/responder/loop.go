@@ -0,0 +1,583 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"com.deablabs.teno-voice/internal/llm/promptbuilder"
+	"com.deablabs.teno-voice/internal/responder/tools"
+	"com.deablabs.teno-voice/internal/transcript"
+	"com.deablabs.teno-voice/internal/usage"
+)
+
+// State is what the responder is doing. Only the event loop changes it.
+type State string
+
+const (
+	// StateIdle is awake and waiting for someone to speak
+	StateIdle State = "idle"
+	// StateListening is while a user is speaking
+	StateListening State = "listening"
+	// StateThinking is while a response is being generated, before any of it plays
+	StateThinking State = "thinking"
+	// StateSpeaking is while a response is playing
+	StateSpeaking State = "speaking"
+	// StateSleeping is asleep and waiting to be woken up
+	StateSleeping State = "sleeping"
+)
+
+// toolRepairReason is the reason of responses that repair rejected tool calls
+const toolRepairReason = "tool repair"
+
+// StateChange is sent on the event stream as a "responder-state" event on every transition
+type StateChange struct {
+	From   State
+	To     State
+	Reason string
+}
+
+// event is an input to the responder's event loop
+type event interface {
+	isEvent()
+}
+
+// interimEvent is a user speaking, with the words heard so far
+type interimEvent struct {
+	text     string
+	duration time.Duration
+	username string
+}
+
+// transcriptionEvent is a user's completed turn
+type transcriptionEvent struct {
+	line              string
+	duration          time.Duration
+	botNameConfidence float64
+	username          string
+	userId            string
+	roles             []string
+	usageEvent        usage.UsageEvent
+}
+
+// respondEvent asks for a response to the transcript
+type respondEvent struct {
+	interruptThinking bool
+	reason            string
+}
+
+// sayEvent asks for the text to be spoken as is
+type sayEvent struct {
+	text string
+}
+
+// interruptEvent asks for the current response to stop
+type interruptEvent struct{}
+
+// speechStartedEvent is sent when the first sentence of a response starts playing
+type speechStartedEvent struct {
+	response *response
+}
+
+// sentenceSpokenEvent is sent when a sentence finished playing, or was cut off
+type sentenceSpokenEvent struct {
+	response    *response
+	sentence    string
+	interrupted bool
+}
+
+// toolMessageEvent is sent as soon as a valid tool message of a response is parsed
+type toolMessageEvent struct {
+	response             *response
+	toolMessage          tools.ToolMessage
+	policy               string
+	requiresConfirmation bool
+}
+
+// responseDoneEvent is sent when every goroutine of a response has finished
+type responseDoneEvent struct {
+	response *response
+}
+
+// tickEvent drives the time based behaviour, like reminding the bot of its tasks
+type tickEvent struct{}
+
+func (interimEvent) isEvent()        {}
+func (transcriptionEvent) isEvent()  {}
+func (respondEvent) isEvent()        {}
+func (sayEvent) isEvent()            {}
+func (interruptEvent) isEvent()      {}
+func (speechStartedEvent) isEvent()  {}
+func (sentenceSpokenEvent) isEvent() {}
+func (toolMessageEvent) isEvent()    {}
+func (responseDoneEvent) isEvent()   {}
+func (tickEvent) isEvent()           {}
+
+// response is one run of the response pipeline
+type response struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	// settings is the snapshot the whole response uses
+	settings *Settings
+	// reason is why the response started
+	reason string
+	// triggeredBy is the participant whose line the response answers, if any
+	triggeredBy speaker
+	// toolRejections are the invalid tool calls of the response. Only its token stream writes them,
+	// and the event loop reads them once the response is done.
+	toolRejections []tools.ToolRejection
+	// interruptedBy is the user who barged in, if anyone did. Only the event loop uses it.
+	interruptedBy string
+	// tasks are the open tasks when the response started
+	tasks []promptbuilder.Task
+	// heldToolMessages wait for the response to finish. Only the event loop uses them.
+	heldToolMessages []toolMessageEvent
+}
+
+// loopState is the state published outside the event loop
+type loopState struct {
+	state             State
+	lastTranscription time.Time
+	awake             bool
+	mu                sync.RWMutex
+}
+
+// State returns what the responder is currently doing
+func (r *Responder) State() State {
+	r.published.mu.RLock()
+	defer r.published.mu.RUnlock()
+
+	return r.published.state
+}
+
+// Awake reports whether the bot is awake, which only changes in the AutoSleep and DirectAddress speaking modes
+func (r *Responder) Awake() bool {
+	r.published.mu.RLock()
+	defer r.published.mu.RUnlock()
+
+	return r.published.awake
+}
+
+// LastTranscription returns when a user last finished a turn
+func (r *Responder) LastTranscription() time.Time {
+	r.published.mu.RLock()
+	defer r.published.mu.RUnlock()
+
+	return r.published.lastTranscription
+}
+
+// post sends an event to the event loop, or drops it if the loop has stopped
+func (r *Responder) post(e event) {
+	select {
+	case r.events <- e:
+	case <-r.loopDone:
+	}
+}
+
+// run is the event loop. It owns the responder's state, and is the only goroutine that reads or changes it.
+func (r *Responder) run(ctx context.Context) {
+	defer close(r.loopDone)
+
+	ticker := r.clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-r.events:
+			r.handle(e)
+		case <-ticker.C():
+			r.handle(tickEvent{})
+		}
+	}
+}
+
+func (r *Responder) handle(e event) {
+	switch e := e.(type) {
+	case interimEvent:
+		r.handleInterim(e)
+	case transcriptionEvent:
+		r.handleTranscription(e)
+	case respondEvent:
+		r.attemptToRespond(e.interruptThinking, e.reason, speaker{})
+	case sayEvent:
+		r.say(e.text, "say")
+	case interruptEvent:
+		if r.response != nil {
+			r.stopResponse()
+			r.transition(r.restingState(), "interrupted")
+		}
+	case speechStartedEvent:
+		if r.response == e.response {
+			r.transition(StateSpeaking, "playing response")
+		}
+	case sentenceSpokenEvent:
+		r.botLineSpoken(e.response.settings.BotName, e.sentence, e.interrupted)
+		if e.interrupted && e.response.interruptedBy != "" {
+			r.Transcript.AddInterruptionLine(e.response.interruptedBy, e.response.settings.BotName)
+		}
+	case toolMessageEvent:
+		r.handleToolMessage(e)
+	case responseDoneEvent:
+		r.handleResponseDone(e)
+	case confirmationsEvent:
+		r.handleConfirmations(e)
+	case resolveConfirmationEvent:
+		r.handleResolveConfirmation(e)
+	case addresseeEvent:
+		r.handleAddressee(e)
+	case syncTasksEvent:
+		r.syncTasks(e.remind)
+	case tasksEvent:
+		e.reply <- r.taskList(false)
+	case scheduleEvent:
+		job, err := r.scheduleJob(e.spec, JobSourceAPI)
+		if err != nil {
+			e.reply <- scheduleReply{err: err}
+		} else {
+			e.reply <- scheduleReply{job: *job}
+		}
+	case jobsEvent:
+		e.reply <- r.jobList()
+	case cancelJobEvent:
+		e.reply <- r.cancelJob(e.id, "")
+	case tickEvent:
+		r.handleTick()
+	}
+}
+
+// say speaks the text as is, replacing the current response
+func (r *Responder) say(text string, reason string) {
+	r.stopResponse()
+	r.startResponse(reason, speaker{}, func(resp *response, sentenceChan chan string) {
+		defer close(sentenceChan)
+		for _, sentence := range splitSentences(text) {
+			select {
+			case <-resp.ctx.Done():
+				return
+			case sentenceChan <- sentence:
+			}
+		}
+	})
+}
+
+func (r *Responder) handleInterim(e interimEvent) {
+	switch r.State() {
+	case StateSpeaking:
+		// Judged with the settings of the response being spoken
+		config := r.response.settings.VoiceUXConfig.bargeInConfig()
+		if config.isBargeIn(e.text, e.duration) {
+			r.response.interruptedBy = e.username
+			r.stopResponse()
+			r.transition(StateListening, "barge-in")
+			return
+		}
+
+		words := normalizedWords(e.text)
+		if config.PauseAndResume && len(words) > 0 && !config.isBackchannel(words) {
+			r.playback.pause(time.Duration(config.ResumeTimeout) * time.Millisecond)
+		}
+	case StateThinking:
+		// The response hasn't started playing, so it's outdated by anything the user says
+		r.stopResponse()
+		r.transition(StateListening, "user speaking")
+	default:
+		r.transition(StateListening, "user speaking")
+	}
+}
+
+func (r *Responder) handleTranscription(e transcriptionEvent) {
+	r.published.mu.Lock()
+	r.published.lastTranscription = r.clock.Now()
+	r.published.mu.Unlock()
+
+	newLine := &transcript.Line{
+		Text:     e.line,
+		Username: e.username,
+		UserId:   e.userId,
+		Type:     "user",
+		Time:     r.clock.Now(),
+	}
+
+	lineSpeaker := speaker{userID: e.userId, roles: e.roles}
+
+	// Speech that doesn't count as a barge-in, like a backchannel, is added to the transcript without stopping the bot
+	if r.State() == StateSpeaking && !r.response.settings.VoiceUXConfig.bargeInConfig().isBargeIn(e.line, e.duration) {
+		r.playback.resume()
+		r.Transcript.AddSpokenLine(newLine)
+		r.sendUsageEvent(e.usageEvent)
+		return
+	}
+
+	if r.response != nil {
+		if r.State() == StateSpeaking {
+			r.response.interruptedBy = e.username
+		}
+		r.stopResponse()
+	}
+
+	r.linesSinceLastResponse++
+	r.turns++
+
+	r.Transcript.AddSpokenLine(newLine)
+	r.resolveConfirmationsByVoice(e.line, lineSpeaker)
+
+	settings := r.Settings()
+	voiceUXConfig := settings.VoiceUXConfig
+
+	// addressed is set when the line is addressed to the bot in the DirectAddress mode, which never keeps the bot awake
+	addressed := false
+
+	switch voiceUXConfig.SpeakingMode {
+	case "NeverSpeak":
+	case "AlwaysSleep":
+		r.sleep()
+	case "DirectAddress":
+		r.sleep()
+		if voiceUXConfig.directAddressConfig().Classifier {
+			r.classifyAddressee(e, lineSpeaker, settings)
+		} else {
+			addressed = r.addressedByRule(e, lineSpeaker, settings)
+		}
+	case "AutoSleep":
+		if r.sleepyAfterLine(voiceUXConfig) {
+			r.sleep()
+		}
+
+		if wake, ok := detectWake(e.line, e.botNameConfidence, settings); ok && settings.AccessPolicy.canWake(lineSpeaker) {
+			wake.UserID = e.userId
+			wake.Username = e.username
+			r.SendJSONEvent("wake", wake)
+
+			r.wakeUp()
+			r.linesSinceLastResponse = 0
+			r.lastEngaged = r.clock.Now()
+		}
+	default: // AlwaysSpeak
+		r.wakeUp()
+	}
+
+	r.transition(r.restingState(), "turn ended")
+
+	// Only respond if the bot is awake or addressed, and to participants whose lines can trigger a response
+	if (r.awake || addressed) && settings.AccessPolicy.canTriggerResponse(lineSpeaker) {
+		r.attemptToRespond(true, "turn ended", lineSpeaker)
+	}
+
+	r.sendUsageEvent(e.usageEvent)
+}
+
+func (r *Responder) handleResponseDone(e responseDoneEvent) {
+	r.lastResponseEnd = r.clock.Now()
+
+	// Held tool messages are sent now that the speech is over. If the response was interrupted or replaced,
+	// the ones whose policy cancels them on interruption are dropped, and the bot is told so it can call them
+	// again if they still apply.
+	completed := e.response.ctx.Err() == nil
+	var toConfirm []tools.ToolMessage
+	for _, held := range e.response.heldToolMessages {
+		if !completed && held.policy == tools.CancelOnInterrupt {
+			r.Transcript.AddToolCancelledLine(held.toolMessage.Name, "your response was interrupted")
+			continue
+		}
+
+		if held.requiresConfirmation {
+			toConfirm = append(toConfirm, held.toolMessage)
+		} else {
+			r.sendToolMessage(e.response, held.toolMessage)
+		}
+	}
+	e.response.heldToolMessages = nil
+	e.response.cancel()
+
+	if r.response == e.response {
+		r.response = nil
+		r.transition(r.restingState(), "response finished")
+	}
+
+	if completed {
+		r.repairToolCalls(e.response)
+	}
+
+	r.readBackDone(e.response)
+	r.requestConfirmations(e.response, toConfirm)
+}
+
+// handleToolMessage sends a tool message right away if its tool fires immediately,
+// and otherwise holds it until the response is done. Tool messages that require confirmation are always held,
+// so they are read back once the bot stops speaking.
+// A response that was interrupted or replaced may still have parsed tool calls before it stopped, and they are
+// handled the same way: the ones that fire immediately were committed when they were parsed.
+func (r *Responder) handleToolMessage(e toolMessageEvent) {
+	if e.policy == tools.FireImmediately && !e.requiresConfirmation {
+		r.sendToolMessage(e.response, e.toolMessage)
+		return
+	}
+
+	e.response.heldToolMessages = append(e.response.heldToolMessages, e)
+}
+
+// sendToolMessage sends a tool message on the event stream, and records it in the transcript.
+// Built-in tools are executed instead.
+func (r *Responder) sendToolMessage(resp *response, toolMessage tools.ToolMessage) {
+	if resp.settings.PromptContents.BuiltinTools.IsBuiltin(toolMessage.Name) {
+		r.executeBuiltinTool(resp, toolMessage)
+		return
+	}
+
+	toolMessageJson, err := json.Marshal([]tools.ToolMessage{toolMessage})
+	if err != nil {
+		fmt.Printf("Error marshalling tool message: %v\n", err)
+		return
+	}
+
+	select {
+	case r.toolMessagesSSEChannel <- SSEMessage{
+		Type: "tool-message",
+		Data: string(toolMessageJson),
+	}:
+		r.Transcript.AddToolMessageLine(string(toolMessageJson))
+	default:
+	}
+}
+
+// repairToolCalls tells the model why its tool calls were rejected and has it respond again.
+// A repair is only attempted once, so a response to a repair is never repaired.
+func (r *Responder) repairToolCalls(resp *response) {
+	if len(resp.toolRejections) == 0 || !resp.settings.PromptContents.RepairToolCalls || resp.reason == toolRepairReason {
+		return
+	}
+
+	for _, rejection := range resp.toolRejections {
+		r.Transcript.AddToolRejectionLine(rejection.Name, rejection.Reason)
+	}
+	r.attemptToRespond(false, toolRepairReason, resp.triggeredBy)
+}
+
+// handleTick puts the bot to sleep when its awake window ends, runs scheduled jobs, reports overdue tasks,
+// and reminds the bot of its open tasks in turn when nothing has been said for their reminder interval
+func (r *Responder) handleTick() {
+	r.expireConfirmations()
+
+	settings := r.Settings()
+
+	// The awake window can end while nobody is talking
+	if settings.VoiceUXConfig.SpeakingMode == "AutoSleep" && r.response == nil && r.awakeWindowElapsed(settings.VoiceUXConfig) {
+		r.sleep()
+		if r.State() == StateIdle {
+			r.transition(r.restingState(), "awake window ended")
+		}
+	}
+
+	now := r.clock.Now()
+	r.checkOverdueTasks(now)
+
+	if r.runDueJob(now) {
+		return
+	}
+
+	if task := r.nextReminder(settings.VoiceUXConfig, now); task != nil {
+		r.lastAutoRespond = now
+		r.remindOfTask(task)
+		r.attemptToRespond(false, "task reminder", speaker{})
+	}
+}
+
+func (r *Responder) attemptToRespond(interruptThinking bool, reason string, triggeredBy speaker) {
+	if r.Settings().VoiceUXConfig.SpeakingMode == "NeverSpeak" || r.clock.Now().Before(r.mutedUntil) {
+		return
+	}
+
+	switch r.State() {
+	case StateListening, StateSpeaking:
+		return
+	case StateThinking:
+		if !interruptThinking {
+			return
+		}
+		r.stopResponse()
+	}
+
+	r.startResponse(reason, triggeredBy, r.getTokenStream)
+}
+
+// stopResponse cancels the current response, if any. Its goroutines still report back as they finish.
+func (r *Responder) stopResponse() {
+	if r.response != nil {
+		r.response.cancel()
+		r.response = nil
+	}
+}
+
+func (r *Responder) transition(to State, reason string) {
+	r.published.mu.Lock()
+	from := r.published.state
+	r.published.state = to
+	r.published.mu.Unlock()
+
+	if from == to {
+		return
+	}
+
+	r.SendJSONEvent("responder-state", StateChange{
+		From:   from,
+		To:     to,
+		Reason: reason,
+	})
+}
+
+func (r *Responder) publishAwake(awake bool) {
+	r.published.mu.Lock()
+	r.published.awake = awake
+	r.published.mu.Unlock()
+}
+
+// restingState is the state to return to when nobody is speaking and there is no response
+func (r *Responder) restingState() State {
+	if r.awake {
+		return StateIdle
+	}
+	return StateSleeping
+}
+
+func (r *Responder) wakeUp() {
+	if r.awake {
+		return
+	}
+	r.awake = true
+	r.publishAwake(true)
+	r.lastEngaged = r.clock.Now()
+	r.SendEvent("state", "Awake")
+
+	if earcons := r.Settings().VoiceUXConfig.Earcons; earcons != nil {
+		r.playEarcon("wake", earcons.Wake)
+	}
+}
+
+func (r *Responder) sleep() {
+	if !r.awake {
+		return
+	}
+	r.awake = false
+	r.publishAwake(false)
+	r.SendEvent("state", "Asleep")
+
+	if earcons := r.Settings().VoiceUXConfig.Earcons; earcons != nil {
+		r.playEarcon("sleep", earcons.Sleep)
+	}
+}
+
+// sleepyAfterLine checks if the bot should fall asleep in AutoSleep after a line that didn't address it,
+// because too many lines went by without a response or the awake window ended
+func (r *Responder) sleepyAfterLine(config VoiceUXConfig) bool {
+	linesExceeded := (config.LinesBeforeSleep > 0 || config.AwakeWindow == 0) && r.linesSinceLastResponse > config.LinesBeforeSleep
+	return linesExceeded || r.awakeWindowElapsed(config)
+}
+
+// awakeWindowElapsed checks if the bot went AwakeWindow seconds without being addressed or finishing a line
+func (r *Responder) awakeWindowElapsed(config VoiceUXConfig) bool {
+	return config.AwakeWindow > 0 && r.clock.Now().Sub(r.lastEngaged) >= time.Duration(config.AwakeWindow)*time.Second
+}
@@ -0,0 +1,569 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"com.deablabs.teno-voice/internal/llm"
+	"com.deablabs.teno-voice/internal/llm/completion"
+	"com.deablabs.teno-voice/internal/llm/promptbuilder"
+	"com.deablabs.teno-voice/internal/responder/tools"
+	"com.deablabs.teno-voice/internal/transcript"
+	"com.deablabs.teno-voice/internal/usage"
+	"github.com/disgoorg/disgo/voice"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeClock only moves when advanced, and its ticker only ticks when told to
+type fakeClock struct {
+	now    time.Time
+	mu     sync.Mutex
+	ticker *fakeTicker
+}
+
+type fakeTicker struct {
+	c chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:    time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC),
+		ticker: &fakeTicker{c: make(chan time.Time)},
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	return c.ticker
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {}
+
+// fakeLLM responds with the same tokens every time
+type fakeLLM struct {
+	llm.LLMService
+	tokens []string
+}
+
+func (f *fakeLLM) GetTranscriptResponseStream(ctx context.Context, transcript *transcript.Transcript, promptContents *promptbuilder.PromptContents, data promptbuilder.TemplateData) (completion.Stream, usage.LLMEvent, error) {
+	return &fakeStream{tokens: f.tokens}, usage.LLMEvent{}, nil
+}
+
+func (f *fakeLLM) NativeToolCalls() bool {
+	return false
+}
+
+type fakeStream struct {
+	tokens []string
+}
+
+func (s *fakeStream) Recv() (completion.Delta, error) {
+	if len(s.tokens) == 0 {
+		return completion.Delta{}, io.EOF
+	}
+	token := s.tokens[0]
+	s.tokens = s.tokens[1:]
+	return completion.Delta{Content: token}, nil
+}
+
+func (s *fakeStream) Close() {}
+
+// fakeTTS hands out the audio of each sentence it synthesizes, so the test decides when the audio plays and ends
+type fakeTTS struct {
+	audio chan *fakeAudio
+	done  chan struct{}
+}
+
+func (f *fakeTTS) Synthesize(text string) (io.ReadCloser, usage.UsageEvent, error) {
+	audio := &fakeAudio{
+		header: make([]byte, 1700),
+		frames: make(chan []byte),
+		done:   f.done,
+	}
+	f.audio <- audio
+	return audio, usage.NewTextToSpeechEvent("fake", "fake", len(text)), nil
+}
+
+// fakeAudio starts with enough bytes for the ones discarded from the start of each sentence,
+// then reads the frames sent to it until frames is closed
+type fakeAudio struct {
+	header []byte
+	frames chan []byte
+	done   <-chan struct{}
+}
+
+func (a *fakeAudio) Read(p []byte) (int, error) {
+	if a.header != nil {
+		n := copy(p, a.header)
+		a.header = nil
+		return n, nil
+	}
+
+	select {
+	case frame, ok := <-a.frames:
+		if !ok {
+			return 0, io.EOF
+		}
+		return copy(p, frame), nil
+	case <-a.done:
+		return 0, io.EOF
+	}
+}
+
+func (a *fakeAudio) Close() error {
+	return nil
+}
+
+// play plays a frame of the sentence, and then the rest of it
+func (a *fakeAudio) play() {
+	a.frames <- []byte{1, 2, 3}
+	close(a.frames)
+}
+
+type fakeConn struct{}
+
+func (fakeConn) SetSpeaking(ctx context.Context, flags voice.SpeakingFlags) error {
+	return nil
+}
+
+type testResponder struct {
+	*Responder
+	clock  *fakeClock
+	tts    *fakeTTS
+	events chan SSEMessage
+}
+
+func newTestResponder(t *testing.T, voiceUXConfig VoiceUXConfig, tasks []promptbuilder.Task) *testResponder {
+	clock := newFakeClock()
+	tts := &fakeTTS{
+		audio: make(chan *fakeAudio, 10),
+		done:  make(chan struct{}),
+	}
+	events := make(chan SSEMessage, 256)
+	playAudio := make(chan []byte)
+
+	r := NewResponder(context.Background(), NewResponderArgs{
+		Settings: &Settings{
+			BotName:       "Teno",
+			VoiceUXConfig: voiceUXConfig,
+			PromptContents: promptbuilder.PromptContents{
+				BotPrimer: "You are Teno.",
+				Tasks:     tasks,
+			},
+			TTSService: tts,
+			LLMService: &fakeLLM{tokens: []string{"Sure", ",", " it's", " noon."}},
+		},
+		PlayAudioChannel:       playAudio,
+		Conn:                   fakeConn{},
+		TranscriptSSEChannel:   make(chan string, 100),
+		ToolMessagesSSEChannel: events,
+		UsageSSEChannel:        make(chan string, 100),
+		RedisClient:            &redis.Client{},
+		TranscriptConfig:       transcript.TranscriptConfig{NumberOfTranscriptLines: 50},
+		FramesWritten:          new(atomic.Int64),
+		Clock:                  clock,
+	})
+
+	// The voice connection takes every frame played
+	go func() {
+		for range playAudio {
+		}
+	}()
+
+	t.Cleanup(func() {
+		close(tts.done)
+		r.Cleanup()
+	})
+
+	return &testResponder{Responder: r, clock: clock, tts: tts, events: events}
+}
+
+// tick ticks the clock, and returns once the event loop has handled the tick
+func (r *testResponder) tick() {
+	r.clock.ticker.c <- r.clock.Now()
+	// Tasks replies once the loop handled everything before it
+	r.Tasks()
+}
+
+// speak has a participant say a line, with interim results first like the transcriber sends
+func (r *testResponder) speak(line string) {
+	r.InterimTranscriptionReceived(line, time.Second, "alice")
+	r.NewTranscription(line, time.Second, 0, "alice", "1", usage.NewTranscriptionEvent("fake", "fake", 0))
+}
+
+// nextAudio returns the audio of the next sentence the bot synthesizes
+func (r *testResponder) nextAudio(t *testing.T) *fakeAudio {
+	t.Helper()
+
+	select {
+	case audio := <-r.tts.audio:
+		return audio
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a sentence to be synthesized")
+		return nil
+	}
+}
+
+// expectEvents checks the next state, wake, tool message and job events, in order
+func (r *testResponder) expectEvents(t *testing.T, want ...string) {
+	t.Helper()
+
+	for _, expected := range want {
+		if got := r.nextEvent(t); got != expected {
+			t.Fatalf("got event %q, want %q", got, expected)
+		}
+	}
+}
+
+func (r *testResponder) nextEvent(t *testing.T) string {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case e := <-r.events:
+			switch e.Type {
+			case "responder-state":
+				var change StateChange
+				if err := json.Unmarshal([]byte(e.Data), &change); err != nil {
+					t.Fatalf("invalid responder-state event %s: %v", e.Data, err)
+				}
+				return fmt.Sprintf("%s -> %s (%s)", change.From, change.To, change.Reason)
+			case "state":
+				return "state " + e.Data
+			case "tool-message":
+				var toolMessages []tools.ToolMessage
+				if err := json.Unmarshal([]byte(e.Data), &toolMessages); err != nil || len(toolMessages) != 1 {
+					t.Fatalf("invalid tool-message event %s: %v", e.Data, err)
+				}
+				return "tool-message " + toolMessages[0].Name
+			case "job":
+				var update JobUpdate
+				if err := json.Unmarshal([]byte(e.Data), &update); err != nil {
+					t.Fatalf("invalid job event %s: %v", e.Data, err)
+				}
+				return fmt.Sprintf("job %s %s", update.Job.Name, update.Reason)
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for an event")
+			return ""
+		}
+	}
+}
+
+func TestLoopStates(t *testing.T) {
+	r := newTestResponder(t, VoiceUXConfig{SpeakingMode: "AutoSleep", AwakeWindow: 30}, nil)
+
+	r.speak("Teno, what time is it?")
+	r.expectEvents(t,
+		"idle -> listening (user speaking)",
+		"listening -> idle (turn ended)",
+		"idle -> thinking (turn ended)",
+		"thinking -> speaking (playing response)",
+	)
+
+	r.nextAudio(t).play()
+	r.expectEvents(t, "speaking -> idle (response finished)")
+
+	// The bot stays awake until the awake window passes without it being addressed
+	r.clock.advance(29 * time.Second)
+	r.tick()
+	if !r.Awake() {
+		t.Fatal("the bot fell asleep before its awake window ended")
+	}
+
+	r.clock.advance(time.Second)
+	r.tick()
+	r.expectEvents(t,
+		"state Asleep",
+		"idle -> sleeping (awake window ended)",
+	)
+
+	r.speak("Teno, are you there?")
+	r.expectEvents(t,
+		"sleeping -> listening (user speaking)",
+		"state Awake",
+		"listening -> idle (turn ended)",
+		"idle -> thinking (turn ended)",
+		"thinking -> speaking (playing response)",
+	)
+}
+
+func TestLoopStaysAsleep(t *testing.T) {
+	r := newTestResponder(t, VoiceUXConfig{SpeakingMode: "AutoSleep", AwakeWindow: 30}, nil)
+
+	r.clock.advance(30 * time.Second)
+	r.tick()
+	r.expectEvents(t,
+		"state Asleep",
+		"idle -> sleeping (awake window ended)",
+	)
+
+	// Lines that don't wake the bot don't get a response
+	r.speak("what time is it?")
+	r.expectEvents(t,
+		"sleeping -> listening (user speaking)",
+		"listening -> sleeping (turn ended)",
+	)
+	if state := r.State(); state != StateSleeping {
+		t.Fatalf("state is %s, want %s", state, StateSleeping)
+	}
+}
+
+func TestLoopBargeIn(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *BargeInConfig
+		// ignored is said over the bot before the barge-in, and doesn't interrupt it
+		ignored string
+		bargeIn string
+	}{
+		{
+			name:    "any speech without a policy",
+			bargeIn: "mm-hmm",
+		},
+		{
+			name:    "backchannel",
+			config:  &BargeInConfig{Backchannels: []string{"mm-hmm"}},
+			ignored: "mm-hmm",
+			bargeIn: "wait",
+		},
+		{
+			name:    "too few words",
+			config:  &BargeInConfig{MinWords: 3},
+			ignored: "wait",
+			bargeIn: "wait a second",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestResponder(t, VoiceUXConfig{SpeakingMode: "AlwaysSpeak", BargeIn: test.config}, nil)
+
+			r.Say("Let me tell you a story.")
+			r.expectEvents(t,
+				"idle -> thinking (say)",
+				"thinking -> speaking (playing response)",
+			)
+			audio := r.nextAudio(t)
+
+			if test.ignored != "" {
+				r.InterimTranscriptionReceived(test.ignored, 300*time.Millisecond, "alice")
+				// Wait for the loop to handle it
+				r.Tasks()
+				if state := r.State(); state != StateSpeaking {
+					t.Fatalf("state is %s after %q, want %s", state, test.ignored, StateSpeaking)
+				}
+			}
+
+			r.InterimTranscriptionReceived(test.bargeIn, 300*time.Millisecond, "alice")
+			r.expectEvents(t, "speaking -> listening (barge-in)")
+
+			// The rest of the sentence is cut off
+			audio.frames <- []byte{1, 2, 3}
+		})
+	}
+}
+
+func TestLoopTaskReminder(t *testing.T) {
+	tasks := []promptbuilder.Task{{
+		Name:             "Take notes",
+		Description:      "Write down the action items",
+		DeliverableGuide: "A list",
+	}}
+	r := newTestResponder(t, VoiceUXConfig{SpeakingMode: "AlwaysSpeak", AutoRespondInterval: 60}, tasks)
+
+	r.clock.advance(59 * time.Second)
+	r.tick()
+	if state := r.State(); state != StateIdle {
+		t.Fatalf("state is %s before the reminder interval passed, want %s", state, StateIdle)
+	}
+
+	r.clock.advance(time.Second)
+	r.tick()
+	r.expectEvents(t,
+		"idle -> thinking (task reminder)",
+		"thinking -> speaking (playing response)",
+	)
+
+	r.nextAudio(t).play()
+	r.expectEvents(t, "speaking -> idle (response finished)")
+}
+
+func TestLoopScheduledJob(t *testing.T) {
+	r := newTestResponder(t, VoiceUXConfig{SpeakingMode: "AlwaysSpeak"}, nil)
+
+	if _, err := r.Schedule(JobSpec{Name: "standup", Say: "Time for standup.", Every: 60}); err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	r.expectEvents(t, "job standup scheduled")
+
+	r.clock.advance(60 * time.Second)
+	r.tick()
+	r.expectEvents(t,
+		"job standup ran",
+		"idle -> thinking (scheduled job)",
+		"thinking -> speaking (playing response)",
+	)
+
+	// Due jobs wait for the bot to stop speaking
+	r.clock.advance(60 * time.Second)
+	r.tick()
+	r.nextAudio(t).play()
+	r.expectEvents(t, "speaking -> idle (response finished)")
+
+	r.tick()
+	r.expectEvents(t,
+		"job standup ran",
+		"idle -> thinking (scheduled job)",
+	)
+}
+
+func TestLoopToolPolicies(t *testing.T) {
+	tests := []struct {
+		name        string
+		interrupted bool
+		want        []string
+	}{
+		{"finished", false, []string{"tool-message createTicket", "tool-message logNote"}},
+		// Only the tool that cancels on interruption is dropped
+		{"interrupted", true, []string{"tool-message createTicket"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestResponder(t, VoiceUXConfig{SpeakingMode: "AlwaysSpeak"}, nil)
+			r.UpdateSettings(func(settings *Settings) error {
+				settings.PromptContents.Tools = []tools.Tool{
+					{Name: "createTicket", Policy: tools.FireAfterSpeech},
+					{Name: "logNote", Policy: tools.CancelOnInterrupt},
+				}
+				// The tool calls come with the '|', so they are parsed before the speech starts
+				settings.LLMService = &fakeLLM{tokens: []string{
+					"On", " it.", `|[{"name": "createTicket", "input": "printer"}, {"name": "logNote", "input": "printer"}]`,
+				}}
+				return nil
+			})
+
+			r.AttemptToRespond(false)
+			r.expectEvents(t,
+				"idle -> thinking (requested)",
+				"thinking -> speaking (playing response)",
+			)
+			audio := r.nextAudio(t)
+
+			if test.interrupted {
+				r.InterimTranscriptionReceived("wait", 300*time.Millisecond, "alice")
+				r.expectEvents(t, "speaking -> listening (barge-in)")
+				audio.frames <- []byte{1, 2, 3}
+				r.expectEvents(t, test.want...)
+			} else {
+				audio.play()
+				r.expectEvents(t, append(test.want, "speaking -> idle (response finished)")...)
+			}
+
+			// Wait for the loop to handle the end of the response
+			r.Tasks()
+			for len(r.events) > 0 {
+				if e := <-r.events; e.Type == "tool-message" {
+					t.Fatalf("unexpected tool message %s", e.Data)
+				}
+			}
+
+			cancelled := strings.Contains(r.Transcript.GetTranscriptString(), "Your call to the tool 'logNote' was cancelled")
+			if cancelled != test.interrupted {
+				t.Fatalf("logNote cancelled in the transcript is %v, want %v", cancelled, test.interrupted)
+			}
+		})
+	}
+}
+
+func TestLoopConfirmationByVoice(t *testing.T) {
+	r := newTestResponder(t, VoiceUXConfig{
+		SpeakingMode: "AlwaysSpeak",
+		BargeIn:      &BargeInConfig{Backchannels: []string{"ok", "mm-hmm"}},
+	}, nil)
+	r.UpdateSettings(func(settings *Settings) error {
+		settings.PromptContents.Tools = []tools.Tool{{Name: "deleteTicket", RequiresConfirmation: true}}
+		settings.LLMService = &fakeLLM{tokens: []string{"Deleting", " it.", `|[{"name": "deleteTicket", "input": "42"}]`}}
+		return nil
+	})
+
+	r.speak("Delete ticket 42")
+	r.expectEvents(t,
+		"idle -> listening (user speaking)",
+		"listening -> idle (turn ended)",
+		"idle -> thinking (turn ended)",
+		"thinking -> speaking (playing response)",
+	)
+	r.nextAudio(t).play()
+	r.expectEvents(t,
+		"speaking -> idle (response finished)",
+		"idle -> thinking (confirmation)",
+		"thinking -> speaking (playing response)",
+	)
+	readBack := r.nextAudio(t)
+
+	// The bot doesn't respond to the answers, so only the confirmation handles them
+	r.UpdateSettings(func(settings *Settings) error {
+		settings.VoiceUXConfig.SpeakingMode = "NeverSpeak"
+		return nil
+	})
+
+	line := func(userId string, text string) {
+		r.NewTranscription(text, time.Second, 0, "user "+userId, userId, usage.NewTranscriptionEvent("fake", "fake", 0))
+	}
+	pending := func(want int) {
+		t.Helper()
+		if got := len(r.PendingConfirmations()); got != want {
+			t.Fatalf("%d pending confirmations, want %d", got, want)
+		}
+	}
+
+	// Said over the read back
+	line("1", "ok")
+	pending(1)
+
+	// The read back is two sentences
+	readBack.play()
+	r.nextAudio(t).play()
+	r.expectEvents(t, "speaking -> idle (response finished)")
+
+	// A backchannel isn't an answer, and only the participant who asked can answer
+	line("1", "mm-hmm")
+	pending(1)
+	line("2", "yes")
+	pending(1)
+
+	line("1", "yes")
+	pending(0)
+	for len(r.events) > 0 {
+		if e := <-r.events; e.Type == "tool-message" {
+			return
+		}
+	}
+	t.Fatal("the confirmed tool message wasn't sent")
+}
@@ -0,0 +1,57 @@
+package responder
+
+import (
+	"context"
+	"time"
+
+	"com.deablabs.teno-voice/internal/llm/promptbuilder"
+)
+
+// templateData gathers the variables prompt templates can use at the moment
+func (r *Responder) templateData(settings *Settings) promptbuilder.TemplateData {
+	now := r.clock.Now()
+
+	var participants []promptbuilder.Participant
+	if r.participants != nil {
+		participants = r.participants()
+	}
+
+	return promptbuilder.TemplateData{
+		BotName:      settings.BotName,
+		Now:          now,
+		CallDuration: now.Sub(r.startTime).Round(time.Second),
+		Participants: participants,
+		Awake:        r.Awake(),
+	}
+}
+
+// promptContents returns the prompt contents for a response: the settings' with the open tasks in place of the
+// configured ones and, with retrieval on, the retrieved chunks in place of the documents, which are returned for a retrieval event.
+// The query is only embedded with embedQuery.
+func (r *Responder) promptContents(ctx context.Context, settings *Settings, tasks []promptbuilder.Task, embedQuery bool) (*promptbuilder.PromptContents, *RetrievalEvent) {
+	contents := settings.PromptContents
+	if contents.Tasks != nil {
+		contents.Tasks = tasks
+	}
+
+	if contents.Retrieval == nil || contents.Documents == nil {
+		return &contents, nil
+	}
+	return &contents, r.retrieveDocuments(ctx, settings, &contents, embedQuery)
+}
+
+// RenderPrompt builds the prompt the bot would respond to now, without calling the LLM.
+// Documents are retrieved with BM25, so rendering never pays for embeddings.
+func (r *Responder) RenderPrompt(ctx context.Context) promptbuilder.RenderedPrompt {
+	settings := r.Settings()
+
+	tasks := make([]promptbuilder.Task, 0)
+	for _, task := range r.Tasks() {
+		if task.Status == promptbuilder.TaskPending || task.Status == promptbuilder.TaskInProgress {
+			tasks = append(tasks, task)
+		}
+	}
+
+	promptContents, _ := r.promptContents(ctx, settings, tasks, false)
+	return settings.LLMService.RenderPrompt(r.Transcript, promptContents, r.templateData(settings))
+}
@@ -1,7 +1,9 @@
 package responder
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -10,14 +12,15 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 
-	"com.deablabs.teno-voice/internal/llm"
+	"com.deablabs.teno-voice/internal/llm/completion"
 	"com.deablabs.teno-voice/internal/llm/promptbuilder"
-
 	"com.deablabs.teno-voice/internal/responder/tools"
 	texttospeech "com.deablabs.teno-voice/internal/textToSpeech"
+	"com.deablabs.teno-voice/internal/textToSpeech/wordtimings"
 	"com.deablabs.teno-voice/internal/transcript"
 	"com.deablabs.teno-voice/internal/usage"
 	"github.com/disgoorg/disgo/voice"
@@ -34,17 +37,37 @@ type VoiceUXConfig struct {
 	SpeakingMode               string `validate:"required"`
 	LinesBeforeSleep           int
 	BotNameConfidenceThreshold float64
-	AutoRespondInterval        int
+	// BotNameAliases are other names and spellings the bot wakes to, like the ways the transcriber tends to hear its name
+	BotNameAliases []string
+	// FuzzyWakeThreshold is how sure the bot must be that its name was said to wake up, from 0 to 1. How closely the line
+	// sounds like the bot name or an alias is combined with the transcriber's search confidence, and checked against it
+	// instead of BotNameConfidenceThreshold. Around 0.9 catches near misses like "Tenno" for "Teno".
+	// 0 turns fuzzy matching off, so only exact names and the search confidence wake it.
+	FuzzyWakeThreshold  float64 `validate:"min=0,max=1"`
+	AutoRespondInterval int
+	// BargeIn decides when speech interrupts the bot. Without it, any speech does.
+	BargeIn *BargeInConfig
+	// ConfirmationTimeout is how many seconds a tool invocation waits to be confirmed, 20 by default
+	ConfirmationTimeout int `validate:"min=0"`
+	// DirectAddress tunes the DirectAddress speaking mode
+	DirectAddress *DirectAddressConfig
+	// AwakeWindow is how many seconds the bot stays awake in AutoSleep without being addressed or responding.
+	// It combines with LinesBeforeSleep, so the bot falls asleep on whichever comes first, and is the only limit
+	// when LinesBeforeSleep is 0. 0 disables it.
+	AwakeWindow int `validate:"min=0"`
+	// Earcons are played when the bot wakes up or falls asleep
+	Earcons *EarconConfig
+}
+
+// VoiceConnection is the part of the call's voice connection used by the responder
+type VoiceConnection interface {
+	SetSpeaking(ctx context.Context, flags voice.SpeakingFlags) error
 }
 
 type NewResponderArgs struct {
-	BotName                string
+	Settings               *Settings
 	PlayAudioChannel       chan []byte
-	Conn                   *voice.Conn
-	TTSService             *texttospeech.TextToSpeechService
-	LLMService             *llm.LLMService
-	VoiceUXConfig          VoiceUXConfig
-	PromptContents         *promptbuilder.PromptContents
+	Conn                   VoiceConnection
 	TranscriptSSEChannel   chan string
 	ToolMessagesSSEChannel chan SSEMessage
 	UsageSSEChannel        chan string
@@ -52,169 +75,305 @@ type NewResponderArgs struct {
 	RedisTranscriptKey     string
 	TranscriptConfig       transcript.TranscriptConfig
 	BotId                  snowflake.ID
+	// FramesWritten counts the opus frames written to the voice connection
+	FramesWritten *atomic.Int64
+	// Clock defaults to the system clock
+	Clock Clock
+	// Leave ends the call, for the LeaveCall built-in tool
+	Leave func()
+	// MemberRoles looks up the Discord roles of a participant, for the access policy
+	MemberRoles func(userID string) []string
+	// Participants lists the people in the voice channel, for prompt templates
+	Participants func() []promptbuilder.Participant
+	// SettingsChanged is called after a built-in tool changes the settings, so the call's config can follow them
+	SettingsChanged func(r *Responder)
 }
 
+// Responder decides when the bot speaks. Its state is owned by an event loop: the exported methods
+// send events to the loop, and the response pipeline reports back to it with events too.
 type Responder struct {
-	BotName                string
-	Transcript             *transcript.Transcript
-	playAudioChannel       chan []byte
-	conn                   voice.Conn
-	TtsService             texttospeech.TextToSpeechService
-	LlmService             llm.LLMService
-	cancelResponse         context.CancelFunc
-	awake                  bool
-	linesSinceLastResponse int
-	VoiceUXConfig          VoiceUXConfig
-	PromptContents         promptbuilder.PromptContents
+	Transcript       *transcript.Transcript
+	playAudioChannel chan []byte
+	conn             VoiceConnection
+	framesWritten    *atomic.Int64
+	settings         atomic.Pointer[Settings]
+	// settingsMutex is held while the settings are replaced, so their updates don't race
+	settingsMutex          sync.Mutex
 	botId                  snowflake.ID
 	toolMessagesSSEChannel chan SSEMessage
 	usageSSEChannel        chan string
-	isSpeaking             bool
-	isResponding           bool
-	userSpeaking           bool
-	LastResponseEnd        time.Time
+	playback               playbackGate
+	clock                  Clock
+	events                 chan event
+	// ctx lasts until the call ends, and every response and background job of the responder derives from it
+	ctx      context.Context
+	stopLoop context.CancelFunc
+	loopDone chan struct{}
+	// goroutines tracks the goroutines that can still write to the channels closed by Cleanup
+	goroutines   sync.WaitGroup
+	published    loopState
+	leave        func()
+	memberRoles  func(userID string) []string
+	participants func() []promptbuilder.Participant
+	startTime    time.Time
+	// settingsChanged is nil if nothing needs to know about the changes built-in tools make to the settings
+	settingsChanged func(r *Responder)
+	// audioOutput is held while writing earcons, so speech doesn't interleave with them
+	audioOutput sync.Mutex
+	earcons     earconCache
+	// documentIndex is the retrieval index over the documents
+	documentIndex documentIndex
+
+	// Owned by the event loop
+	response               *response
+	awake                  bool
+	linesSinceLastResponse int
+	lastResponseEnd        time.Time
+	lastAutoRespond        time.Time
+	mutedUntil             time.Time
+	confirmations          []*pendingConfirmation
+	// turns counts the lines transcribed, so late decisions about a line can tell if it is still the latest
+	turns int
+	// lastBotLine is when the bot last said something
+	lastBotLine time.Time
+	// lastEngaged is when the bot was last woken up or finished a line, for the awake window
+	lastEngaged time.Time
+	// tasks are the tasks of the settings with their statuses
+	tasks []*trackedTask
+	// jobs are the scheduled jobs, in the order they were scheduled
+	jobs []*Job
 }
 
 type audioStreamWithIndex struct {
 	index       int
 	opusPackets io.ReadCloser
 	sentence    string
+	// wordTimings is nil when the TTS service doesn't report them
+	wordTimings *wordtimings.WordTimings
 }
 
+// Each opus frame is 20ms of audio
+const opusFrameDuration = 20 * time.Millisecond
+
 func NewResponder(ctx context.Context, args NewResponderArgs) *Responder {
+	clock := args.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+
+	loopCtx, stopLoop := context.WithCancel(ctx)
+
 	responder := &Responder{
-		BotName:                args.BotName,
 		playAudioChannel:       args.PlayAudioChannel,
-		conn:                   *args.Conn,
+		conn:                   args.Conn,
+		framesWritten:          args.FramesWritten,
 		Transcript:             transcript.NewTranscript(args.TranscriptSSEChannel, args.RedisClient, args.RedisTranscriptKey, args.TranscriptConfig),
-		TtsService:             *args.TTSService,
-		LlmService:             *args.LLMService,
-		VoiceUXConfig:          args.VoiceUXConfig,
-		PromptContents:         *args.PromptContents,
-		cancelResponse:         nil,
-		awake:                  true,
-		linesSinceLastResponse: 0,
 		botId:                  args.BotId,
 		toolMessagesSSEChannel: args.ToolMessagesSSEChannel,
 		usageSSEChannel:        args.UsageSSEChannel,
-		isSpeaking:             false,
-		isResponding:           false,
-		userSpeaking:           false,
-		LastResponseEnd:        time.Now(),
-	}
-
-	go responder.AutoRespond(ctx)
+		clock:                  clock,
+		events:                 make(chan event, 64),
+		ctx:                    loopCtx,
+		stopLoop:               stopLoop,
+		loopDone:               make(chan struct{}),
+		leave:                  args.Leave,
+		memberRoles:            args.MemberRoles,
+		participants:           args.Participants,
+		settingsChanged:        args.SettingsChanged,
+		startTime:              clock.Now(),
+		published: loopState{
+			state:             StateIdle,
+			lastTranscription: clock.Now(),
+			awake:             true,
+		},
+		awake:           true,
+		lastResponseEnd: clock.Now(),
+		lastEngaged:     clock.Now(),
+	}
+
+	responder.SetSettings(args.Settings)
+	responder.syncTasks(false)
+
+	go responder.run(loopCtx)
+	responder.spawn(func() {
+		responder.summarize(loopCtx)
+	})
 
 	return responder
 }
 
 func (r *Responder) Cleanup() {
-	if r.cancelResponse != nil {
-		r.cancelResponse()
-	}
+	r.stopLoop()
+	<-r.loopDone
+
+	// The loop has stopped, so its state is safe to use here
+	r.stopResponse()
+
+	// Responses, earcons and other background work stop with the call's context, and nothing new can start
+	// without the loop, so once they are done nobody writes to the channels anymore
+	r.goroutines.Wait()
+
 	close(r.toolMessagesSSEChannel)
 	close(r.playAudioChannel)
 	close(r.usageSSEChannel)
 	r.Transcript.Cleanup()
 }
 
-func (r *Responder) Respond() context.CancelFunc {
+// NewTranscription handles a user's completed turn, with how long they were speaking
+func (r *Responder) NewTranscription(line string, duration time.Duration, botNameSpoken float64, username string, userId string, usageEvent usage.UsageEvent) {
+	// Roles are looked up here rather than in the event loop, since it may take a request to Discord
+	var roles []string
+	if r.memberRoles != nil && r.Settings().AccessPolicy != nil {
+		roles = r.memberRoles(userId)
+	}
+
+	r.post(transcriptionEvent{
+		line:              line,
+		duration:          duration,
+		botNameConfidence: botNameSpoken,
+		username:          username,
+		userId:            userId,
+		roles:             roles,
+		usageEvent:        usageEvent,
+	})
+}
+
+// InterimTranscriptionReceived handles a user speaking, with the words heard so far and how long they have been speaking.
+// While the bot is talking, the barge-in policy decides if the speech interrupts it, pauses it, or is ignored.
+func (r *Responder) InterimTranscriptionReceived(text string, duration time.Duration, username string) {
+	r.post(interimEvent{
+		text:     text,
+		duration: duration,
+		username: username,
+	})
+}
+
+// AttemptToRespond responds to the transcript unless a user or the bot is speaking.
+// With interruptThinking, a response that hasn't started playing yet is replaced.
+func (r *Responder) AttemptToRespond(interruptThinking bool) {
+	r.post(respondEvent{
+		interruptThinking: interruptThinking,
+		reason:            "requested",
+	})
+}
+
+// Say speaks the given text as the bot, interrupting any response in progress
+func (r *Responder) Say(text string) {
+	r.post(sayEvent{
+		text: text,
+	})
+}
+
+// Interrupt stops the response in progress, if any
+func (r *Responder) Interrupt() {
+	r.post(interruptEvent{})
+}
+
+// startResponse runs the response pipeline with sentences from produce, which must close sentenceChan when done.
+// The whole response uses the current settings snapshot. Only the event loop calls it.
+func (r *Responder) startResponse(reason string, triggeredBy speaker, produce func(resp *response, sentenceChan chan string)) {
 	startRespondingTime := time.Now()
-	r.isResponding = true
-	ctx, cancelFunc := context.WithCancel(context.Background())
+	resp := r.newResponse(reason, triggeredBy)
+	ctx := resp.ctx
+
 	sentenceChan := make(chan string)
 	audioStreamChan := make(chan audioStreamWithIndex, 100)
 
-	// Create channel for tool messages ready to be sent
-	toolMessageChan := make(chan string, 1)
-
 	wg := sync.WaitGroup{}
 
-	// Start the goroutine to get a stream of tokens
+	// Start the goroutine to get a stream of sentences
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		r.getTokenStream(ctx, sentenceChan, toolMessageChan)
+		produce(resp, sentenceChan)
 	}()
 
 	// Start the goroutine to synthesize the sentences into audio
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		r.synthesizeSentences(ctx, sentenceChan, audioStreamChan)
+		r.synthesizeSentences(ctx, resp.settings, sentenceChan, audioStreamChan)
 	}()
 
 	// Start the goroutine to play the synthesized sentences
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		r.playSynthesizedSentences(ctx, startRespondingTime, audioStreamChan)
+		r.playSynthesizedSentences(ctx, resp, startRespondingTime, audioStreamChan)
 	}()
 
-	// Start a goroutine to wait for all other goroutines to finish
-	go func() {
+	// Start a goroutine to wait for all other goroutines to finish, and report back to the event loop.
+	// Cleanup waits for it, and so for the whole pipeline.
+	r.spawn(func() {
 		wg.Wait()
 
-		// Check if the context was cancelled
-		select {
-		case <-ctx.Done():
-			// If the context was cancelled, don't send the tool message
-			return
-		default:
-			// If the context wasn't cancelled, send the tool message
-			for toolMessage := range toolMessageChan {
-				select {
-				case r.toolMessagesSSEChannel <- SSEMessage{
-					Type: "tool-message",
-					Data: toolMessage,
-				}:
-					r.Transcript.AddToolMessageLine(toolMessage)
-				default:
-				}
-
-			}
-		}
-
-		r.isResponding = false
+		r.post(responseDoneEvent{
+			response: resp,
+		})
+	})
+}
 
-		// After all other goroutines have finished, close the toolMessageChan
-		close(toolMessageChan)
+// spawn runs f in a goroutine that Cleanup waits for. Only the event loop and NewResponder call it,
+// so no goroutine is added once Cleanup is waiting.
+func (r *Responder) spawn(f func()) {
+	r.goroutines.Add(1)
+	go func() {
+		defer r.goroutines.Done()
+		f()
 	}()
+}
 
-	return cancelFunc
+// newResponse makes a response with the current settings snapshot the current one. Only the event loop calls it.
+func (r *Responder) newResponse(reason string, triggeredBy speaker) *response {
+	ctx, cancelFunc := context.WithCancel(r.ctx)
+	resp := &response{
+		ctx:         ctx,
+		cancel:      cancelFunc,
+		settings:    r.Settings(),
+		reason:      reason,
+		triggeredBy: triggeredBy,
+		tasks:       r.taskList(true),
+	}
+	r.response = resp
+	r.transition(StateThinking, reason)
+	return resp
 }
 
-func (r *Responder) AttemptToRespond(interruptThinking bool) {
-	if interruptThinking {
-		if r.userSpeaking || r.isSpeaking || r.VoiceUXConfig.SpeakingMode == "NeverSpeak" {
-			return
-		}
-	} else {
-		if r.userSpeaking || r.isSpeaking || r.isResponding || r.VoiceUXConfig.SpeakingMode == "NeverSpeak" {
-			return
-		}
-	}
+func (r *Responder) getTokenStream(resp *response, sentenceChan chan string) {
+	defer close(sentenceChan)
 
-	r.cancelResponse = r.Respond()
-}
+	ctx := resp.ctx
+	settings := resp.settings
 
-func (r *Responder) getTokenStream(ctx context.Context, sentenceChan chan string, toolMessageChan chan string) {
 	// Create the chat completion stream
-	stream, usageEvent, err := r.LlmService.GetTranscriptResponseStream(r.Transcript, r.BotName, &r.PromptContents)
+	promptContents, retrieval := r.promptContents(ctx, settings, resp.tasks, true)
+	if retrieval != nil {
+		r.SendJSONEvent("retrieval", retrieval)
+	}
+	stream, usageEvent, err := settings.LLMService.GetTranscriptResponseStream(ctx, r.Transcript, promptContents, r.templateData(settings))
 	if err != nil {
 		fmt.Printf("Token stream error: %v\n", err)
 		return
 	}
 	defer stream.Close()
-	defer close(sentenceChan)
+
+	// emit sends a sentence on, unless the response was cancelled and nothing reads them anymore
+	emit := func(sentence string) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case sentenceChan <- sentence:
+			return true
+		}
+	}
 
 	var totalTokens int
 
 	// Initialize a strings.Builder to build sentences from tokens
 	var sentenceBuilder strings.Builder
 
-	// Add this line to initialize a strings.Builder for tool messages
-	var toolMessageBuilder strings.Builder
+	// Tool messages are parsed as they stream in, so each can be sent as soon as it is complete
+	var toolMessageParser tools.ToolMessageParser
 
 	// Initialize a variable to store the previous token
 	var previousToken string
@@ -225,63 +384,132 @@ func (r *Responder) getTokenStream(ctx context.Context, sentenceChan chan string
 	// Initialize a flag to check if we're in the tool message section
 	var inToolMessages = false
 
+	// With native function calling, tool calls come separately from the spoken text
+	nativeToolCalls := settings.LLMService.NativeToolCalls()
+	toolCalls := completion.NewToolCallAccumulator()
+
+	// Whether any text has been streamed yet, since a '^' only means silence at the start of a response
+	var spokenText bool
+
+	// sendToolMessages hands valid tool messages to the event loop, which sends them according to their tool's policy
+	sendToolMessages := func(toolMessages []tools.ToolMessage, rejections []tools.ToolRejection) {
+		for _, toolMessage := range toolMessages {
+			toolMessage, tool, rejection := tools.ValidateToolMessage(toolMessage, settings.PromptContents.AllTools())
+			if rejection != nil {
+				rejections = append(rejections, *rejection)
+				continue
+			}
+
+			if !settings.AccessPolicy.canUseTool(toolMessage.Name, resp.triggeredBy) {
+				rejections = append(rejections, tools.ToolRejection{
+					Name:   toolMessage.Name,
+					Input:  toolMessage.Input,
+					Reason: fmt.Sprintf("the participant who triggered the response isn't allowed to use %s", toolMessage.Name),
+				})
+				continue
+			}
+			toolMessage.SpeakerID = resp.triggeredBy.userID
+
+			log.Printf("Tool message: %s(%s)\n", toolMessage.Name, toolMessage.Input)
+			r.post(toolMessageEvent{
+				response:             resp,
+				toolMessage:          toolMessage,
+				policy:               tool.InterruptPolicy(),
+				requiresConfirmation: tool.RequiresConfirmation,
+			})
+		}
+
+		for _, rejection := range rejections {
+			r.SendJSONEvent("tool-rejected", rejection)
+		}
+		resp.toolRejections = append(resp.toolRejections, rejections...)
+	}
+
 	// Iterate over tokens received from the stream
 	for !streamEnded {
+		// A response that was superseded or interrupted stops reading, so none of its later tool calls are sent
+		if ctx.Err() != nil {
+			return
+		}
+
 		// Receive a token from the stream
-		response, err := stream.Recv()
+		delta, err := stream.Recv()
 
 		// If the stream has ended, set the streamEnded flag to true
 		if errors.Is(err, io.EOF) {
 			streamEnded = true
+		} else if ctx.Err() != nil {
+			return
 		} else if err != nil {
 			// If there is an error while receiving the token, close the channel and return
 			fmt.Printf("\nStream error: %v\n", err)
 			return
 		} else {
-			// Extract the token from the response
-			if len(response.Choices) == 0 {
-				continue
-			}
-			currentToken := response.Choices[0].Delta.Content
-
 			totalTokens++
 
-			// If token is a "^", return
-			if strings.Contains(currentToken, "^") {
-				return
+			for _, toolCall := range delta.ToolCalls {
+				toolCalls.Add(toolCall)
+			}
+			if len(delta.ToolCalls) > 0 {
+				sendToolMessages(functionCallsToToolMessages(toolCalls.TakeCompleted(false), settings.PromptContents.AllTools()), nil)
 			}
 
-			// If token is a "|", we've reached the tool message section
-			if strings.Contains(currentToken, "|") {
-				inToolMessages = true
+			// Extract the token from the response
+			currentToken := delta.Content
 
-				// Split the current token into parts separated by "|"
-				parts := strings.SplitN(currentToken, "|", 2)
+			if nativeToolCalls {
+				if currentToken == "" {
+					continue
+				}
 
-				// If there are characters after the "|", add them to the tool message
-				if len(parts) == 2 {
-					toolMessageBuilder.WriteString(parts[1])
+				// The model chose to stay silent
+				if !spokenText && strings.HasPrefix(strings.TrimSpace(previousToken+currentToken), "^") {
+					return
+				}
+				if strings.TrimSpace(currentToken) != "" {
+					spokenText = true
+				}
+			} else {
+				// If token is a "^", return
+				if !inToolMessages && strings.Contains(currentToken, "^") {
+					return
 				}
 
-				// Don't append this token to the sentence
-				sentenceBuilder.WriteString(previousToken)
+				// If token is a "|", we've reached the tool message section
+				if !inToolMessages && strings.Contains(currentToken, "|") {
+					inToolMessages = true
 
-				// Emit the remaining sentence
-				sentenceChan <- sentenceBuilder.String()
-				sentenceBuilder.Reset()
-				continue
+					// Split the current token into parts separated by "|"
+					parts := strings.SplitN(currentToken, "|", 2)
+
+					// If there are characters after the "|", add them to the tool message
+					if len(parts) == 2 {
+						sendToolMessages(toolMessageParser.Write(parts[1]))
+					}
+
+					// Don't append this token to the sentence
+					sentenceBuilder.WriteString(previousToken)
+
+					// Emit the remaining sentence
+					if !emit(sentenceBuilder.String()) {
+						return
+					}
+					sentenceBuilder.Reset()
+					continue
+				}
 			}
 
 			if inToolMessages {
-				// If we're in the tool message section, append the token to the toolMessageBuilder
-				toolMessageBuilder.WriteString(currentToken)
+				// If we're in the tool message section, parse the token as part of the tool messages
+				sendToolMessages(toolMessageParser.Write(currentToken))
 			} else if previousToken != "" {
 				sentenceBuilder.WriteString(previousToken)
 
 				// If the previous token ends with a sentence-ending character and the current token starts with a whitespace, emit the sentence and reset the sentenceBuilder
 				if isEndOfSentence(previousToken) && startsWithWhitespace(currentToken) {
-					sentence := sentenceBuilder.String()
-					sentenceChan <- sentence
+					if !emit(sentenceBuilder.String()) {
+						return
+					}
 					sentenceBuilder.Reset()
 				}
 			}
@@ -291,27 +519,25 @@ func (r *Responder) getTokenStream(ctx context.Context, sentenceChan chan string
 		}
 	}
 
-	// Emit any remaining sentence
-	if sentenceBuilder.Len() > 0 {
-		sentenceChan <- sentenceBuilder.String()
+	// The last token is only written to the sentence once the next one arrives
+	if !inToolMessages {
+		sentenceBuilder.WriteString(previousToken)
 	}
 
-	// Validate and emit any remaining tool messages
-	if toolMessageBuilder.Len() > 0 {
-		toolMessage := toolMessageBuilder.String()
-		log.Printf("Tool message: %v\n", toolMessage)
-
-		validToolMessage := tools.FormatToolMessage(toolMessage, r.PromptContents.Tools)
-		if validToolMessage != "" {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				toolMessageChan <- validToolMessage
-			}
+	// Emit any remaining sentence
+	if strings.TrimSpace(sentenceBuilder.String()) != "" {
+		if !emit(sentenceBuilder.String()) {
+			return
 		}
 	}
 
+	// Send the tool messages left when the stream ended
+	if nativeToolCalls {
+		sendToolMessages(functionCallsToToolMessages(toolCalls.TakeCompleted(true), settings.PromptContents.AllTools()), nil)
+	} else {
+		sendToolMessages(nil, toolMessageParser.Close())
+	}
+
 	usageEvent.SetCompletionTokens(totalTokens)
 	if !usageEvent.IsEmpty() {
 		usageJson, err := usage.UsageEventToJSON(usageEvent)
@@ -326,19 +552,42 @@ func (r *Responder) getTokenStream(ctx context.Context, sentenceChan chan string
 	}
 }
 
-func (r *Responder) synthesizeSentences(ctx context.Context, sentenceChan chan string, audioStreamChan chan audioStreamWithIndex) {
+// functionCallsToToolMessages turns native function calls into tool messages.
+// Calls to unknown tools keep the function name, so they are rejected when validated.
+func functionCallsToToolMessages(toolCalls []completion.ToolCall, availableTools []tools.Tool) []tools.ToolMessage {
+	toolMessages := make([]tools.ToolMessage, 0, len(toolCalls))
+	for _, toolCall := range toolCalls {
+		tool, ok := tools.ToolByFunctionName(toolCall.Name, availableTools)
+		if !ok {
+			tool.Name = toolCall.Name
+		}
+
+		// Tools with object inputs take the arguments as their input, and other tools take a single input argument
+		input := toolCall.Argument("input")
+		if tool.ObjectInput() {
+			input = json.RawMessage(toolCall.Arguments)
+		}
+
+		toolMessages = append(toolMessages, tools.ToolMessage{
+			Name:  tool.Name,
+			Input: input,
+		})
+	}
+	return toolMessages
+}
+
+func (r *Responder) synthesizeSentences(ctx context.Context, settings *Settings, sentenceChan chan string, audioStreamChan chan audioStreamWithIndex) {
 	defer close(audioStreamChan) // Make sure to close the audioStreamChan when sentenceChan is closed
 
 	sentenceIndex := 0
 	for sentence := range sentenceChan {
 		select {
 		case <-ctx.Done():
-			r.isResponding = false
 			return
 		default:
 		}
 
-		opusPackets, usageEvent, err := r.TtsService.Synthesize(strings.TrimSpace(strings.TrimPrefix(sentence, r.BotName+": ")))
+		opusPackets, timings, usageEvent, err := synthesize(settings.TTSService, strings.TrimSpace(strings.TrimPrefix(sentence, settings.BotName+": ")))
 		if err != nil {
 			fmt.Printf("Error generating speech: %v\n", err)
 			continue
@@ -347,6 +596,7 @@ func (r *Responder) synthesizeSentences(ctx context.Context, sentenceChan chan s
 			index:       sentenceIndex,
 			opusPackets: opusPackets,
 			sentence:    sentence,
+			wordTimings: timings,
 		}
 		sentenceIndex++
 
@@ -362,23 +612,26 @@ func (r *Responder) synthesizeSentences(ctx context.Context, sentenceChan chan s
 	}
 }
 
-func (r *Responder) playSynthesizedSentences(ctx context.Context, receivedTranscriptionTime time.Time, audioStreamChan chan audioStreamWithIndex) {
-	audioStreamMap := make(map[int]io.ReadCloser)
+func (r *Responder) playSynthesizedSentences(ctx context.Context, resp *response, receivedTranscriptionTime time.Time, audioStreamChan chan audioStreamWithIndex) {
+	audioStreamMap := make(map[int]audioStreamWithIndex)
 	nextAudioIndex := 0
 	bytesToDiscard := 1700 // Adjust this value based on how much you want to trim from the beginning
 
 	firstSentence := true
 
+	// Start unpaused, in case a pause outlived the previous response
+	r.playback.resume()
+	defer r.playback.resume()
+
 	for audioStreamWithIndex := range audioStreamChan {
-		audioStreamMap[audioStreamWithIndex.index] = audioStreamWithIndex.opusPackets
-		sentence := audioStreamWithIndex.sentence
+		audioStreamMap[audioStreamWithIndex.index] = audioStreamWithIndex
 
 		r.setSpeaking(true)
 
-		speakingTime := time.Now()
-
 		if firstSentence {
-			r.isSpeaking = true
+			r.post(speechStartedEvent{
+				response: resp,
+			})
 			transcriptionToResponseLatency := time.Since(receivedTranscriptionTime)
 			// Print latency in milliseconds
 			fmt.Printf("Transcription to response latency: %.0f ms\n", transcriptionToResponseLatency.Seconds()*1000)
@@ -387,28 +640,39 @@ func (r *Responder) playSynthesizedSentences(ctx context.Context, receivedTransc
 		}
 
 		for {
-			opusPackets, ok := audioStreamMap[nextAudioIndex]
+			audioStream, ok := audioStreamMap[nextAudioIndex]
 			if !ok {
 				break
 			}
+			opusPackets := audioStream.opusPackets
+			sentence := audioStream.sentence
 
 			// Discard bytes from the beginning of the audio stream
-			if err := r.discardBytes(opusPackets, bytesToDiscard); err != nil {
+			discardedFrames, err := r.discardBytes(opusPackets, bytesToDiscard)
+			if err != nil {
 				break
 			}
 
+			// Frames written since the sentence started, minus the silence played by pauses, is how much of it was heard
+			startFrame := r.framesWritten.Load()
+			silentFrames := 0
+
 			// Use a buffer to read the packets and send them to the playAudioChannel
 			buf := make([]byte, 8192)
 			for {
+				silentFrames += r.waitWhilePaused(ctx)
+
 				select {
 				case <-ctx.Done():
-					r.isSpeaking = false
+					playedFrames := discardedFrames + int(r.framesWritten.Load()-startFrame) - silentFrames
 					r.sendSilentFrames(5)
 					r.setSpeaking(false)
 					opusPackets.Close()
-					r.isResponding = false
-					r.botLineSpoken(r.getCutoffSentence(speakingTime, sentence), true)
-					r.LastResponseEnd = time.Now()
+					r.post(sentenceSpokenEvent{
+						response:    resp,
+						sentence:    getCutoffSentence(strings.TrimPrefix(sentence, resp.settings.BotName+": "), audioStream.wordTimings, time.Duration(playedFrames)*opusFrameDuration),
+						interrupted: true,
+					})
 					return
 				default:
 				}
@@ -421,12 +685,17 @@ func (r *Responder) playSynthesizedSentences(ctx context.Context, receivedTransc
 					break
 				}
 
-				// Send the payload to the playAudioChannel
-				r.playAudioChannel <- buf[:n]
+				// Send the payload to the playAudioChannel, after any earcon that is playing
+				r.audioOutput.Lock()
+				r.writeAudio(buf[:n])
+				r.audioOutput.Unlock()
 			}
 			opusPackets.Close() // Close the opusPackets after playing
 
-			r.botLineSpoken(sentence, false)
+			r.post(sentenceSpokenEvent{
+				response: resp,
+				sentence: sentence,
+			})
 
 			// Remove the played audio stream from the map and increment the nextAudioIndex
 			delete(audioStreamMap, nextAudioIndex)
@@ -435,55 +704,9 @@ func (r *Responder) playSynthesizedSentences(ctx context.Context, receivedTransc
 		r.sendSilentFrames(1)
 		r.setSpeaking(false)
 	}
-	r.isSpeaking = false
-	r.isResponding = false
-	r.LastResponseEnd = time.Now()
 }
 
-func (r *Responder) NewTranscription(line string, botNameSpoken float64, username string, userId string, usageEvent usage.UsageEvent) {
-	r.userSpeaking = false
-
-	if r.cancelResponse != nil {
-		r.cancelResponse()
-	}
-
-	newLine := &transcript.Line{
-		Text:     line,
-		Username: username,
-		UserId:   userId,
-		Type:     "user",
-		Time:     time.Now(),
-	}
-
-	r.linesSinceLastResponse++
-
-	r.Transcript.AddSpokenLine(newLine)
-
-	switch r.VoiceUXConfig.SpeakingMode {
-	case "NeverSpeak":
-		return
-	case "AlwaysSleep":
-		r.Sleep()
-	case "AutoSleep":
-		if r.linesSinceLastResponse > r.VoiceUXConfig.LinesBeforeSleep {
-			r.Sleep()
-			// log.Printf("Bot is asleep\n")
-		}
-
-		if botNameSpoken > r.VoiceUXConfig.BotNameConfidenceThreshold {
-			r.WakeUp()
-			r.linesSinceLastResponse = 0
-			// log.Printf("Bot is awake\n")
-		}
-	default: // AlwaysSpeak
-		r.WakeUp()
-	}
-
-	// Only respond if the bot is awake
-	if r.awake {
-		r.AttemptToRespond(true)
-	}
-
+func (r *Responder) sendUsageEvent(usageEvent usage.UsageEvent) {
 	usageJson, err := usage.UsageEventToJSON(usageEvent)
 	if err != nil {
 		fmt.Printf("Error converting usage event to JSON: %v\n", err)
@@ -495,84 +718,47 @@ func (r *Responder) NewTranscription(line string, botNameSpoken float64, usernam
 	}
 }
 
-func (r *Responder) WakeUp() {
-	if r.awake {
-		return
-	}
-	r.awake = true
-	r.toolMessagesSSEChannel <- SSEMessage{
-		Type: "state",
-		Data: "Awake",
+// SendEvent sends a message on the event stream without blocking if nobody is listening
+func (r *Responder) SendEvent(eventType string, data string) {
+	select {
+	case r.toolMessagesSSEChannel <- SSEMessage{
+		Type: eventType,
+		Data: data,
+	}:
+	default:
 	}
 }
 
-func (r *Responder) Sleep() {
-	if !r.awake {
+// SendJSONEvent marshals data to JSON and sends it on the event stream
+func (r *Responder) SendJSONEvent(eventType string, data interface{}) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		fmt.Printf("Error marshalling %s event: %v\n", eventType, err)
 		return
 	}
-	r.awake = false
-	r.toolMessagesSSEChannel <- SSEMessage{
-		Type: "state",
-		Data: "Asleep",
-	}
+	r.SendEvent(eventType, string(jsonData))
 }
 
-func (r *Responder) botLineSpoken(line string, interrupted bool) {
+func (r *Responder) botLineSpoken(botName string, line string, interrupted bool) {
 	newLine := &transcript.Line{
 		Text:     line,
-		Username: r.BotName,
+		Username: botName,
 		UserId:   r.botId.String(),
 		Type:     "assistant",
 		Time:     time.Now(),
 	}
 	if line != "" {
 		r.Transcript.AddSpokenLine(newLine)
+		r.lastBotLine = r.clock.Now()
 	}
 
 	// Reset the counter when the bot speaks
 	if !interrupted {
 		r.linesSinceLastResponse = 0
+		r.lastEngaged = r.clock.Now()
 	}
 }
 
-func (r *Responder) InterimTranscriptionReceived() {
-	r.userSpeaking = true
-	if r.cancelResponse != nil {
-		r.cancelResponse()
-	}
-}
-
-func (r *Responder) AutoRespond(ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			if r.VoiceUXConfig.AutoRespondInterval != 0 && len(r.PromptContents.Tasks) > 0 {
-				// Check if AutoRespondInterval time has passed since the last response
-				if time.Since(r.LastResponseEnd) >= time.Duration(r.VoiceUXConfig.AutoRespondInterval)*time.Second {
-					r.Transcript.AddTaskReminderLine(r.PromptContents.Tasks[0].Name)
-					r.AttemptToRespond(false)
-				}
-				time.Sleep(time.Duration(max(1, r.VoiceUXConfig.AutoRespondInterval)) * time.Second)
-			} else {
-				// If AutoRespondInterval is zero, or isSpeaking is true, then we can simply continue without sleeping or responding.
-				// Adding a sleep equivalent to AutoRespondInterval to prevent a busy wait loop that could consume CPU.
-				// We use max(1, AutoRespondInterval) to ensure that there's always some sleep even when AutoRespondInterval is zero.
-				time.Sleep(time.Duration(max(1, r.VoiceUXConfig.AutoRespondInterval)) * time.Second)
-			}
-		}
-	}
-}
-
-// Utility function to get the maximum of two integers.
-func max(a, b int) int {
-	if a > b {
-		return a
-	}
-	return b
-}
-
 // isEndOfSentence checks if a token ends with a sentence-ending character or a sentence-ending character followed by a quote
 func isEndOfSentence(token string) bool {
 	endChars := []string{".", "!", "?", ";", ":", "-", "\n"}
@@ -595,6 +781,30 @@ func isEndOfSentence(token string) bool {
 	return false
 }
 
+// splitSentences splits text into sentences, so long text can start playing before all of it is synthesized
+func splitSentences(text string) []string {
+	var sentences []string
+	var sentenceBuilder strings.Builder
+
+	for _, word := range strings.Fields(text) {
+		if sentenceBuilder.Len() > 0 {
+			sentenceBuilder.WriteString(" ")
+		}
+		sentenceBuilder.WriteString(word)
+
+		if isEndOfSentence(word) {
+			sentences = append(sentences, sentenceBuilder.String())
+			sentenceBuilder.Reset()
+		}
+	}
+
+	if sentenceBuilder.Len() > 0 {
+		sentences = append(sentences, sentenceBuilder.String())
+	}
+
+	return sentences
+}
+
 // startsWithWhitespace checks if a token starts with a whitespace character
 func startsWithWhitespace(token string) bool {
 	if len(token) == 0 {
@@ -604,8 +814,11 @@ func startsWithWhitespace(token string) bool {
 	return unicode.IsSpace(firstChar)
 }
 
-func (r *Responder) discardBytes(reader io.Reader, bytesToDiscard int) error {
+// discardBytes reads and drops packets from the start of an opus stream until at least bytesToDiscard bytes are gone.
+// It returns how many of the dropped packets were audio frames rather than ogg opus headers.
+func (r *Responder) discardBytes(reader io.Reader, bytesToDiscard int) (int, error) {
 	buf := make([]byte, 8192)
+	audioFrames := 0
 
 	// If the reader is a net.Conn, set a read deadline
 	if conn, ok := reader.(net.Conn); ok {
@@ -617,12 +830,15 @@ func (r *Responder) discardBytes(reader io.Reader, bytesToDiscard int) error {
 		n := int(math.Min(float64(cap(buf)), float64(bytesToDiscard)))
 		readBytes, err := reader.Read(buf[:n])
 		if err != nil {
-			return err
+			return audioFrames, err
+		}
+		if !bytes.HasPrefix(buf[:readBytes], []byte("OpusHead")) && !bytes.HasPrefix(buf[:readBytes], []byte("OpusTags")) {
+			audioFrames++
 		}
 		bytesToDiscard -= readBytes
 	}
 
-	return nil
+	return audioFrames, nil
 }
 
 func (r *Responder) setSpeaking(speaking bool) {
@@ -642,29 +858,53 @@ func (r *Responder) setSpeaking(speaking bool) {
 	}
 }
 
+// synthesize synthesizes text with word timings if the service supports them
+func synthesize(service texttospeech.TextToSpeechService, text string) (io.ReadCloser, *wordtimings.WordTimings, usage.UsageEvent, error) {
+	if timingService, ok := service.(texttospeech.WordTimingService); ok {
+		opusPackets, timings, usageEvent, err := timingService.SynthesizeWithWordTimings(text)
+		if err == nil {
+			return opusPackets, timings, usageEvent, nil
+		}
+		fmt.Printf("Error generating speech with word timings, generating it without: %v\n", err)
+	}
+
+	opusPackets, usageEvent, err := service.Synthesize(text)
+	return opusPackets, nil, usageEvent, err
+}
+
 func (r *Responder) sendSilentFrames(frames int) {
 	// Define silence Opus frame
 	silenceOpusFrame := []byte{0xF8, 0xFF, 0xFE}
 
 	// Send silent frames after finishing each sentence
 	for i := 0; i < frames; i++ {
-		// Send the payload to the playAudioChannel
-		r.playAudioChannel <- silenceOpusFrame
+		if !r.writeAudio(silenceOpusFrame) {
+			return
+		}
 	}
 }
 
-func (r *Responder) getCutoffSentence(speakingTime time.Time, sentence string) string {
-	speakingRateWPM := 150
-
-	now := time.Now()
-	duration := now.Sub(speakingTime)
-
-	// Convert duration to seconds and speakingRate to words per second
-	seconds := duration.Seconds()
-	wordsPerSecond := float64(speakingRateWPM) / 60.0
+// writeAudio sends an opus frame to the voice connection, or gives up and returns false once the call is ending
+func (r *Responder) writeAudio(frame []byte) bool {
+	select {
+	case <-r.ctx.Done():
+		return false
+	case r.playAudioChannel <- frame:
+		return true
+	}
+}
 
-	// Calculate the number of words spoken
-	wordsSpoken := int(seconds * wordsPerSecond)
+// getCutoffSentence returns the part of a sentence heard in the played duration of its audio. Without word timings
+// from the TTS service, it is estimated from a typical speaking rate.
+func getCutoffSentence(sentence string, timings *wordtimings.WordTimings, played time.Duration) string {
+	var wordsSpoken int
+	if timings != nil {
+		wordsSpoken = timings.WordsSpoken(played)
+	} else {
+		speakingRateWPM := 150
+		wordsPerSecond := float64(speakingRateWPM) / 60.0
+		wordsSpoken = int(played.Seconds() * wordsPerSecond)
+	}
 
 	// Get the words from the sentence
 	words := strings.Fields(sentence)
@@ -0,0 +1,223 @@
+package responder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"com.deablabs.teno-voice/internal/llm"
+	"com.deablabs.teno-voice/internal/llm/promptbuilder"
+	"com.deablabs.teno-voice/internal/retrieval"
+)
+
+// How long embedding the query can take before falling back to BM25
+const retrievalTimeout = 5 * time.Second
+
+// RetrievalEvent is sent on the event stream as a "retrieval" event with the chunks of the documents added to a response's prompt
+type RetrievalEvent struct {
+	Query string
+	// Retriever is "bm25" or "embeddings"
+	Retriever string
+	Chunks    []RetrievedChunk
+}
+
+// RetrievedChunk is a chunk added to the prompt. Index is its position in its document, from 0.
+type RetrievedChunk struct {
+	Document string
+	Index    int
+	Score    float64
+	Tokens   int
+}
+
+// documentIndex is the call's retrieval index. It is rebuilt in the background when the documents,
+// the retrieval config or the LLM service change, and responses keep using the previous one until the new one is ready.
+type documentIndex struct {
+	mu sync.Mutex
+	// key and service are what the latest index is built from, which may still be building
+	key     string
+	service llm.LLMService
+	ready   *builtIndex
+}
+
+// builtIndex holds the retrievers over the chunks of the documents. The embeddings are nil without an
+// embedding model, or if embedding the chunks failed.
+type builtIndex struct {
+	bm25       *retrieval.BM25
+	embeddings *retrieval.EmbeddingIndex
+}
+
+// llmEmbedder embeds texts with the call's LLM service, reporting the usage
+type llmEmbedder struct {
+	r       *Responder
+	service llm.LLMService
+	model   string
+}
+
+func (e llmEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors, usageEvent, err := e.service.Embed(ctx, texts, e.model)
+	if err != nil {
+		return nil, err
+	}
+	e.r.sendUsageEvent(usageEvent)
+	return vectors, nil
+}
+
+// retrievalDefaults fills in the defaults of a retrieval config
+func retrievalDefaults(config retrieval.Config) retrieval.Config {
+	if config.ChunkWords == 0 {
+		config.ChunkWords = 150
+	}
+	if config.ChunkOverlap == 0 {
+		config.ChunkOverlap = 30
+	}
+	if config.TopK == 0 {
+		config.TopK = 4
+	}
+	if config.QueryLines == 0 {
+		config.QueryLines = 4
+	}
+	return config
+}
+
+// refreshIndex starts rebuilding the retrieval index if the settings' documents, retrieval config or LLM service changed.
+// The chunks are embedded before the new index is swapped in, so responses never wait for it.
+func (r *Responder) refreshIndex(settings *Settings) {
+	if settings.PromptContents.Retrieval == nil || settings.PromptContents.Documents == nil {
+		return
+	}
+	config := retrievalDefaults(*settings.PromptContents.Retrieval)
+	key := indexKey(settings.PromptContents.Documents, config)
+	service := settings.LLMService
+
+	index := &r.documentIndex
+	index.mu.Lock()
+	if index.key == key && index.service == service {
+		index.mu.Unlock()
+		return
+	}
+	index.key = key
+	index.service = service
+	index.mu.Unlock()
+
+	r.spawn(func() {
+		built := r.buildIndex(settings.PromptContents.Documents, config, service)
+		if built.embeddings != nil {
+			if err := built.embeddings.Embed(r.ctx); err != nil {
+				fmt.Printf("Error embedding the documents, retrieving with BM25 until they change: %v\n", err)
+				built.embeddings = nil
+			}
+		}
+
+		index.mu.Lock()
+		defer index.mu.Unlock()
+
+		// The settings changed again while this one was building, and the newer build will replace the index
+		if index.key != key || index.service != service {
+			return
+		}
+		index.ready = built
+	})
+}
+
+// buildIndex splits the documents into chunks and indexes them, without embedding them yet
+func (r *Responder) buildIndex(documents []promptbuilder.Document, config retrieval.Config, service llm.LLMService) *builtIndex {
+	var chunks []retrieval.Chunk
+	for _, document := range documents {
+		source := retrieval.Source{Name: document.Name, Content: document.Content}
+		chunks = append(chunks, retrieval.Split(source, config.ChunkWords, config.ChunkOverlap)...)
+	}
+
+	built := &builtIndex{bm25: retrieval.NewBM25(chunks)}
+	if config.EmbeddingModel != "" {
+		built.embeddings = retrieval.NewEmbeddingIndex(chunks, llmEmbedder{r: r, service: service, model: config.EmbeddingModel})
+	}
+	return built
+}
+
+// currentIndex returns the latest index that is ready. Until the first one is, the settings' documents
+// are indexed for BM25 on the spot, which is quick and free.
+func (r *Responder) currentIndex(settings *Settings, config retrieval.Config) *builtIndex {
+	index := &r.documentIndex
+	index.mu.Lock()
+	ready := index.ready
+	index.mu.Unlock()
+
+	if ready != nil {
+		return ready
+	}
+	return &builtIndex{bm25: r.buildIndex(settings.PromptContents.Documents, config, settings.LLMService).bm25}
+}
+
+// indexKey identifies the documents and the parts of the config the index is built from
+func indexKey(documents []promptbuilder.Document, config retrieval.Config) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d %d %s\n", config.ChunkWords, config.ChunkOverlap, config.EmbeddingModel)
+	json.NewEncoder(hash).Encode(documents)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// retrieveDocuments replaces the documents of the prompt contents with the chunks most relevant
+// to the latest lines of the transcript, and returns them for a retrieval event.
+// Embedding the query is a paid call, so without embedQuery the chunks are retrieved with BM25.
+func (r *Responder) retrieveDocuments(ctx context.Context, settings *Settings, contents *promptbuilder.PromptContents, embedQuery bool) *RetrievalEvent {
+	config := retrievalDefaults(*settings.PromptContents.Retrieval)
+
+	index := r.currentIndex(settings, config)
+
+	lines := r.Transcript.GetTranscript()
+	if len(lines) > config.QueryLines {
+		lines = lines[len(lines)-config.QueryLines:]
+	}
+	queryLines := make([]string, len(lines))
+	for i, line := range lines {
+		queryLines[i] = line.Text
+	}
+	query := strings.Join(queryLines, "\n")
+
+	event := RetrievalEvent{Query: query, Retriever: "bm25"}
+	var results []retrieval.Result
+	if embedQuery && index.embeddings != nil {
+		retrievalCtx, cancel := context.WithTimeout(ctx, retrievalTimeout)
+		embedded, err := index.embeddings.Retrieve(retrievalCtx, query, config.TopK)
+		cancel()
+		if err != nil {
+			fmt.Printf("Error retrieving documents with embeddings, falling back to BM25: %v\n", err)
+		} else {
+			results, event.Retriever = embedded, "embeddings"
+		}
+	}
+	if event.Retriever == "bm25" {
+		results, _ = index.bm25.Retrieve(ctx, query, config.TopK)
+	}
+
+	documents := make([]promptbuilder.Document, 0, len(results))
+	total := 0
+	for rank, result := range results {
+		tokens := settings.LLMService.TokenCount(result.Text)
+		if config.MaxTokens > 0 && total+tokens > config.MaxTokens {
+			continue
+		}
+		total += tokens
+
+		documents = append(documents, promptbuilder.Document{
+			Name:    fmt.Sprintf("%s (part %d)", result.Document, result.Index+1),
+			Content: result.Text,
+			// The best chunks are kept first if the token budget can't fit them all
+			Priority: len(results) - rank,
+		})
+		event.Chunks = append(event.Chunks, RetrievedChunk{
+			Document: result.Document,
+			Index:    result.Index,
+			Score:    result.Score,
+			Tokens:   tokens,
+		})
+	}
+
+	contents.Documents = documents
+	return &event
+}
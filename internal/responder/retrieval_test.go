@@ -0,0 +1,84 @@
+package responder
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"com.deablabs.teno-voice/internal/llm/promptbuilder"
+	"com.deablabs.teno-voice/internal/retrieval"
+	"com.deablabs.teno-voice/internal/transcript"
+	"com.deablabs.teno-voice/internal/usage"
+)
+
+// embeddingLLM embeds texts about printers and texts about anything else in opposite directions
+type embeddingLLM struct {
+	fakeLLM
+	embedCalls atomic.Int32
+}
+
+func (f *embeddingLLM) Embed(ctx context.Context, texts []string, model string) ([][]float32, usage.LLMEvent, error) {
+	f.embedCalls.Add(1)
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{0, 1}
+		if strings.Contains(text, "printer") {
+			vectors[i] = []float32{1, 0}
+		}
+	}
+	return vectors, usage.LLMEvent{}, nil
+}
+
+func (f *embeddingLLM) TokenCount(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestRetrievalIndex(t *testing.T) {
+	r := newTestResponder(t, VoiceUXConfig{SpeakingMode: "NeverSpeak"}, nil)
+	service := &embeddingLLM{}
+	r.UpdateSettings(func(settings *Settings) error {
+		settings.LLMService = service
+		settings.PromptContents.Documents = []promptbuilder.Document{
+			{Name: "Office", Content: "The printer is on the second floor."},
+			{Name: "Kitchen", Content: "Coffee is next to the fridge."},
+		}
+		settings.PromptContents.Retrieval = &retrieval.Config{EmbeddingModel: "text-embedding-ada-002", TopK: 1}
+		return nil
+	})
+	r.Transcript.AddSpokenLine(&transcript.Line{Text: "Where can I find it?", Username: "alice", UserId: "1", Type: "user"})
+
+	// The chunks are embedded in the background once the settings change
+	deadline := time.Now().Add(time.Second)
+	for {
+		r.documentIndex.mu.Lock()
+		ready := r.documentIndex.ready
+		r.documentIndex.mu.Unlock()
+		if ready != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the index wasn't built")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if calls := service.embedCalls.Load(); calls != 1 {
+		t.Fatalf("the documents were embedded in %d calls, want 1", calls)
+	}
+
+	// Rendering the prompt doesn't embed the query, and the question shares no terms with the documents
+	contents, event := r.promptContents(context.Background(), r.Settings(), nil, false)
+	if event.Retriever != "bm25" || len(contents.Documents) != 0 || service.embedCalls.Load() != 1 {
+		t.Fatalf("got %d chunks with %s after %d embedding calls, want none with bm25 after 1", len(contents.Documents), event.Retriever, service.embedCalls.Load())
+	}
+
+	r.Transcript.AddSpokenLine(&transcript.Line{Text: "The printer, I mean", Username: "alice", UserId: "1", Type: "user"})
+	contents, event = r.promptContents(context.Background(), r.Settings(), nil, true)
+	if event.Retriever != "embeddings" || len(contents.Documents) != 1 || contents.Documents[0].Name != "Office (part 1)" {
+		t.Fatalf("got %+v with %s, want the office document with embeddings", contents.Documents, event.Retriever)
+	}
+	if event.Chunks[0].Tokens != 7 {
+		t.Errorf("the chunk has %d tokens, want 7 counted by the service", event.Chunks[0].Tokens)
+	}
+}
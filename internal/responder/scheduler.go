@@ -0,0 +1,283 @@
+package responder
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Where a job was scheduled from
+const (
+	JobSourceAPI  = "api"
+	JobSourceTool = "tool"
+)
+
+// MaxJobs is how many jobs a call can have scheduled at once
+const MaxJobs = 20
+
+var ErrJobNotFound = errors.New("job not found")
+
+// JobSpec describes a job to schedule in a call. A job runs once, At a time or In some seconds, or runs Every
+// some seconds, starting At or In if either is set. It either says fixed text, or gives the bot an instruction
+// and has it respond.
+type JobSpec struct {
+	// Name describes the job when it is listed
+	Name string
+	At   *time.Time
+	// In is how many seconds from now the job first runs
+	In int `validate:"min=0"`
+	// Every is how many seconds apart a recurring job runs
+	Every int `validate:"omitempty,min=10"`
+	// Say is spoken as is
+	Say string
+	// Instruction is added to the transcript, only visible to the bot, before it responds
+	Instruction string
+}
+
+// Validate checks the rules across fields that struct validation can't
+func (s JobSpec) Validate() error {
+	if (strings.TrimSpace(s.Say) == "") == (strings.TrimSpace(s.Instruction) == "") {
+		return errors.New("a job needs either Say or Instruction")
+	}
+	if s.At != nil && s.In > 0 {
+		return errors.New("a job can't have both At and In")
+	}
+	if s.At == nil && s.In == 0 && s.Every == 0 {
+		return errors.New("a job needs At, In or Every")
+	}
+	return nil
+}
+
+// Job is a scheduled job. It is sent on the event stream in a "job" event when it is scheduled, runs or is cancelled.
+type Job struct {
+	JobSpec
+	ID string
+	// Source is "api" or "tool"
+	Source  string
+	NextRun time.Time
+	// Runs counts the times the job ran
+	Runs int
+	// scheduledBy is the participant whose request the bot scheduled the job for. The response to the job is
+	// limited to the tools they can use. Jobs scheduled through the API aren't limited.
+	scheduledBy speaker
+}
+
+// JobUpdate is sent on the event stream as a "job" event
+type JobUpdate struct {
+	Job Job
+	// Reason is "scheduled", "ran" or "cancelled". A job that runs once is removed once it ran.
+	Reason string
+}
+
+// scheduleEvent asks for a job to be scheduled
+type scheduleEvent struct {
+	spec  JobSpec
+	reply chan scheduleReply
+}
+
+type scheduleReply struct {
+	job Job
+	err error
+}
+
+// jobsEvent asks for the scheduled jobs
+type jobsEvent struct {
+	reply chan []Job
+}
+
+// cancelJobEvent asks for a job to be cancelled
+type cancelJobEvent struct {
+	id    string
+	reply chan error
+}
+
+func (scheduleEvent) isEvent()  {}
+func (jobsEvent) isEvent()      {}
+func (cancelJobEvent) isEvent() {}
+
+// Schedule schedules a job in the call
+func (r *Responder) Schedule(spec JobSpec) (Job, error) {
+	reply := make(chan scheduleReply, 1)
+	r.post(scheduleEvent{spec: spec, reply: reply})
+
+	select {
+	case scheduled := <-reply:
+		return scheduled.job, scheduled.err
+	case <-r.loopDone:
+		return Job{}, errors.New("the call has ended")
+	}
+}
+
+// Jobs returns the scheduled jobs, the next to run first
+func (r *Responder) Jobs() []Job {
+	reply := make(chan []Job, 1)
+	r.post(jobsEvent{reply: reply})
+
+	select {
+	case jobs := <-reply:
+		return jobs
+	case <-r.loopDone:
+		return nil
+	}
+}
+
+// CancelJob removes a scheduled job
+func (r *Responder) CancelJob(id string) error {
+	reply := make(chan error, 1)
+	r.post(cancelJobEvent{id: id, reply: reply})
+
+	select {
+	case err := <-reply:
+		return err
+	case <-r.loopDone:
+		return ErrJobNotFound
+	}
+}
+
+// scheduleJob adds a job to the schedule. The spec is checked the same way wherever it comes from.
+func (r *Responder) scheduleJob(spec JobSpec, source string) (*Job, error) {
+	if err := validator.New().Struct(spec); err != nil {
+		return nil, err
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	if len(r.jobs) >= MaxJobs {
+		return nil, fmt.Errorf("the call already has %d jobs scheduled", MaxJobs)
+	}
+
+	now := r.clock.Now()
+	job := &Job{
+		JobSpec: spec,
+		ID:      newID(),
+		Source:  source,
+	}
+
+	switch {
+	case spec.At != nil:
+		if !spec.At.After(now) {
+			return nil, errors.New("At is in the past")
+		}
+		job.NextRun = *spec.At
+	case spec.In > 0:
+		job.NextRun = now.Add(time.Duration(spec.In) * time.Second)
+	default:
+		job.NextRun = now.Add(time.Duration(spec.Every) * time.Second)
+	}
+
+	r.jobs = append(r.jobs, job)
+	r.SendJSONEvent("job", JobUpdate{Job: *job, Reason: "scheduled"})
+	return job, nil
+}
+
+// cancelJob removes a job from the schedule. If source is set, only a job from that source can be cancelled.
+func (r *Responder) cancelJob(id string, source string) error {
+	for i, job := range r.jobs {
+		if job.ID == id && (source == "" || job.Source == source) {
+			r.jobs = append(r.jobs[:i], r.jobs[i+1:]...)
+			r.SendJSONEvent("job", JobUpdate{Job: *job, Reason: "cancelled"})
+			return nil
+		}
+	}
+	return ErrJobNotFound
+}
+
+// jobList returns copies of the scheduled jobs, the next to run first
+func (r *Responder) jobList() []Job {
+	jobs := make([]Job, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].NextRun.Before(jobs[j].NextRun)
+	})
+	return jobs
+}
+
+// runDueJob runs the job that has been due the longest, and reports whether it did. Due jobs wait while someone
+// is speaking or the bot is responding or muted, and run one at a time. A recurring job skips the runs it missed.
+func (r *Responder) runDueJob(now time.Time) bool {
+	if r.response != nil || r.State() == StateListening || now.Before(r.mutedUntil) {
+		return false
+	}
+
+	due := -1
+	for i, job := range r.jobs {
+		if !job.NextRun.After(now) && (due == -1 || job.NextRun.Before(r.jobs[due].NextRun)) {
+			due = i
+		}
+	}
+	if due == -1 {
+		return false
+	}
+
+	job := r.jobs[due]
+	job.Runs++
+	if job.Every > 0 {
+		interval := time.Duration(job.Every) * time.Second
+		for !job.NextRun.After(now) {
+			job.NextRun = job.NextRun.Add(interval)
+		}
+	} else {
+		r.jobs = append(r.jobs[:due], r.jobs[due+1:]...)
+	}
+	r.SendJSONEvent("job", JobUpdate{Job: *job, Reason: "ran"})
+
+	// Task reminders hold off after a job, like after any other response the bot starts on its own
+	r.lastAutoRespond = now
+
+	if job.Say != "" {
+		r.say(job.Say, "scheduled job")
+	} else {
+		r.Transcript.AddScheduledInstructionLine(job.Instruction)
+		r.attemptToRespond(false, "scheduled job", job.scheduledBy)
+	}
+	return true
+}
+
+// scheduleJobByTool schedules an instruction for the ScheduleJob built-in tool, which times jobs in minutes
+// or at a time of day in the prompt's timezone, and tells the bot the job's ID so it can cancel it
+func (r *Responder) scheduleJobByTool(settings *Settings, scheduledBy speaker, instruction string, minutes float64, at string, everyMinutes float64) error {
+	location, err := time.LoadLocation(settings.PromptContents.Timezone)
+	if err != nil {
+		return err
+	}
+
+	spec := JobSpec{
+		Name:        instruction,
+		Instruction: instruction,
+		In:          int(minutes * 60),
+		Every:       int(everyMinutes * 60),
+	}
+
+	if at != "" {
+		clockTime, err := time.Parse("15:04", at)
+		if err != nil {
+			return fmt.Errorf("invalid time of day '%s'", at)
+		}
+
+		now := r.clock.Now().In(location)
+		next := time.Date(now.Year(), now.Month(), now.Day(), clockTime.Hour(), clockTime.Minute(), 0, 0, location)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		spec.At = &next
+	}
+
+	job, err := r.scheduleJob(spec, JobSourceTool)
+	if err != nil {
+		return err
+	}
+	job.scheduledBy = scheduledBy
+
+	when := "at " + job.NextRun.In(location).Format("15:04")
+	if job.Every > 0 {
+		when += fmt.Sprintf(" and then every %s", time.Duration(job.Every)*time.Second)
+	}
+	r.Transcript.AddJobScheduledLine(job.ID, when)
+	return nil
+}
@@ -0,0 +1,57 @@
+package responder
+
+import (
+	"com.deablabs.teno-voice/internal/llm"
+	"com.deablabs.teno-voice/internal/llm/promptbuilder"
+	texttospeech "com.deablabs.teno-voice/internal/textToSpeech"
+)
+
+// Settings is an immutable snapshot of the config the responder works with. Config updates swap in a new
+// snapshot instead of changing the current one, and each response uses the snapshot it started with.
+type Settings struct {
+	BotName        string
+	VoiceUXConfig  VoiceUXConfig
+	PromptContents promptbuilder.PromptContents
+	TTSService     texttospeech.TextToSpeechService
+	LLMService     llm.LLMService
+	// AccessPolicy is nil when everyone can wake the bot, trigger its responses and have it use tools
+	AccessPolicy *AccessPolicy
+}
+
+// Settings returns the current settings snapshot, which must not be modified
+func (r *Responder) Settings() *Settings {
+	return r.settings.Load()
+}
+
+// SetSettings replaces the settings snapshot. Responses already in progress keep using the previous one.
+func (r *Responder) SetSettings(settings *Settings) {
+	r.settingsMutex.Lock()
+	defer r.settingsMutex.Unlock()
+
+	r.settings.Store(settings)
+	r.refreshIndex(settings)
+}
+
+// UpdateSettings swaps in a copy of the current settings changed by update. Updates are applied one at a time,
+// so a config update and a built-in tool changing the settings together don't undo each other's changes.
+// Nothing changes if update returns an error.
+func (r *Responder) UpdateSettings(update func(settings *Settings) error) error {
+	r.settingsMutex.Lock()
+	defer r.settingsMutex.Unlock()
+
+	next := *r.settings.Load()
+	if err := update(&next); err != nil {
+		return err
+	}
+	r.settings.Store(&next)
+	r.refreshIndex(&next)
+	return nil
+}
+
+// bargeInConfig returns the barge-in policy. Without one, any speech interrupts the bot, as it always has.
+func (c VoiceUXConfig) bargeInConfig() BargeInConfig {
+	if c.BargeIn == nil {
+		return BargeInConfig{}
+	}
+	return *c.BargeIn
+}
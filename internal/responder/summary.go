@@ -0,0 +1,54 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// How long a summary can take before it is given up on, leaving its lines for the next one
+const summaryTimeout = time.Minute
+
+// summarize folds the lines that fall out of the transcript into its rolling summary in the background,
+// reporting each new summary on the event stream and its token usage on the usage stream
+func (r *Responder) summarize(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.Transcript.LinesDropped():
+		}
+
+		config := r.Transcript.Config().Summary
+		if config == nil {
+			continue
+		}
+
+		batch, maxWords := config.BatchLines, config.MaxWords
+		if batch == 0 {
+			batch = 10
+		}
+		if maxWords == 0 {
+			maxWords = 300
+		}
+
+		lines := r.Transcript.TakeDroppedLines(batch)
+		if lines == nil {
+			continue
+		}
+
+		settings := r.Settings()
+		summaryCtx, cancel := context.WithTimeout(ctx, summaryTimeout)
+		text, usageEvent, err := settings.LLMService.Summarize(summaryCtx, r.Transcript.Summary().Text, lines, settings.BotName, config.Model, maxWords)
+		cancel()
+		if err != nil {
+			// The lines are retried when more lines fall out of the transcript, and the summary keeps what it already had
+			fmt.Printf("Error summarizing transcript: %v\n", err)
+			r.Transcript.ReturnDroppedLines(lines)
+			continue
+		}
+
+		r.sendUsageEvent(usageEvent)
+		r.SendJSONEvent("summary", r.Transcript.SetSummary(text, len(lines)))
+	}
+}
@@ -0,0 +1,206 @@
+package responder
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"com.deablabs.teno-voice/internal/llm/promptbuilder"
+)
+
+var ErrTaskNotFound = errors.New("task not found")
+
+// TaskUpdate is sent on the event stream as a "task" event when a task is added or removed, its status changes,
+// or its due time passes before it is done
+type TaskUpdate struct {
+	// Task is the task as it is now
+	Task promptbuilder.Task
+	// From is the status before the change, empty for a task that was just added
+	From string
+	// Reason is "added", "removed", "config", "tool" or "overdue"
+	Reason string
+}
+
+// trackedTask is a task of the config with its status in the call. Only the event loop uses it.
+type trackedTask struct {
+	promptbuilder.Task
+	// configStatus is the status the config last gave the task, so a config update only changes
+	// the status when it sets a different one
+	configStatus string
+	lastReminded time.Time
+	overdue      bool
+}
+
+func (t *trackedTask) open() bool {
+	return t.Status == promptbuilder.TaskPending || t.Status == promptbuilder.TaskInProgress
+}
+
+// syncTasksEvent asks for the tasks to be synced with the settings after a config update
+type syncTasksEvent struct {
+	remind bool
+}
+
+// tasksEvent asks for the tasks and their statuses
+type tasksEvent struct {
+	reply chan []promptbuilder.Task
+}
+
+func (syncTasksEvent) isEvent() {}
+func (tasksEvent) isEvent()     {}
+
+// SyncTasks picks up the tasks of the current settings. With remind, the bot is reminded of the new ones straight away.
+func (r *Responder) SyncTasks(remind bool) {
+	r.post(syncTasksEvent{remind: remind})
+}
+
+// Tasks returns the call's tasks with their statuses
+func (r *Responder) Tasks() []promptbuilder.Task {
+	reply := make(chan []promptbuilder.Task, 1)
+	r.post(tasksEvent{reply: reply})
+
+	select {
+	case tasks := <-reply:
+		return tasks
+	case <-r.loopDone:
+		return nil
+	}
+}
+
+// syncTasks tracks the tasks added to the settings and stops tracking the removed ones. Tasks that are still there
+// keep their status unless the config sets a new one.
+func (r *Responder) syncTasks(remind bool) {
+	existing := make(map[string]*trackedTask, len(r.tasks))
+	for _, task := range r.tasks {
+		existing[task.ID] = task
+	}
+
+	var synced, added []*trackedTask
+	for _, task := range r.Settings().PromptContents.Tasks {
+		id := task.TaskID()
+		tracked, ok := existing[id]
+		delete(existing, id)
+
+		if !ok {
+			tracked = &trackedTask{Task: task, configStatus: task.Status}
+			tracked.ID = id
+			if tracked.Status == "" {
+				tracked.Status = promptbuilder.TaskPending
+			}
+			r.SendJSONEvent("task", TaskUpdate{Task: tracked.Task, Reason: "added"})
+			synced = append(synced, tracked)
+			added = append(added, tracked)
+			continue
+		}
+
+		status, due := tracked.Status, tracked.Due
+		tracked.Task = task
+		tracked.ID, tracked.Status = id, status
+		if due == nil || task.Due == nil || !due.Equal(*task.Due) {
+			tracked.overdue = false
+		}
+		if task.Status != "" && task.Status != tracked.configStatus && task.Status != status {
+			tracked.Status = task.Status
+			r.SendJSONEvent("task", TaskUpdate{Task: tracked.Task, From: status, Reason: "config"})
+		}
+		tracked.configStatus = task.Status
+		synced = append(synced, tracked)
+	}
+
+	for _, removed := range existing {
+		r.SendJSONEvent("task", TaskUpdate{Task: removed.Task, From: removed.Status, Reason: "removed"})
+	}
+	r.tasks = synced
+
+	if !remind {
+		return
+	}
+
+	reminded := false
+	for _, task := range added {
+		if task.open() {
+			r.remindOfTask(task)
+			reminded = true
+		}
+	}
+	if reminded {
+		r.attemptToRespond(false, "new task", speaker{})
+	}
+}
+
+// setTaskStatus changes the status of a task
+func (r *Responder) setTaskStatus(id string, status string, reason string) error {
+	switch status {
+	case promptbuilder.TaskPending, promptbuilder.TaskInProgress, promptbuilder.TaskDone, promptbuilder.TaskFailed:
+	default:
+		return fmt.Errorf("unknown task status '%s'", status)
+	}
+
+	for _, task := range r.tasks {
+		if task.ID != id {
+			continue
+		}
+		if task.Status != status {
+			from := task.Status
+			task.Status = status
+			r.SendJSONEvent("task", TaskUpdate{Task: task.Task, From: from, Reason: reason})
+		}
+		return nil
+	}
+	return ErrTaskNotFound
+}
+
+// taskList returns the tracked tasks, or only the open ones
+func (r *Responder) taskList(openOnly bool) []promptbuilder.Task {
+	tasks := make([]promptbuilder.Task, 0, len(r.tasks))
+	for _, task := range r.tasks {
+		if !openOnly || task.open() {
+			tasks = append(tasks, task.Task)
+		}
+	}
+	return tasks
+}
+
+// nextReminder picks the open task to remind the bot of, if one is due a reminder. Tasks take turns,
+// the one reminded longest ago going first.
+func (r *Responder) nextReminder(config VoiceUXConfig, now time.Time) *trackedTask {
+	var next *trackedTask
+	for _, task := range r.tasks {
+		if !task.open() {
+			continue
+		}
+
+		interval := time.Duration(task.ReminderInterval) * time.Second
+		if interval == 0 {
+			interval = time.Duration(config.AutoRespondInterval) * time.Second
+		}
+		if interval == 0 || now.Sub(r.lastResponseEnd) < interval || now.Sub(r.lastAutoRespond) < interval || now.Sub(task.lastReminded) < interval {
+			continue
+		}
+
+		if next == nil || task.lastReminded.Before(next.lastReminded) {
+			next = task
+		}
+	}
+	return next
+}
+
+// remindOfTask adds a reminder of the task to the transcript
+func (r *Responder) remindOfTask(task *trackedTask) {
+	task.lastReminded = r.clock.Now()
+
+	reminder := fmt.Sprintf("%s (ID %s)", task.Name, task.ID)
+	if task.overdue {
+		reminder += ", which is overdue"
+	}
+	r.Transcript.AddTaskReminderLine(reminder)
+}
+
+// checkOverdueTasks reports open tasks whose due time has passed, once each
+func (r *Responder) checkOverdueTasks(now time.Time) {
+	for _, task := range r.tasks {
+		if task.open() && !task.overdue && task.Due != nil && now.After(*task.Due) {
+			task.overdue = true
+			r.SendJSONEvent("task", TaskUpdate{Task: task.Task, From: task.Status, Reason: "overdue"})
+		}
+	}
+}
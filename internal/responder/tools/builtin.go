@@ -0,0 +1,236 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Names of the built-in tools, which the responder executes itself instead of sending them on the event stream
+const (
+	BuiltinLeaveCall       = "LeaveCall"
+	BuiltinGoToSleep       = "GoToSleep"
+	BuiltinMuteSelf        = "MuteSelf"
+	BuiltinChangeVoice     = "ChangeVoice"
+	BuiltinSetSpeakingMode = "SetSpeakingMode"
+	BuiltinPlayClip        = "PlayClip"
+	BuiltinSetTaskStatus   = "SetTaskStatus"
+	BuiltinScheduleJob     = "ScheduleJob"
+	BuiltinCancelJob       = "CancelJob"
+)
+
+// MaxMuteMinutes is the longest the bot can mute itself for
+const MaxMuteMinutes = 120
+
+// BuiltinToolsConfig chooses which built-in tools the bot can use in a call. They are all off by default.
+type BuiltinToolsConfig struct {
+	LeaveCall   bool
+	GoToSleep   bool
+	MuteSelf    bool
+	ChangeVoice bool
+	// Voices the bot can change to with ChangeVoice. Any voice of the TTS service is allowed if empty.
+	Voices          []string
+	SetSpeakingMode bool
+	PlayClip        bool
+	// Clips the bot can play with PlayClip
+	Clips []Clip `validate:"dive"`
+	// SetTaskStatus lets the bot mark its tasks in progress, done or failed
+	SetTaskStatus bool
+	// ScheduleJobs lets the bot set timers and recurring jobs in the call, and cancel them
+	ScheduleJobs bool
+}
+
+// Clip is a sound the bot can play in the call. The URL must point to an Ogg Opus file.
+type Clip struct {
+	Name        string `validate:"required"`
+	Description string
+	URL         string `validate:"required,url"`
+}
+
+// BuiltinToolExecution reports a built-in tool the responder executed, on the event stream as a "builtin-tool" event
+type BuiltinToolExecution struct {
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+	// Error is why the tool failed, if it did
+	Error string `json:"error,omitempty"`
+}
+
+// Tools returns the definitions of the enabled built-in tools, which are announced in the prompt like any other tool
+func (c *BuiltinToolsConfig) Tools() []Tool {
+	if c == nil {
+		return nil
+	}
+
+	var builtinTools []Tool
+
+	if c.LeaveCall {
+		builtinTools = append(builtinTools, Tool{
+			Name:        BuiltinLeaveCall,
+			Description: "Leave the voice call. Only use it when someone asks you to leave, and say goodbye first.",
+			InputGuide:  "An empty object.",
+			InputSchema: emptyObjectSchema,
+			// Someone interrupting the goodbye, like "no, stay", keeps the bot in the call
+			Policy: CancelOnInterrupt,
+		})
+	}
+
+	if c.GoToSleep {
+		builtinTools = append(builtinTools, Tool{
+			Name:        BuiltinGoToSleep,
+			Description: "Stop responding until someone says your name again. Use it when people want to talk among themselves.",
+			InputGuide:  "An empty object.",
+			InputSchema: emptyObjectSchema,
+			Policy:      FireAfterSpeech,
+		})
+	}
+
+	if c.MuteSelf {
+		builtinTools = append(builtinTools, Tool{
+			Name:        BuiltinMuteSelf,
+			Description: "Stay completely silent for a number of minutes, even if someone says your name.",
+			InputGuide:  "How many minutes to stay silent for.",
+			InputSchema: objectSchema(map[string]interface{}{
+				"minutes": map[string]interface{}{"type": "number", "minimum": 1, "maximum": MaxMuteMinutes},
+			}),
+			Policy: FireAfterSpeech,
+		})
+	}
+
+	if c.ChangeVoice {
+		voice := map[string]interface{}{"type": "string", "minLength": 1}
+		inputGuide := "The name of the text to speech voice to change to."
+		if len(c.Voices) > 0 {
+			voice["enum"] = c.Voices
+			inputGuide = "One of the available voices: " + strings.Join(c.Voices, ", ") + "."
+		}
+
+		builtinTools = append(builtinTools, Tool{
+			Name:        BuiltinChangeVoice,
+			Description: "Change the voice you speak with. Your next response is spoken in the new voice.",
+			InputGuide:  inputGuide,
+			InputSchema: objectSchema(map[string]interface{}{"voice": voice}),
+			Policy:      FireImmediately,
+		})
+	}
+
+	if c.SetSpeakingMode {
+		builtinTools = append(builtinTools, Tool{
+			Name:        BuiltinSetSpeakingMode,
+			Description: "Change when you speak. AlwaysSpeak responds to everything, AutoSleep only responds when your name is said and for a while after, DirectAddress only responds to what is said to you, and AlwaysSleep only responds when your name is said.",
+			InputGuide:  "The speaking mode to change to.",
+			InputSchema: objectSchema(map[string]interface{}{
+				"mode": map[string]interface{}{"type": "string", "enum": []string{"AlwaysSpeak", "AutoSleep", "DirectAddress", "AlwaysSleep"}},
+			}),
+			Policy: FireAfterSpeech,
+		})
+	}
+
+	if c.PlayClip && len(c.Clips) > 0 {
+		clipNames := make([]string, 0, len(c.Clips))
+		clipDescriptions := make([]string, 0, len(c.Clips))
+		for _, clip := range c.Clips {
+			clipNames = append(clipNames, clip.Name)
+			if clip.Description != "" {
+				clipDescriptions = append(clipDescriptions, fmt.Sprintf("%s (%s)", clip.Name, clip.Description))
+			} else {
+				clipDescriptions = append(clipDescriptions, clip.Name)
+			}
+		}
+
+		builtinTools = append(builtinTools, Tool{
+			Name:        BuiltinPlayClip,
+			Description: "Play a sound clip in the voice call, after you finish speaking.",
+			InputGuide:  "One of the available clips: " + strings.Join(clipDescriptions, ", ") + ".",
+			InputSchema: objectSchema(map[string]interface{}{
+				"clip": map[string]interface{}{"type": "string", "enum": clipNames},
+			}),
+			Policy: CancelOnInterrupt,
+		})
+	}
+
+	if c.SetTaskStatus {
+		builtinTools = append(builtinTools, Tool{
+			Name:        BuiltinSetTaskStatus,
+			Description: "Mark one of your tasks in progress, done or failed. Mark a task done as soon as it is complete, so you aren't reminded of it again.",
+			InputGuide:  "The ID of the task and its new status.",
+			InputSchema: objectSchema(map[string]interface{}{
+				"task":   map[string]interface{}{"type": "string", "minLength": 1},
+				"status": map[string]interface{}{"type": "string", "enum": []string{"in-progress", "done", "failed"}},
+			}),
+			Policy: FireImmediately,
+		})
+	}
+
+	if c.ScheduleJobs {
+		builtinTools = append(builtinTools, Tool{
+			Name:        BuiltinScheduleJob,
+			Description: "Schedule an instruction for yourself, like a timer or a reminder. When the job runs, you get the instruction and respond to it. Set minutes for a delay, at for a time of day, and everyMinutes to repeat it.",
+			InputGuide:  "The instruction to give yourself, like \"Tell everyone the meeting ends in 5 minutes\", and when to run it: minutes from now, or at a time of day as HH:MM, optionally repeating every so many minutes.",
+			InputSchema: partialObjectSchema(map[string]interface{}{
+				"instruction":  map[string]interface{}{"type": "string", "minLength": 1},
+				"minutes":      map[string]interface{}{"type": "number", "minimum": 0},
+				"at":           map[string]interface{}{"type": "string", "pattern": `^([01]?[0-9]|2[0-3]):[0-5][0-9]$`},
+				"everyMinutes": map[string]interface{}{"type": "number", "minimum": 1},
+			}, "instruction"),
+			Policy: FireImmediately,
+		})
+
+		builtinTools = append(builtinTools, Tool{
+			Name:        BuiltinCancelJob,
+			Description: "Cancel a job you scheduled, by the ID you were given when you scheduled it.",
+			InputGuide:  "The ID of the job.",
+			InputSchema: objectSchema(map[string]interface{}{
+				"job": map[string]interface{}{"type": "string", "minLength": 1},
+			}),
+			Policy: FireImmediately,
+		})
+	}
+
+	return builtinTools
+}
+
+// IsBuiltin checks if a tool name is one of the enabled built-in tools
+func (c *BuiltinToolsConfig) IsBuiltin(name string) bool {
+	for _, tool := range c.Tools() {
+		if tool.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Clip finds a registered clip by name
+func (c *BuiltinToolsConfig) Clip(name string) (Clip, bool) {
+	if c == nil {
+		return Clip{}, false
+	}
+
+	for _, clip := range c.Clips {
+		if clip.Name == name {
+			return clip, true
+		}
+	}
+	return Clip{}, false
+}
+
+var emptyObjectSchema = json.RawMessage(`{"type":"object","properties":{},"additionalProperties":false}`)
+
+// objectSchema returns the schema of an object where all the properties are required
+func objectSchema(properties map[string]interface{}) json.RawMessage {
+	required := make([]string, 0, len(properties))
+	for name := range properties {
+		required = append(required, name)
+	}
+	return partialObjectSchema(properties, required...)
+}
+
+// partialObjectSchema returns the schema of an object where only some of the properties are required
+func partialObjectSchema(properties map[string]interface{}, required ...string) json.RawMessage {
+	schema, _ := json.Marshal(map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	})
+	return schema
+}
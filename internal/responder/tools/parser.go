@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// ToolMessageParser parses the JSON array of tool messages the model writes after a '|' as it streams in,
+// returning each tool message as soon as its JSON object is complete
+type ToolMessageParser struct {
+	current  strings.Builder
+	depth    int
+	inString bool
+	escaped  bool
+}
+
+// Write adds streamed text, and returns the tool messages completed by it
+// along with rejections for completed objects that aren't valid tool messages
+func (p *ToolMessageParser) Write(text string) ([]ToolMessage, []ToolRejection) {
+	var toolMessages []ToolMessage
+	var rejections []ToolRejection
+
+	for _, r := range text {
+		// Outside of an object, only the array brackets and separators are expected
+		if p.depth == 0 {
+			if r == '{' {
+				p.depth = 1
+				p.current.WriteRune(r)
+			}
+			continue
+		}
+
+		p.current.WriteRune(r)
+
+		switch {
+		case p.escaped:
+			p.escaped = false
+		case p.inString && r == '\\':
+			p.escaped = true
+		case r == '"':
+			p.inString = !p.inString
+		case p.inString:
+		case r == '{' || r == '[':
+			p.depth++
+		case r == '}' || r == ']':
+			p.depth--
+		}
+
+		if p.depth == 0 {
+			object := p.current.String()
+			p.current.Reset()
+
+			var toolMessage ToolMessage
+			if err := json.Unmarshal([]byte(object), &toolMessage); err != nil {
+				rejections = append(rejections, ToolRejection{
+					Input:  json.RawMessage(strconv.Quote(object)),
+					Reason: "tool message is not a valid JSON object",
+				})
+				continue
+			}
+			toolMessages = append(toolMessages, toolMessage)
+		}
+	}
+
+	return toolMessages, rejections
+}
+
+// Close returns a rejection if the stream ended in the middle of a tool message
+func (p *ToolMessageParser) Close() []ToolRejection {
+	if p.depth == 0 {
+		return nil
+	}
+
+	return []ToolRejection{{
+		Input:  json.RawMessage(strconv.Quote(p.current.String())),
+		Reason: "tool message is incomplete",
+	}}
+}
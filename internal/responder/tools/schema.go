@@ -0,0 +1,285 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Schema is the subset of JSON Schema that tool inputs are validated against: type, enum, const,
+// properties, required, additionalProperties, items, minimum, maximum, minLength, maxLength, pattern,
+// minItems and maxItems. Other keywords, like description, are allowed but not checked.
+type Schema struct {
+	Type                 schemaTypes        `json:"type"`
+	Enum                 []interface{}      `json:"enum"`
+	Const                *interface{}       `json:"const"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+
+	pattern *regexp.Regexp
+	// noAdditionalProperties and additionalSchema are parsed from AdditionalProperties, which is a boolean or a schema
+	noAdditionalProperties bool
+	additionalSchema       *Schema
+}
+
+// schemaTypes is the type keyword, which is either a single type or a list of them
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	*t = multiple
+	return nil
+}
+
+var schemaTypeNames = map[string]bool{
+	"object":  true,
+	"array":   true,
+	"string":  true,
+	"number":  true,
+	"integer": true,
+	"boolean": true,
+	"null":    true,
+}
+
+// ParseSchema parses and checks a JSON Schema
+func ParseSchema(data []byte) (*Schema, error) {
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema: %s", err)
+	}
+
+	if err := schema.compile("input"); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func (s *Schema) compile(path string) error {
+	for _, schemaType := range s.Type {
+		if !schemaTypeNames[schemaType] {
+			return fmt.Errorf("invalid schema at %s: unknown type %q", path, schemaType)
+		}
+	}
+
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid schema at %s: invalid pattern: %s", path, err)
+		}
+		s.pattern = pattern
+	}
+
+	additionalProperties := bytes.TrimSpace(s.AdditionalProperties)
+	switch {
+	case len(additionalProperties) == 0, bytes.Equal(additionalProperties, []byte("true")):
+	case bytes.Equal(additionalProperties, []byte("false")):
+		s.noAdditionalProperties = true
+	default:
+		var additionalSchema Schema
+		if err := json.Unmarshal(additionalProperties, &additionalSchema); err != nil {
+			return fmt.Errorf("invalid schema at %s: additionalProperties must be a boolean or a schema", path)
+		}
+		if err := additionalSchema.compile(path + ".*"); err != nil {
+			return err
+		}
+		s.additionalSchema = &additionalSchema
+	}
+
+	for name, property := range s.Properties {
+		if property == nil {
+			return fmt.Errorf("invalid schema at %s.%s: property schema is null", path, name)
+		}
+		if err := property.compile(path + "." + name); err != nil {
+			return err
+		}
+	}
+
+	if s.Items != nil {
+		if err := s.Items.compile(path + "[]"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IsObject checks if the schema only accepts objects
+func (s *Schema) IsObject() bool {
+	return len(s.Type) == 1 && s.Type[0] == "object"
+}
+
+// Validate checks a decoded JSON value against the schema, and describes the first problem found
+func (s *Schema) Validate(value interface{}) error {
+	return s.validate(value, "input")
+}
+
+func (s *Schema) validate(value interface{}, path string) error {
+	if len(s.Type) > 0 && !s.matchesType(value) {
+		return fmt.Errorf("%s must be of type %s", path, strings.Join(s.Type, " or "))
+	}
+
+	if s.Enum != nil {
+		found := false
+		for _, allowed := range s.Enum {
+			if jsonEqual(value, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			allowed, _ := json.Marshal(s.Enum)
+			return fmt.Errorf("%s must be one of %s", path, allowed)
+		}
+	}
+
+	if s.Const != nil && !jsonEqual(value, *s.Const) {
+		expected, _ := json.Marshal(*s.Const)
+		return fmt.Errorf("%s must be %s", path, expected)
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return s.validateObject(value, path)
+	case []interface{}:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			return fmt.Errorf("%s must have at least %d items", path, *s.MinItems)
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			return fmt.Errorf("%s must have at most %d items", path, *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range value {
+				if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := len([]rune(value))
+		if s.MinLength != nil && length < *s.MinLength {
+			return fmt.Errorf("%s must be at least %d characters long", path, *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return fmt.Errorf("%s must be at most %d characters long", path, *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			return fmt.Errorf("%s must match the pattern %s", path, s.Pattern)
+		}
+	case float64:
+		if s.Minimum != nil && value < *s.Minimum {
+			return fmt.Errorf("%s must be at least %v", path, *s.Minimum)
+		}
+		if s.Maximum != nil && value > *s.Maximum {
+			return fmt.Errorf("%s must be at most %v", path, *s.Maximum)
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) validateObject(object map[string]interface{}, path string) error {
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			return fmt.Errorf("%s is missing the required property %q", path, name)
+		}
+	}
+
+	// Properties are checked in order so the same input always gets the same reason
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, ok := s.Properties[name]
+		switch {
+		case ok:
+			if err := property.validate(object[name], path+"."+name); err != nil {
+				return err
+			}
+		case s.noAdditionalProperties:
+			return fmt.Errorf("%s has the unknown property %q", path, name)
+		case s.additionalSchema != nil:
+			if err := s.additionalSchema.validate(object[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) matchesType(value interface{}) bool {
+	for _, schemaType := range s.Type {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if schemaType == "object" {
+				return true
+			}
+		case []interface{}:
+			if schemaType == "array" {
+				return true
+			}
+		case string:
+			if schemaType == "string" {
+				return true
+			}
+		case float64:
+			if schemaType == "number" || (schemaType == "integer" && value == math.Trunc(value)) {
+				return true
+			}
+		case bool:
+			if schemaType == "boolean" {
+				return true
+			}
+		case nil:
+			if schemaType == "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonEqual compares two decoded JSON values
+func jsonEqual(a interface{}, b interface{}) bool {
+	aJson, errA := json.Marshal(a)
+	bJson, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aJson, bJson)
+}
+
+// InputSchemaValidation checks that a tool's input schema is a schema that tool inputs can be validated against
+func InputSchemaValidation(fl validator.FieldLevel) bool {
+	schema, ok := fl.Field().Interface().(json.RawMessage)
+	if !ok {
+		return false
+	}
+
+	_, err := ParseSchema(schema)
+	return err == nil
+}
@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseSchema(t *testing.T) {
+	tests := []struct {
+		schema string
+		err    string
+	}{
+		{`{"type": "object"}`, ""},
+		{`{"type": ["string", "null"]}`, ""},
+		{`{"description": "ignored", "type": "string"}`, ""},
+		{`{"additionalProperties": true}`, ""},
+		{`{"additionalProperties": {"type": "string"}}`, ""},
+		{`[]`, "invalid schema: json: cannot unmarshal array into Go value of type tools.Schema"},
+		{`{"type": 1}`, "invalid schema: type must be a string or an array of strings"},
+		{`{"type": "text"}`, `invalid schema at input: unknown type "text"`},
+		{`{"pattern": "("}`, "invalid schema at input: invalid pattern: error parsing regexp: missing closing ): `(`"},
+		{`{"additionalProperties": 1}`, "invalid schema at input: additionalProperties must be a boolean or a schema"},
+		{`{"additionalProperties": {"type": "text"}}`, `invalid schema at input.*: unknown type "text"`},
+		{`{"properties": {"name": null}}`, "invalid schema at input.name: property schema is null"},
+		{`{"properties": {"name": {"type": "text"}}}`, `invalid schema at input.name: unknown type "text"`},
+		{`{"properties": {"user": {"properties": {"name": {"pattern": "["}}}}}`, "invalid schema at input.user.name: invalid pattern: error parsing regexp: missing closing ]: `[`"},
+		{`{"items": {"type": "text"}}`, `invalid schema at input[]: unknown type "text"`},
+		{`{"items": {"properties": {"tags": {"items": {"type": "text"}}}}}`, `invalid schema at input[].tags[]: unknown type "text"`},
+	}
+
+	for _, test := range tests {
+		_, err := ParseSchema([]byte(test.schema))
+		if errorString(err) != test.err {
+			t.Errorf("ParseSchema(%s) = %q, want %q", test.schema, errorString(err), test.err)
+		}
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		input  string
+		err    string
+	}{
+		{"type", `{"type": "string"}`, `"hi"`, ""},
+		{"wrong type", `{"type": "string"}`, `1`, "input must be of type string"},
+		{"any of types", `{"type": ["string", "null"]}`, `null`, ""},
+		{"none of types", `{"type": ["string", "null"]}`, `true`, "input must be of type string or null"},
+		{"integer", `{"type": "integer"}`, `3`, ""},
+		{"not an integer", `{"type": "integer"}`, `3.5`, "input must be of type integer"},
+		{"number", `{"type": "number"}`, `3.5`, ""},
+		{"boolean", `{"type": "boolean"}`, `false`, ""},
+		{"array", `{"type": "array"}`, `[]`, ""},
+		{"object", `{"type": "object"}`, `{}`, ""},
+		{"no type", `{}`, `{"anything": [1]}`, ""},
+
+		{"enum", `{"enum": ["red", 1]}`, `1`, ""},
+		{"not in enum", `{"enum": ["red", 1]}`, `"blue"`, `input must be one of ["red",1]`},
+		{"const", `{"const": {"a": 1}}`, `{"a": 1}`, ""},
+		{"not const", `{"const": "on"}`, `"off"`, `input must be "on"`},
+
+		{"minimum", `{"minimum": 1}`, `1`, ""},
+		{"below minimum", `{"minimum": 1}`, `0.5`, "input must be at least 1"},
+		{"maximum", `{"maximum": 10}`, `10`, ""},
+		{"above maximum", `{"maximum": 10}`, `11`, "input must be at most 10"},
+
+		{"minLength counts characters", `{"minLength": 2}`, `"é!"`, ""},
+		{"below minLength", `{"minLength": 2}`, `"a"`, "input must be at least 2 characters long"},
+		{"above maxLength", `{"maxLength": 2}`, `"abc"`, "input must be at most 2 characters long"},
+		{"pattern", `{"pattern": "^[0-9]+$"}`, `"42"`, ""},
+		{"not matching pattern", `{"pattern": "^[0-9]+$"}`, `"4a"`, "input must match the pattern ^[0-9]+$"},
+
+		{"minItems", `{"minItems": 1}`, `[1]`, ""},
+		{"below minItems", `{"minItems": 1}`, `[]`, "input must have at least 1 items"},
+		{"above maxItems", `{"maxItems": 1}`, `[1, 2]`, "input must have at most 1 items"},
+		{"items", `{"items": {"type": "string"}}`, `["a", "b"]`, ""},
+		{"wrong item", `{"items": {"type": "string"}}`, `["a", 2]`, "input[1] must be of type string"},
+
+		{"required", `{"required": ["name"]}`, `{"name": "a"}`, ""},
+		{"missing required", `{"required": ["name"]}`, `{}`, `input is missing the required property "name"`},
+		{"property", `{"properties": {"age": {"type": "integer"}}}`, `{"age": 3}`, ""},
+		{"wrong property", `{"properties": {"age": {"type": "integer"}}}`, `{"age": "3"}`, "input.age must be of type integer"},
+		{"additional properties allowed", `{"properties": {}}`, `{"extra": 1}`, ""},
+		{"no additional properties", `{"properties": {"a": {}}, "additionalProperties": false}`, `{"a": 1, "b": 2}`, `input has the unknown property "b"`},
+		{"additional properties schema", `{"additionalProperties": {"type": "string"}}`, `{"a": "x"}`, ""},
+		{"wrong additional property", `{"additionalProperties": {"type": "string"}}`, `{"a": 1}`, "input.a must be of type string"},
+		{"first property in order", `{"additionalProperties": {"type": "string"}}`, `{"b": 1, "a": 2}`, "input.a must be of type string"},
+
+		{"nested property", `{"properties": {"user": {"properties": {"name": {"minLength": 1}}}}}`, `{"user": {"name": ""}}`, "input.user.name must be at least 1 characters long"},
+		{"nested required", `{"properties": {"user": {"required": ["name"]}}}`, `{"user": {}}`, `input.user is missing the required property "name"`},
+		{"property of item", `{"items": {"properties": {"id": {"type": "string"}}}}`, `[{"id": "a"}, {"id": 1}]`, "input[1].id must be of type string"},
+		{"item of property", `{"properties": {"tags": {"items": {"enum": ["a"]}}}}`, `{"tags": ["a", "b"]}`, `input.tags[1] must be one of ["a"]`},
+	}
+
+	for _, test := range tests {
+		schema, err := ParseSchema([]byte(test.schema))
+		if err != nil {
+			t.Errorf("%s: ParseSchema(%s) failed: %v", test.name, test.schema, err)
+			continue
+		}
+
+		var input interface{}
+		if err := json.Unmarshal([]byte(test.input), &input); err != nil {
+			t.Fatalf("%s: invalid input %s: %v", test.name, test.input, err)
+		}
+
+		if err := schema.Validate(input); errorString(err) != test.err {
+			t.Errorf("%s: Validate(%s) = %q, want %q", test.name, test.input, errorString(err), test.err)
+		}
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
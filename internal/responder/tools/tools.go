@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -11,67 +12,147 @@ type Tool struct {
 	Description string `json:"description"`
 	InputGuide  string `json:"inputGuide"`
 	OutputGuide string `json:"outputGuide"`
+	// InputSchema is an optional JSON Schema the input must match. With a schema the input is any JSON value,
+	// usually an object with the tool's parameters. Without one the input is a non-empty string.
+	InputSchema json.RawMessage `json:"inputSchema,omitempty" validate:"omitempty,ToolInputSchema"`
+	// Policy decides when the tool's invocations are sent and if an interruption cancels them.
+	// It defaults to cancel-on-interrupt.
+	Policy string `json:"policy,omitempty" validate:"omitempty,oneof=fire-immediately fire-after-speech cancel-on-interrupt"`
+	// RequiresConfirmation makes the bot read invocations back and only send them once a participant confirms
+	RequiresConfirmation bool `json:"requiresConfirmation,omitempty"`
 }
 
+const (
+	// FireImmediately sends invocations as soon as they are parsed, while the bot may still be speaking
+	FireImmediately = "fire-immediately"
+	// FireAfterSpeech sends invocations once the bot stops speaking, even if it was interrupted
+	FireAfterSpeech = "fire-after-speech"
+	// CancelOnInterrupt sends invocations once the bot finishes speaking, and drops them if it was interrupted
+	CancelOnInterrupt = "cancel-on-interrupt"
+)
+
+// InterruptPolicy returns the tool's policy, or the default one if it has none
+func (t Tool) InterruptPolicy() string {
+	if t.Policy == "" {
+		return CancelOnInterrupt
+	}
+	return t.Policy
+}
+
+// ToolMessage is a tool invocation. Input is a JSON string, or a value matching the tool's input schema.
 type ToolMessage struct {
-	Name  string `json:"name"`
-	Input string `json:"input"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+	// SpeakerID is the Discord user ID of the participant who triggered the response, if anyone did
+	SpeakerID string `json:"speakerId,omitempty"`
 }
 
-func FormatToolMessage(message string, availableTools []Tool) string {
-	lastBracket := strings.LastIndex(message, "]")
-	if lastBracket != -1 {
-		message = message[:lastBracket+1]
+// ToolRejection is an invalid tool invocation, with the reason it was rejected
+type ToolRejection struct {
+	Name   string          `json:"name"`
+	Input  json.RawMessage `json:"input"`
+	Reason string          `json:"reason"`
+}
+
+// ObjectInput checks if the tool's input is an object of parameters
+func (t Tool) ObjectInput() bool {
+	if len(t.InputSchema) == 0 {
+		return false
 	}
 
-	var toolMessages []ToolMessage
-	err := json.Unmarshal([]byte(message), &toolMessages)
-	// If there's an error, the JSON was invalid. Return an empty list.
-	if err != nil {
-		// Log the specific unmarshalling error for debugging.
-		fmt.Printf("Tool message JSON unmarshal error: %v\n", err)
+	schema, err := ParseSchema(t.InputSchema)
+	return err == nil && schema.IsObject()
+}
+
+// checkInput validates a tool message's input against the tool, and returns the reason if it is invalid
+func (t Tool) checkInput(input json.RawMessage) string {
+	if len(bytes.TrimSpace(input)) == 0 {
+		return "input is missing"
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(input, &value); err != nil {
+		return "input is not valid JSON"
+	}
+
+	if len(t.InputSchema) == 0 {
+		text, ok := value.(string)
+		if !ok {
+			return "input must be a string"
+		}
+		if strings.TrimSpace(text) == "" {
+			return "input is empty"
+		}
 		return ""
 	}
 
-	// Convert the list of available tools into a set for faster lookup.
-	availableToolNames := make(map[string]bool)
-	for _, tool := range availableTools {
-		availableToolNames[tool.Name] = true
+	schema, err := ParseSchema(t.InputSchema)
+	if err != nil {
+		return err.Error()
+	}
+	if err := schema.Validate(value); err != nil {
+		return err.Error()
 	}
+	return ""
+}
 
-	validToolMessages := make([]ToolMessage, 0)
+// ValidateToolMessage checks that a tool message invokes an available tool with a valid input.
+// It returns the tool message with its name trimmed, or a rejection with the reason it is invalid.
+func ValidateToolMessage(toolMessage ToolMessage, availableTools []Tool) (ToolMessage, Tool, *ToolRejection) {
+	name := strings.TrimSpace(toolMessage.Name)
+	toolMessage.Name = name
 
-	// Iterate through the tool messages, checking that each has a non-empty "name" and "input",
-	// and that the "name" corresponds to an available tool. Keep only valid tool messages.
-	for _, toolMessage := range toolMessages {
-		// Trim leading and trailing whitespace from the name and input.
-		name := strings.TrimSpace(toolMessage.Name)
-		input := strings.TrimSpace(toolMessage.Input)
+	var tool Tool
+	var reason string
+	if name == "" {
+		reason = "name is empty"
+	} else if found, ok := toolByName(name, availableTools); !ok {
+		reason = fmt.Sprintf("tool '%s' is not available", name)
+	} else {
+		tool = found
+		reason = tool.checkInput(toolMessage.Input)
+	}
 
-		if name == "" {
-			fmt.Printf("Invalid tool message: name is empty\n")
-		}
-		if input == "" {
-			fmt.Printf("Invalid tool message: input is empty\n")
-		}
-		if !availableToolNames[name] {
-			fmt.Printf("Invalid tool message: tool name '%s' is not available\n", name)
+	if reason != "" {
+		fmt.Printf("Invalid tool message: %s\n", reason)
+		return toolMessage, tool, &ToolRejection{
+			Name:   name,
+			Input:  toolMessage.Input,
+			Reason: reason,
 		}
+	}
 
-		if name != "" && input != "" && availableToolNames[name] {
-			validToolMessages = append(validToolMessages, toolMessage)
+	return toolMessage, tool, nil
+}
+
+func toolByName(name string, availableTools []Tool) (Tool, bool) {
+	for _, tool := range availableTools {
+		if tool.Name == name {
+			return tool, true
 		}
 	}
+	return Tool{}, false
+}
 
-	// Convert the valid tool messages back into a JSON string.
-	validToolMessagesJSON, err := json.Marshal(validToolMessages)
-	if err != nil {
-		// Log the error and return an empty JSON array.
-		fmt.Printf("Error marshalling tool messages back into JSON: %v\n", err)
-		return ""
-	}
+// FunctionName returns the name a tool is given as a native function. Function names can only have letters,
+// digits, underscores and dashes, so anything else is replaced with an underscore.
+func FunctionName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
 
-	return string(validToolMessagesJSON)
+// ToolByFunctionName finds the available tool a native function call refers to
+func ToolByFunctionName(functionName string, availableTools []Tool) (Tool, bool) {
+	for _, tool := range availableTools {
+		if FunctionName(tool.Name) == functionName {
+			return tool, true
+		}
+	}
+	return Tool{}, false
 }
 
 // ParseTools parses a JSON string into an array of Tools
@@ -83,7 +164,11 @@ func ParseTools(jsonTools string) ([]Tool, error) {
 
 // ToolToString converts a Tool object to a string
 func ToolToString(tool Tool) string {
-	return fmt.Sprintf("Name: %s\nDescription: %s\nInput Guide: %s\nOutput Guide: %s", tool.Name, tool.Description, tool.InputGuide, tool.OutputGuide)
+	toolString := fmt.Sprintf("Name: %s\nDescription: %s\nInput Guide: %s\nOutput Guide: %s", tool.Name, tool.Description, tool.InputGuide, tool.OutputGuide)
+	if len(tool.InputSchema) > 0 {
+		toolString += fmt.Sprintf("\nInput Schema: %s", tool.InputSchema)
+	}
+	return toolString
 }
 
 // ToolsToStringArray converts an array of Tool objects to an array of strings
@@ -0,0 +1,82 @@
+package responder
+
+import (
+	"strings"
+
+	"com.deablabs.teno-voice/internal/phonetic"
+)
+
+// WakeEvent is sent on the event stream as a "wake" event when a participant wakes the bot by saying its name
+type WakeEvent struct {
+	UserID   string
+	Username string
+	// Alias is the bot name or alias heard in the line, or empty if only the transcriber's search heard the bot name
+	Alias string
+	// Score is how closely the line matched Alias, from 0 to 1
+	Score float64
+	// SearchConfidence is the transcriber's confidence that the bot name was said
+	SearchConfidence float64
+	// Combined is Score combined with SearchConfidence, which FuzzyWakeThreshold is checked against
+	Combined float64
+}
+
+// matchBotName finds the bot name or alias a line sounds most like, and how closely it matches from 0 to 1.
+// Names are compared to every run of as many words in the line, and one more, so "ten o" can match "Teno".
+func matchBotName(line string, names []string) (string, float64) {
+	words := normalizedWords(line)
+
+	bestName, bestScore := "", 0.0
+	for _, name := range names {
+		nameWords := normalizedWords(name)
+		if len(nameWords) == 0 {
+			continue
+		}
+		joinedName := strings.Join(nameWords, "")
+
+		for size := len(nameWords); size <= len(nameWords)+1; size++ {
+			for start := 0; start+size <= len(words); start++ {
+				score := phonetic.Match(joinedName, strings.Join(words[start:start+size], ""))
+				if score > bestScore {
+					bestName, bestScore = name, score
+				}
+			}
+		}
+	}
+
+	return bestName, bestScore
+}
+
+// wakeScore combines how closely a line matched the bot name with the transcriber's search confidence, as the chance
+// that either of them is right. A strong match or a confident search is enough, and weak hits on both add up.
+func wakeScore(matchScore float64, searchConfidence float64) float64 {
+	return 1 - (1-matchScore)*(1-searchConfidence)
+}
+
+// detectWake checks if a line wakes the bot. The bot name or an alias said exactly always does. With fuzzy matching,
+// so does a close enough match combined with the transcriber's search confidence, and without it a confident search.
+func detectWake(line string, searchConfidence float64, settings *Settings) (WakeEvent, bool) {
+	config := settings.VoiceUXConfig
+	alias, score := matchBotName(line, append([]string{settings.BotName}, config.BotNameAliases...))
+
+	wake := WakeEvent{
+		Alias:            alias,
+		Score:            score,
+		SearchConfidence: searchConfidence,
+		Combined:         wakeScore(score, searchConfidence),
+	}
+
+	if score == 1 {
+		return wake, true
+	}
+
+	if config.FuzzyWakeThreshold > 0 {
+		return wake, wake.Combined >= config.FuzzyWakeThreshold
+	}
+
+	if searchConfidence > config.BotNameConfidenceThreshold {
+		wake.Alias, wake.Score, wake.Combined = "", 0, searchConfidence
+		return wake, true
+	}
+
+	return wake, false
+}
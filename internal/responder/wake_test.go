@@ -0,0 +1,72 @@
+package responder
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMatchBotName(t *testing.T) {
+	names := []string{"Teno", "Tenno Bot"}
+
+	tests := []struct {
+		line  string
+		alias string
+		score float64
+	}{
+		{"Hey Teno, what's the time?", "Teno", 1},
+		{"hey tenno", "Teno", 0.9},
+		{"okay Tina can you help", "Teno", 0.75},
+		// Split words are joined, so "ten o" sounds like "Teno"
+		{"ten o are you there", "Teno", 1},
+		{"ask tenno bot", "Tenno Bot", 1},
+		{"", "", 0},
+	}
+
+	for _, test := range tests {
+		alias, score := matchBotName(test.line, names)
+		if alias != test.alias || math.Abs(score-test.score) > 1e-9 {
+			t.Errorf("matchBotName(%q) = %q, %v, want %q, %v", test.line, alias, score, test.alias, test.score)
+		}
+	}
+}
+
+func TestDetectWake(t *testing.T) {
+	tests := []struct {
+		name             string
+		line             string
+		searchConfidence float64
+		fuzzyThreshold   float64
+		wakes            bool
+	}{
+		{"exact name", "hi Teno", 0, 0, true},
+		{"exact name with fuzzy matching", "hi Teno", 0, 0.9, true},
+		{"near miss without fuzzy matching", "hi Tina", 0, 0, false},
+		{"confident search without fuzzy matching", "hi there", 0.8, 0, true},
+		{"unsure search without fuzzy matching", "hi there", 0.6, 0, false},
+		{"close match alone", "hi tenno", 0, 0.9, true},
+		{"weak match alone", "hi Tina", 0, 0.9, false},
+		{"weak match and weak search together", "hi Tina", 0.6, 0.9, true},
+		{"confident search alone", "hi there", 0.95, 0.9, true},
+		// With fuzzy matching, the search confidence is combined instead of checked on its own
+		{"search below the fuzzy threshold", "hi there", 0.8, 0.9, false},
+	}
+
+	for _, test := range tests {
+		settings := &Settings{
+			BotName: "Teno",
+			VoiceUXConfig: VoiceUXConfig{
+				SpeakingMode:               "AutoSleep",
+				BotNameConfidenceThreshold: 0.7,
+				FuzzyWakeThreshold:         test.fuzzyThreshold,
+			},
+		}
+
+		wake, wakes := detectWake(test.line, test.searchConfidence, settings)
+		if wakes != test.wakes {
+			t.Errorf("%s: detectWake(%q, %v) wakes = %v, want %v (combined %v)", test.name, test.line, test.searchConfidence, wakes, test.wakes, wake.Combined)
+		}
+		if want := wakeScore(wake.Score, test.searchConfidence); wake.Combined != want {
+			t.Errorf("%s: combined score %v, want %v", test.name, wake.Combined, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package retrieval
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters: k1 limits how much repeating a term helps, b how much longer chunks are penalised
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Words too common to tell chunks apart
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true, "by": true,
+	"do": true, "for": true, "from": true, "has": true, "have": true, "he": true, "her": true, "his": true, "i": true,
+	"if": true, "in": true, "is": true, "it": true, "its": true, "me": true, "my": true, "no": true, "not": true,
+	"of": true, "on": true, "or": true, "our": true, "she": true, "so": true, "that": true, "the": true, "their": true,
+	"them": true, "there": true, "they": true, "this": true, "to": true, "was": true, "we": true, "were": true,
+	"what": true, "when": true, "which": true, "who": true, "will": true, "with": true, "you": true, "your": true,
+}
+
+// BM25 is an in-memory BM25 index over chunks
+type BM25 struct {
+	chunks        []Chunk
+	termCounts    []map[string]int
+	lengths       []int
+	averageLength float64
+	// documentFrequency counts the chunks each term appears in
+	documentFrequency map[string]int
+}
+
+// NewBM25 indexes chunks for BM25 retrieval
+func NewBM25(chunks []Chunk) *BM25 {
+	index := &BM25{
+		chunks:            chunks,
+		termCounts:        make([]map[string]int, len(chunks)),
+		lengths:           make([]int, len(chunks)),
+		documentFrequency: make(map[string]int),
+	}
+
+	total := 0
+	for i, chunk := range chunks {
+		counts := make(map[string]int)
+		terms := tokenize(chunk.Text)
+		for _, term := range terms {
+			counts[term]++
+		}
+		for term := range counts {
+			index.documentFrequency[term]++
+		}
+		index.termCounts[i] = counts
+		index.lengths[i] = len(terms)
+		total += len(terms)
+	}
+	if len(chunks) > 0 {
+		index.averageLength = float64(total) / float64(len(chunks))
+	}
+
+	return index
+}
+
+// Retrieve returns the k chunks that score highest for the query, leaving out chunks that share no terms with it
+func (index *BM25) Retrieve(ctx context.Context, query string, k int) ([]Result, error) {
+	terms := make(map[string]bool)
+	for _, term := range tokenize(query) {
+		terms[term] = true
+	}
+
+	n := float64(len(index.chunks))
+	var results []Result
+	for i, chunk := range index.chunks {
+		score := 0.0
+		for term := range terms {
+			count := float64(index.termCounts[i][term])
+			if count == 0 {
+				continue
+			}
+			frequency := float64(index.documentFrequency[term])
+			idf := math.Log(1 + (n-frequency+0.5)/(frequency+0.5))
+			norm := 1 - bm25B + bm25B*float64(index.lengths[i])/index.averageLength
+			score += idf * count * (bm25K1 + 1) / (count + bm25K1*norm)
+		}
+		if score > 0 {
+			results = append(results, Result{Chunk: chunk, Score: score})
+		}
+	}
+
+	return top(results, k), ctx.Err()
+}
+
+// tokenize lowercases text and splits it into terms, leaving out stop words
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := words[:0]
+	for _, word := range words {
+		if !stopWords[word] {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// top sorts results best first and keeps the first k
+func top(results []Result, k int) []Result {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
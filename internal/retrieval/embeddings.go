@@ -0,0 +1,91 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+)
+
+// EmbeddingIndex ranks chunks by the cosine similarity of their embeddings to the query's.
+// The chunks must be embedded with Embed before the index is queried.
+type EmbeddingIndex struct {
+	embedder Embedder
+	chunks   []Chunk
+
+	mu      sync.Mutex
+	vectors [][]float32
+}
+
+// NewEmbeddingIndex indexes chunks for embedding based retrieval
+func NewEmbeddingIndex(chunks []Chunk, embedder Embedder) *EmbeddingIndex {
+	return &EmbeddingIndex{embedder: embedder, chunks: chunks}
+}
+
+// Retrieve embeds the query and returns the k chunks closest to it
+func (index *EmbeddingIndex) Retrieve(ctx context.Context, query string, k int) ([]Result, error) {
+	index.mu.Lock()
+	vectors := index.vectors
+	index.mu.Unlock()
+	if vectors == nil && len(index.chunks) > 0 {
+		return nil, errors.New("the chunks aren't embedded yet")
+	}
+
+	queryVectors, err := index.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(queryVectors) != 1 {
+		return nil, errors.New("embedder returned no vector for the query")
+	}
+
+	results := make([]Result, len(index.chunks))
+	for i, chunk := range index.chunks {
+		results[i] = Result{Chunk: chunk, Score: cosineSimilarity(queryVectors[0], vectors[i])}
+	}
+
+	return top(results, k), nil
+}
+
+// Embed embeds the chunks, unless they already are
+func (index *EmbeddingIndex) Embed(ctx context.Context) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if index.vectors != nil || len(index.chunks) == 0 {
+		return nil
+	}
+
+	texts := make([]string, len(index.chunks))
+	for i, chunk := range index.chunks {
+		texts[i] = chunk.Text
+	}
+
+	vectors, err := index.embedder.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+	if len(vectors) != len(texts) {
+		return errors.New("embedder returned the wrong number of vectors")
+	}
+
+	index.vectors = vectors
+	return nil
+}
+
+func cosineSimilarity(a []float32, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,82 @@
+package retrieval
+
+import (
+	"context"
+	"strings"
+)
+
+// Config turns on retrieval: documents are split into chunks, and each turn only the chunks most relevant
+// to the recent transcript go into the prompt instead of every document
+type Config struct {
+	// ChunkWords is how many words each chunk has, 150 by default
+	ChunkWords int `validate:"min=0"`
+	// ChunkOverlap is how many words a chunk shares with the one before it, so a passage split between them is still found.
+	// 30 by default. Chunks don't overlap if it isn't less than ChunkWords.
+	ChunkOverlap int `validate:"min=0"`
+	// TopK is how many chunks are retrieved each turn, 4 by default
+	TopK int `validate:"min=0"`
+	// MaxTokens caps the tokens of the retrieved chunks. 0 leaves them limited by TopK and the token budget only.
+	MaxTokens int `validate:"min=0"`
+	// QueryLines is how many of the latest transcript lines make up the query, 4 by default
+	QueryLines int `validate:"min=0"`
+	// EmbeddingModel ranks chunks by embedding similarity with this model instead of BM25, like "text-embedding-ada-002".
+	// BM25 is still used when embedding fails.
+	EmbeddingModel string
+}
+
+// Source is a document to index
+type Source struct {
+	Name    string
+	Content string
+}
+
+// Chunk is a piece of a document
+type Chunk struct {
+	Document string
+	// Index is the position of the chunk in its document, from 0
+	Index int
+	Text  string
+}
+
+// Result is a retrieved chunk, with how relevant it is to the query. Scores of different retrievers can't be compared.
+type Result struct {
+	Chunk
+	Score float64
+}
+
+// Retriever ranks chunks by how relevant they are to a query, returning at most k of them, best first
+type Retriever interface {
+	Retrieve(ctx context.Context, query string, k int) ([]Result, error)
+}
+
+// Embedder turns texts into embedding vectors, for embedding based retrieval
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Split splits a document into chunks of words words, each starting overlap words before the end of the previous one
+func Split(source Source, words int, overlap int) []Chunk {
+	fields := strings.Fields(source.Content)
+	if len(fields) == 0 {
+		return nil
+	}
+	if overlap >= words {
+		overlap = 0
+	}
+
+	var chunks []Chunk
+	for start := 0; ; start += words - overlap {
+		end := start + words
+		if end > len(fields) {
+			end = len(fields)
+		}
+		chunks = append(chunks, Chunk{
+			Document: source.Name,
+			Index:    len(chunks),
+			Text:     strings.Join(fields[start:end], " "),
+		})
+		if end == len(fields) {
+			return chunks
+		}
+	}
+}
@@ -4,55 +4,79 @@ import (
 	"context"
 	"log"
 	"strings"
+	"sync/atomic"
+	"time"
 
-	Config "com.deablabs.teno-voice/internal/config"
 	"com.deablabs.teno-voice/internal/responder"
-	"com.deablabs.teno-voice/internal/usage"
 	"com.deablabs.teno-voice/pkg/deepgram"
 	"github.com/Jeffail/gabs/v2"
 	"github.com/gorilla/websocket"
 )
 
-var dg = deepgram.NewClient(Config.Environment.DeepgramToken)
-
 type TranscriberConfig struct {
-	Keywords     []string
-	IgnoredUsers []string
+	Keywords      []string
+	IgnoredUsers  []string
+	TurnDetection *TurnDetectionConfig
 }
 
 type Transcriber struct {
-	BotName   string
-	Config    TranscriberConfig
 	Responder *responder.Responder
+	deepgram  *deepgram.Client
+	settings  atomic.Pointer[transcriberSettings]
+	turns     *TurnDetector
 }
 
-func NewTranscriber(botName string, config TranscriberConfig, responder *responder.Responder) *Transcriber {
-	ignoredUsersMap := make(map[string]struct{})
-	for _, ignoredUser := range config.IgnoredUsers {
-		ignoredUsersMap[ignoredUser] = struct{}{}
-	}
-	return &Transcriber{
-		BotName:   botName,
-		Config:    config,
+// transcriberSettings is an immutable snapshot of the transcriber's config, replaced as a whole on updates
+type transcriberSettings struct {
+	botName string
+	config  TranscriberConfig
+}
+
+func NewTranscriber(deepgramClient *deepgram.Client, botName string, config TranscriberConfig, responder *responder.Responder) *Transcriber {
+	t := &Transcriber{
 		Responder: responder,
+		deepgram:  deepgramClient,
+	}
+	t.SetConfig(botName, config)
+	t.turns = NewTurnDetector(t.turnDetectionConfig, responder.NewTranscription)
+	return t
+}
+
+// SetConfig replaces the bot name and config. Streams that are already open keep their keywords.
+func (t *Transcriber) SetConfig(botName string, config TranscriberConfig) {
+	t.settings.Store(&transcriberSettings{
+		botName: botName,
+		config:  config,
+	})
+}
+
+func (t *Transcriber) turnDetectionConfig() TurnDetectionConfig {
+	config := t.settings.Load().config
+	if config.TurnDetection == nil {
+		return defaultTurnDetectionConfig
 	}
+	return config.TurnDetection.withDefaults()
 }
 
 // deepgram s2t sdk
 func (t *Transcriber) NewStream(ctx context.Context, onClose func(), username string, userId string) (*websocket.Conn, error) {
+	settings := t.settings.Load()
+
 	// Split botname into words
-	botNameWords := strings.Split(t.BotName, " ")
+	botNameWords := strings.Split(settings.botName, " ")
 
-	ws, _, err := dg.LiveTranscription(deepgram.LiveTranscriptionOptions{
+	ws, _, err := t.deepgram.LiveTranscription(deepgram.LiveTranscriptionOptions{
 		Punctuate:       true,
 		Encoding:        "opus",
 		Sample_rate:     48000,
 		Channels:        2,
 		Interim_results: true,
 		Search:          botNameWords,
-		Keywords:        append(t.Config.Keywords, botNameWords...),
+		Keywords:        append(append([]string{}, settings.config.Keywords...), botNameWords...),
 		Model:           "phonecall",
 		Tier:            "nova",
+		// Needs a utterance_end_ms value as Deepgram only sends UtteranceEnd messages when it's set
+		Utterance_end_ms: max(t.turnDetectionConfig().UtteranceEndMs, 1000),
 	})
 
 	if err != nil {
@@ -74,6 +98,8 @@ func (t *Transcriber) NewStream(ctx context.Context, onClose func(), username st
 						onClose()
 					}
 
+					t.turns.Flush(userId)
+
 					ctx.Done()
 					return // Change this line
 				}
@@ -86,6 +112,11 @@ func (t *Transcriber) NewStream(ctx context.Context, onClose func(), username st
 
 				// log.Printf("Full Deepgram response: %s", jsonParsed.String())
 
+				if messageType, _ := jsonParsed.Path("type").Data().(string); messageType == "UtteranceEnd" {
+					t.turns.UtteranceEnd(userId)
+					continue
+				}
+
 				transcription, ok := jsonParsed.Path("channel.alternatives.0.transcript").Data().(string)
 
 				// Strip whitespace from transcription
@@ -93,7 +124,8 @@ func (t *Transcriber) NewStream(ctx context.Context, onClose func(), username st
 
 				if ok {
 					if !jsonParsed.Path("is_final").Data().(bool) && transcription != "" {
-						t.Responder.InterimTranscriptionReceived()
+						t.Responder.InterimTranscriptionReceived(transcription, speechDuration(jsonParsed), username)
+						t.turns.Speaking(userId)
 					} else {
 						// Check if the bot name was spoken
 						botNameConfidence := float64(0)
@@ -108,11 +140,15 @@ func (t *Transcriber) NewStream(ctx context.Context, onClose func(), username st
 							}
 						}
 
-						usageEvent := usage.NewTranscriptionEvent("deepgram", "nova-streaming", jsonParsed.Path("duration").Data().(float64)/60.0)
+						minutes := jsonParsed.Path("duration").Data().(float64) / 60.0
+						speechFinal, _ := jsonParsed.Path("speech_final").Data().(bool)
 
 						// Check if there are alpha numeric characters in the transcription
 						if transcription != "" {
-							t.Responder.NewTranscription(transcription, botNameConfidence, username, userId, usageEvent)
+							// The turn detector merges the segments of a turn into one line, and passes it to the responder once the turn is complete
+							t.turns.FinalSegment(transcription, speechDuration(jsonParsed), speechFinal, botNameConfidence, username, userId, minutes)
+						} else if speechFinal {
+							t.turns.UtteranceEnd(userId)
 						}
 					}
 				}
@@ -126,11 +162,31 @@ func (t *Transcriber) NewStream(ctx context.Context, onClose func(), username st
 	return ws, err
 }
 
+// speechDuration returns the time from the first to the last word of a Deepgram result
+func speechDuration(result *gabs.Container) time.Duration {
+	words := result.Path("channel.alternatives.0.words").Children()
+	if len(words) == 0 {
+		return 0
+	}
+
+	start, _ := words[0].Path("start").Data().(float64)
+	end, _ := words[len(words)-1].Path("end").Data().(float64)
+
+	return time.Duration((end - start) * float64(time.Second))
+}
+
 func (t *Transcriber) IsIgnored(userId string) bool {
-	for _, ignoredUser := range t.Config.IgnoredUsers {
+	for _, ignoredUser := range t.settings.Load().config.IgnoredUsers {
 		if userId == ignoredUser {
 			return true
 		}
 	}
 	return false
 }
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
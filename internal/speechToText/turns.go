@@ -0,0 +1,194 @@
+package speechtotext
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"com.deablabs.teno-voice/internal/usage"
+)
+
+// TurnDetectionConfig controls how long the transcriber waits before deciding a speaker has finished their turn.
+// Durations are in milliseconds, and each one left at 0 uses its default.
+type TurnDetectionConfig struct {
+	// SilenceDebounce is how long to wait after the end of an utterance before the turn is complete
+	SilenceDebounce int `validate:"min=0"`
+	// IncompleteTurnDebounce is how long to wait when the turn sounds unfinished, for example when it ends with "and"
+	IncompleteTurnDebounce int `validate:"min=0"`
+	// UtteranceEndMs is the silence after which Deepgram sends an UtteranceEnd message. Deepgram requires at least 1000.
+	UtteranceEndMs int `validate:"omitempty,min=1000"`
+}
+
+var defaultTurnDetectionConfig = TurnDetectionConfig{
+	SilenceDebounce:        500,
+	IncompleteTurnDebounce: 2000,
+	UtteranceEndMs:         1000,
+}
+
+// withDefaults returns the config with the default of each duration that isn't set
+func (c TurnDetectionConfig) withDefaults() TurnDetectionConfig {
+	if c.SilenceDebounce == 0 {
+		c.SilenceDebounce = defaultTurnDetectionConfig.SilenceDebounce
+	}
+	if c.IncompleteTurnDebounce == 0 {
+		c.IncompleteTurnDebounce = defaultTurnDetectionConfig.IncompleteTurnDebounce
+	}
+	if c.UtteranceEndMs == 0 {
+		c.UtteranceEndMs = defaultTurnDetectionConfig.UtteranceEndMs
+	}
+	return c
+}
+
+// trailingWords are words that usually mean the speaker has more to say when they end an utterance
+var trailingWords = map[string]struct{}{
+	"and": {}, "but": {}, "or": {}, "so": {}, "because": {}, "cause": {}, "then": {}, "if": {}, "when": {},
+	"while": {}, "although": {}, "though": {}, "unless": {}, "until": {}, "like": {}, "that": {}, "which": {},
+	"who": {}, "the": {}, "a": {}, "an": {}, "to": {}, "of": {}, "with": {}, "for": {}, "about": {}, "my": {},
+	"your": {}, "um": {}, "uh": {}, "umm": {}, "uhh": {}, "er": {}, "hmm": {},
+}
+
+// pendingTurn holds the final segments of a turn that hasn't been judged complete yet
+type pendingTurn struct {
+	username          string
+	segments          []string
+	botNameConfidence float64
+	minutes           float64
+	// duration is how long the speaker was talking in the turn's segments
+	duration time.Duration
+	timer    *time.Timer
+}
+
+// TurnDetector merges the final transcription segments of each speaker into turns,
+// and hands a turn to the responder once the speaker seems to have finished it
+type TurnDetector struct {
+	onTurn func(line string, duration time.Duration, botNameConfidence float64, username string, userId string, usageEvent usage.UsageEvent)
+	config func() TurnDetectionConfig
+	turns  map[string]*pendingTurn
+	mu     sync.Mutex
+}
+
+func NewTurnDetector(config func() TurnDetectionConfig, onTurn func(line string, duration time.Duration, botNameConfidence float64, username string, userId string, usageEvent usage.UsageEvent)) *TurnDetector {
+	return &TurnDetector{
+		onTurn: onTurn,
+		config: config,
+		turns:  make(map[string]*pendingTurn),
+	}
+}
+
+// Speaking is called on interim results, and holds back the speaker's pending turn while they keep talking.
+// The turn still completes if the speech never becomes a final segment and nothing else arrives for a while.
+func (d *TurnDetector) Speaking(userId string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if turn, ok := d.turns[userId]; ok {
+		turn.timer.Reset(time.Duration(d.config().IncompleteTurnDebounce) * time.Millisecond)
+	}
+}
+
+// FinalSegment adds a final segment to the speaker's turn. speechFinal is Deepgram's endpointing signal that the speaker paused,
+// and duration is how long the segment's speech lasted.
+func (d *TurnDetector) FinalSegment(segment string, duration time.Duration, speechFinal bool, botNameConfidence float64, username string, userId string, minutes float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	turn, ok := d.turns[userId]
+	if !ok {
+		turn = &pendingTurn{
+			username: username,
+		}
+		turn.timer = time.AfterFunc(time.Hour, func() {
+			d.complete(userId, turn)
+		})
+		d.turns[userId] = turn
+	}
+
+	turn.segments = append(turn.segments, segment)
+	turn.minutes += minutes
+	turn.duration += duration
+	if botNameConfidence > turn.botNameConfidence {
+		turn.botNameConfidence = botNameConfidence
+	}
+
+	config := d.config()
+	if speechFinal {
+		turn.timer.Reset(endOfTurnDelay(config, turn.text()))
+	} else {
+		// Without an endpoint the speaker is probably still talking, so only complete the turn
+		// if neither more speech nor an UtteranceEnd arrives for a while
+		turn.timer.Reset(time.Duration(config.IncompleteTurnDebounce+config.UtteranceEndMs) * time.Millisecond)
+	}
+}
+
+// UtteranceEnd is called when Deepgram reports a gap in speech after the speaker's last final word
+func (d *TurnDetector) UtteranceEnd(userId string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if turn, ok := d.turns[userId]; ok {
+		turn.timer.Reset(endOfTurnDelay(d.config(), turn.text()))
+	}
+}
+
+// Flush completes the speaker's pending turn immediately, for when their stream closes
+func (d *TurnDetector) Flush(userId string) {
+	d.mu.Lock()
+	turn, ok := d.turns[userId]
+	d.mu.Unlock()
+
+	if ok {
+		d.complete(userId, turn)
+	}
+}
+
+func (d *TurnDetector) complete(userId string, turn *pendingTurn) {
+	d.mu.Lock()
+	if d.turns[userId] != turn {
+		// The turn was already completed
+		d.mu.Unlock()
+		return
+	}
+	turn.timer.Stop()
+	delete(d.turns, userId)
+	d.mu.Unlock()
+
+	usageEvent := usage.NewTranscriptionEvent("deepgram", "nova-streaming", turn.minutes)
+	d.onTurn(turn.text(), turn.duration, turn.botNameConfidence, turn.username, userId, usageEvent)
+}
+
+func (t *pendingTurn) text() string {
+	return strings.Join(t.segments, " ")
+}
+
+// endOfTurnDelay returns how long to wait after a pause before the turn is complete.
+// Questions are answered right away, and turns that sound unfinished get more time.
+func endOfTurnDelay(config TurnDetectionConfig, text string) time.Duration {
+	switch {
+	case strings.HasSuffix(text, "?"):
+		return 0
+	case soundsUnfinished(text):
+		return time.Duration(config.IncompleteTurnDebounce) * time.Millisecond
+	default:
+		return time.Duration(config.SilenceDebounce) * time.Millisecond
+	}
+}
+
+// soundsUnfinished checks if text ends with a comma, an ellipsis or a word that is usually followed by more words
+func soundsUnfinished(text string) bool {
+	if strings.HasSuffix(text, ",") || strings.HasSuffix(text, "...") || strings.HasSuffix(text, "-") {
+		return true
+	}
+
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return false
+	}
+
+	lastWord := strings.ToLower(strings.TrimFunc(words[len(words)-1], func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}))
+
+	_, ok := trailingWords[lastWord]
+	return ok
+}
@@ -0,0 +1,73 @@
+package speechtotext
+
+import (
+	"testing"
+	"time"
+
+	"com.deablabs.teno-voice/internal/usage"
+)
+
+func TestTurnDetectionConfigDefaults(t *testing.T) {
+	tests := []struct {
+		config TurnDetectionConfig
+		want   TurnDetectionConfig
+	}{
+		{TurnDetectionConfig{}, defaultTurnDetectionConfig},
+		{TurnDetectionConfig{SilenceDebounce: 300}, TurnDetectionConfig{SilenceDebounce: 300, IncompleteTurnDebounce: 2000, UtteranceEndMs: 1000}},
+		{TurnDetectionConfig{IncompleteTurnDebounce: 3000}, TurnDetectionConfig{SilenceDebounce: 500, IncompleteTurnDebounce: 3000, UtteranceEndMs: 1000}},
+		{TurnDetectionConfig{UtteranceEndMs: 1500}, TurnDetectionConfig{SilenceDebounce: 500, IncompleteTurnDebounce: 2000, UtteranceEndMs: 1500}},
+	}
+
+	for _, test := range tests {
+		if got := test.config.withDefaults(); got != test.want {
+			t.Errorf("%+v.withDefaults() = %+v, want %+v", test.config, got, test.want)
+		}
+	}
+}
+
+type turn struct {
+	line     string
+	duration time.Duration
+}
+
+func newTestTurnDetector(config TurnDetectionConfig) (*TurnDetector, chan turn) {
+	turns := make(chan turn, 10)
+	detector := NewTurnDetector(func() TurnDetectionConfig { return config }, func(line string, duration time.Duration, botNameConfidence float64, username string, userId string, usageEvent usage.UsageEvent) {
+		turns <- turn{line: line, duration: duration}
+	})
+	return detector, turns
+}
+
+func TestTurnDetectorSegments(t *testing.T) {
+	detector, turns := newTestTurnDetector(TurnDetectionConfig{SilenceDebounce: 10, IncompleteTurnDebounce: 50, UtteranceEndMs: 1000})
+
+	detector.FinalSegment("I was thinking", time.Second, true, 0, "alice", "1", 0)
+	detector.FinalSegment("we could meet", 2*time.Second, true, 0, "alice", "1", 0)
+
+	select {
+	case got := <-turns:
+		if got.line != "I was thinking we could meet" || got.duration != 3*time.Second {
+			t.Fatalf("got turn %+v, want the merged segments", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the turn didn't complete")
+	}
+}
+
+func TestTurnDetectorInterimThenSilence(t *testing.T) {
+	detector, turns := newTestTurnDetector(TurnDetectionConfig{SilenceDebounce: 10, IncompleteTurnDebounce: 50, UtteranceEndMs: 1000})
+
+	detector.FinalSegment("so I was thinking", time.Second, false, 0, "alice", "1", 0)
+	// An interim result that never becomes a final segment, and then nothing
+	detector.Speaking("1")
+
+	// The turn completes after the incomplete turn debounce, well before the wait for a segment without an endpoint
+	select {
+	case got := <-turns:
+		if got.line != "so I was thinking" {
+			t.Fatalf("got turn %q, want %q", got.line, "so I was thinking")
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("the turn didn't complete after the speaker went silent")
+	}
+}
@@ -0,0 +1,197 @@
+package azure
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"com.deablabs.teno-voice/internal/textToSpeech/wordtimings"
+	"com.deablabs.teno-voice/internal/usage"
+	"github.com/gorilla/websocket"
+)
+
+// The websocket endpoint is what the Speech SDK uses. Unlike the REST endpoint, it reports word boundary events.
+const ttsWebsocketEndpoint = "wss://" + region + ".tts.speech.microsoft.com/cognitiveservices/websocket/v1"
+
+// Audio offsets in word boundary events are in ticks of 100 nanoseconds
+const tick = 100 * time.Nanosecond
+
+type wordBoundaryMetadata struct {
+	Metadata []struct {
+		Type string
+		Data struct {
+			Offset   int64
+			Duration int64
+			Text     struct {
+				Text    string
+				BoxType string
+			} `json:"text"`
+		}
+	}
+}
+
+// SynthesizeWithWordTimings synthesizes text like Synthesize, and also reports when each word is spoken in the audio.
+// The word timings are filled in as the audio streams in.
+func (a *AzureTTS) SynthesizeWithWordTimings(text string) (io.ReadCloser, *wordtimings.WordTimings, usage.UsageEvent, error) {
+	token, err := a.getAccessToken()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ssml := SSML{
+		Version: "1.0",
+		Lang:    a.Config.Language,
+		Voice: Voice{
+			Lang:   a.Config.Language,
+			Name:   a.Config.VoiceID,
+			Gender: a.Config.Gender,
+			Text:   text,
+		},
+	}
+
+	ssmlBytes, err := xml.Marshal(ssml)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	connectionID := newID()
+	header := http.Header{
+		"Authorization":  []string{"Bearer " + token},
+		"X-ConnectionId": []string{connectionID},
+	}
+
+	ws, _, err := websocket.DefaultDialer.Dial(ttsWebsocketEndpoint+"?X-ConnectionId="+connectionID, header)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error connecting to azure tts websocket: %s", err)
+	}
+
+	requestID := newID()
+	metadataOptions := `{"sentenceBoundaryEnabled":false,"wordBoundaryEnabled":true,"punctuationBoundaryEnabled":false,"sessionEndEnabled":true}`
+
+	messages := []string{
+		websocketMessage("speech.config", "", "application/json",
+			`{"context":{"synthesis":{"audio":{"metadataoptions":`+metadataOptions+`,"outputFormat":"ogg-48khz-16bit-mono-opus"}}}}`),
+		websocketMessage("synthesis.context", requestID, "application/json",
+			`{"synthesis":{"audio":{"metadataOptions":`+metadataOptions+`,"outputFormat":"ogg-48khz-16bit-mono-opus"},"language":{"autoDetection":false}}}`),
+		websocketMessage("ssml", requestID, "application/ssml+xml", string(ssmlBytes)),
+	}
+
+	for _, message := range messages {
+		if err := ws.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			ws.Close()
+			return nil, nil, nil, fmt.Errorf("error sending azure tts request: %s", err)
+		}
+	}
+
+	audioReader, audioWriter := io.Pipe()
+	timings := wordtimings.NewWordTimings()
+
+	go receiveSynthesis(ws, audioWriter, timings)
+
+	opusReader := NewOpusPacketReader(audioReader)
+
+	usageEvent := usage.NewTextToSpeechEvent("azure", a.Config.Model, len(text))
+
+	return opusReader, timings, usageEvent, nil
+}
+
+// receiveSynthesis writes the audio received on the websocket to audioWriter, and adds word boundaries to timings,
+// until the synthesis ends or the audio reader is closed
+func receiveSynthesis(ws *websocket.Conn, audioWriter *io.PipeWriter, timings *wordtimings.WordTimings) {
+	defer ws.Close()
+
+	for {
+		messageType, message, err := ws.ReadMessage()
+		if err != nil {
+			audioWriter.CloseWithError(err)
+			return
+		}
+
+		switch messageType {
+		case websocket.TextMessage:
+			headers, body, _ := strings.Cut(string(message), "\r\n\r\n")
+
+			switch messagePath(headers) {
+			case "audio.metadata":
+				var metadata wordBoundaryMetadata
+				if err := json.Unmarshal([]byte(body), &metadata); err != nil {
+					continue
+				}
+				for _, item := range metadata.Metadata {
+					if item.Type != "WordBoundary" || item.Data.Text.BoxType == "Punctuation" {
+						continue
+					}
+					timings.Add(wordtimings.WordBoundary{
+						Text:     item.Data.Text.Text,
+						Offset:   time.Duration(item.Data.Offset) * tick,
+						Duration: time.Duration(item.Data.Duration) * tick,
+					})
+				}
+			case "turn.end":
+				audioWriter.Close()
+				return
+			}
+		case websocket.BinaryMessage:
+			// Binary messages start with the length of their headers as a big endian uint16
+			if len(message) < 2 {
+				continue
+			}
+			headerLength := int(binary.BigEndian.Uint16(message[:2]))
+			if len(message) < 2+headerLength {
+				continue
+			}
+
+			if messagePath(string(message[2:2+headerLength])) != "audio" {
+				continue
+			}
+
+			audio := message[2+headerLength:]
+			if len(audio) == 0 {
+				continue
+			}
+
+			if _, err := audioWriter.Write(audio); err != nil {
+				// The audio reader was closed, so nobody wants the rest of the synthesis
+				return
+			}
+		}
+	}
+}
+
+func websocketMessage(path string, requestID string, contentType string, body string) string {
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "Path:%s\r\n", path)
+	if requestID != "" {
+		fmt.Fprintf(&message, "X-RequestId:%s\r\n", requestID)
+	}
+	fmt.Fprintf(&message, "X-Timestamp:%s\r\n", time.Now().UTC().Format("2006-01-02T15:04:05.000Z"))
+	fmt.Fprintf(&message, "Content-Type:%s\r\n\r\n", contentType)
+	message.WriteString(body)
+	return message.String()
+}
+
+// messagePath returns the Path header of a websocket message
+func messagePath(headers string) string {
+	for _, header := range strings.Split(headers, "\r\n") {
+		name, value, ok := strings.Cut(header, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Path") {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// newID returns a random ID in the format the Speech service uses for connection and request IDs
+func newID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 
 	"com.deablabs.teno-voice/internal/textToSpeech/azure"
+	"com.deablabs.teno-voice/internal/textToSpeech/wordtimings"
 	"com.deablabs.teno-voice/internal/usage"
 	"github.com/go-playground/validator/v10"
 )
@@ -19,6 +20,13 @@ type TextToSpeechService interface {
 	Synthesize(text string) (io.ReadCloser, usage.UsageEvent, error)
 }
 
+// WordTimingService is implemented by services that can also report when each word is spoken in the audio they
+// synthesize, so an interrupted sentence can be cut off at the last word that was actually heard
+type WordTimingService interface {
+	TextToSpeechService
+	SynthesizeWithWordTimings(text string) (io.ReadCloser, *wordtimings.WordTimings, usage.UsageEvent, error)
+}
+
 func TTSConfigValidation(fl validator.FieldLevel) bool {
 	config, ok := fl.Field().Interface().(TTSConfigPayload)
 	if !ok {
@@ -53,6 +61,28 @@ func TTSConfigValidation(fl validator.FieldLevel) bool {
 	return true
 }
 
+// WithVoice returns a copy of the service that speaks with another voice
+func WithVoice(service TextToSpeechService, voiceID string) (TextToSpeechService, error) {
+	switch service := service.(type) {
+	case *azure.AzureTTS:
+		config := service.Config
+		config.VoiceID = voiceID
+		return azure.NewAzureTTS(config), nil
+	default:
+		return nil, fmt.Errorf("the text to speech service can't change voices")
+	}
+}
+
+// ConfigPayload returns the config the service was made with, the reverse of ParseTTSConfig
+func ConfigPayload(service TextToSpeechService) (TTSConfigPayload, bool) {
+	switch service := service.(type) {
+	case *azure.AzureTTS:
+		return TTSConfigPayload{TTSServiceName: "azure", TTSConfig: service.Config}, true
+	default:
+		return TTSConfigPayload{}, false
+	}
+}
+
 func ParseTTSConfig(payload TTSConfigPayload) (TextToSpeechService, error) {
 	switch payload.TTSServiceName {
 	case "azure":
@@ -0,0 +1,47 @@
+package wordtimings
+
+import (
+	"sync"
+	"time"
+)
+
+// WordBoundary is where a word is in synthesized audio, relative to the start of the audio
+type WordBoundary struct {
+	Text     string
+	Offset   time.Duration
+	Duration time.Duration
+}
+
+// WordTimings collects the word boundaries of one synthesis. Providers add boundaries while the audio
+// streams in, and the player reads them when playback is cut off.
+type WordTimings struct {
+	boundaries []WordBoundary
+	mu         sync.Mutex
+}
+
+func NewWordTimings() *WordTimings {
+	return &WordTimings{}
+}
+
+func (t *WordTimings) Add(boundary WordBoundary) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.boundaries = append(t.boundaries, boundary)
+}
+
+// WordsSpoken returns how many words were spoken in full within the first played duration of the audio
+func (t *WordTimings) WordsSpoken(played time.Duration) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	words := 0
+	for _, boundary := range t.boundaries {
+		if boundary.Offset+boundary.Duration > played {
+			break
+		}
+		words++
+	}
+
+	return words
+}
@@ -0,0 +1,84 @@
+package transcript
+
+import "time"
+
+// SummaryConfig turns on the rolling summary, which keeps the gist of the lines that fall out of the transcript
+type SummaryConfig struct {
+	// Model is the model that writes the summary, the call's model by default
+	Model string
+	// BatchLines is how many dropped lines are folded into the summary at once, 10 by default
+	BatchLines int `validate:"min=0"`
+	// MaxWords is roughly how long the summary can get, 300 by default
+	MaxWords int `validate:"min=0"`
+}
+
+// Summary is the rolling summary of the part of the call that is no longer in the transcript.
+// It is sent on the event stream as a "summary" event whenever it changes.
+type Summary struct {
+	Text string
+	// LinesSummarized counts the lines folded into the summary so far
+	LinesSummarized int
+	UpdatedAt       time.Time
+}
+
+// Summary returns the current rolling summary
+func (t *Transcript) Summary() Summary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.summary
+}
+
+// SetSummary replaces the rolling summary with one that folds in lines more lines
+func (t *Transcript) SetSummary(text string, lines int) Summary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.summary = Summary{
+		Text:            text,
+		LinesSummarized: t.summary.LinesSummarized + lines,
+		UpdatedAt:       time.Now(),
+	}
+	return t.summary
+}
+
+// LinesDropped is signalled when lines fall out of the transcript while the summary is on
+func (t *Transcript) LinesDropped() <-chan struct{} {
+	return t.linesDropped
+}
+
+// TakeDroppedLines returns the lines that fell out of the transcript since the last call, once there are at least batch of them
+func (t *Transcript) TakeDroppedLines(batch int) []Line {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.dropped) == 0 || len(t.dropped) < batch {
+		return nil
+	}
+
+	lines := t.dropped
+	t.dropped = nil
+	return lines
+}
+
+// ReturnDroppedLines puts back lines taken with TakeDroppedLines that couldn't be summarized,
+// ahead of the lines dropped since, so they are retried with the next batch
+func (t *Transcript) ReturnDroppedLines(lines []Line) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.dropped = append(append([]Line(nil), lines...), t.dropped...)
+}
+
+// drop keeps the lines that fall out of the transcript for the summary, if it is on. The caller must hold t.mu.
+func (t *Transcript) drop(lines []Line) {
+	if t.config.Summary == nil || len(lines) == 0 {
+		return
+	}
+
+	t.dropped = append(t.dropped, lines...)
+	select {
+	case t.linesDropped <- struct{}{}:
+	default:
+	}
+}
@@ -19,12 +19,20 @@ type Transcript struct {
 	transcriptSSEChannel chan string
 	redisClient          redis.Client
 	transcriptKey        string
-	Config               TranscriptConfig
+	config               TranscriptConfig
 	mu                   sync.Mutex
+	// summary, dropped and linesDropped are used by the rolling summary
+	summary      Summary
+	dropped      []Line
+	linesDropped chan struct{}
 }
 
 type TranscriptConfig struct {
 	NumberOfTranscriptLines int `validate:"required"`
+	// Add a line to the transcript when someone joins or leaves the voice channel
+	PresenceLines bool
+	// Summary folds the lines that fall out of the transcript into a rolling summary, which is added to the prompt
+	Summary *SummaryConfig
 }
 
 type Line struct {
@@ -42,7 +50,28 @@ func NewTranscript(transcriptSSEChannel chan string, redisClient *redis.Client,
 		transcriptSSEChannel: transcriptSSEChannel,
 		redisClient:          *redisClient,
 		transcriptKey:        transcriptKey,
-		Config:               config,
+		config:               config,
+		linesDropped:         make(chan struct{}, 1),
+	}
+}
+
+// Config returns a copy of the current config
+func (t *Transcript) Config() TranscriptConfig {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.config
+}
+
+// SetConfig replaces the config, dropping the oldest lines if there are more than the new NumberOfTranscriptLines
+func (t *Transcript) SetConfig(config TranscriptConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.config = config
+	if len(t.lines) > config.NumberOfTranscriptLines {
+		t.drop(t.lines[:len(t.lines)-config.NumberOfTranscriptLines])
+		t.lines = t.lines[len(t.lines)-config.NumberOfTranscriptLines:]
 	}
 }
 
@@ -63,7 +92,8 @@ func (t *Transcript) addLine(line *Line) {
 	defer t.mu.Unlock()
 
 	// If the slice has reached the max limit from config, remove the oldest element before appending.
-	if len(t.lines) >= t.Config.NumberOfTranscriptLines {
+	if len(t.lines) >= t.config.NumberOfTranscriptLines {
+		t.drop(t.lines[:1])
 		t.lines = t.lines[1:]
 	}
 
@@ -108,6 +138,23 @@ func (t *Transcript) AddInterruptionLine(username string, botName string) {
 	t.addLine(newLine)
 }
 
+func (t *Transcript) AddPresenceLine(displayName string, joined bool) {
+	text := fmt.Sprintf("[%s left the channel]", displayName)
+	if joined {
+		text = fmt.Sprintf("[%s joined the channel]", displayName)
+	}
+
+	newLine := &Line{
+		Text:     text,
+		Username: "",
+		UserId:   "",
+		Type:     "system",
+		Time:     time.Now(),
+	}
+
+	t.addLine(newLine)
+}
+
 func (t *Transcript) AddTaskReminderLine(task string) {
 	text := "[Only visible to you] Complete the task: " + task
 
@@ -122,6 +169,34 @@ func (t *Transcript) AddTaskReminderLine(task string) {
 	t.addLine(newLine)
 }
 
+func (t *Transcript) AddScheduledInstructionLine(instruction string) {
+	text := "[Only visible to you] Scheduled instruction: " + instruction
+
+	newLine := &Line{
+		Text:     text,
+		Username: "",
+		UserId:   "",
+		Type:     "system",
+		Time:     time.Now(),
+	}
+
+	t.addLine(newLine)
+}
+
+func (t *Transcript) AddJobScheduledLine(jobID string, when string) {
+	text := fmt.Sprintf("[Only visible to you] Job %s scheduled, first running %s.", jobID, when)
+
+	newLine := &Line{
+		Text:     text,
+		Username: "",
+		UserId:   "",
+		Type:     "system",
+		Time:     time.Now(),
+	}
+
+	t.addLine(newLine)
+}
+
 func (t *Transcript) AddNewDocumentAlertLine(newDocumentNames []string) {
 	// Combine all document names into a single string separated by commas
 	documentNames := strings.Join(newDocumentNames, ", ")
@@ -153,6 +228,34 @@ func (t *Transcript) AddToolMessageLine(toolMessage string) {
 	t.addLine(newLine)
 }
 
+func (t *Transcript) AddToolRejectionLine(toolName string, reason string) {
+	text := fmt.Sprintf("[Only visible to you] Your call to the tool '%s' was rejected: %s. Call it again with a corrected input.", toolName, reason)
+
+	newLine := &Line{
+		Text:     text,
+		Username: "",
+		UserId:   "",
+		Type:     "system",
+		Time:     time.Now(),
+	}
+
+	t.addLine(newLine)
+}
+
+func (t *Transcript) AddToolCancelledLine(toolName string, reason string) {
+	text := fmt.Sprintf("[Only visible to you] Your call to the tool '%s' was cancelled because %s.", toolName, reason)
+
+	newLine := &Line{
+		Text:     text,
+		Username: "",
+		UserId:   "",
+		Type:     "system",
+		Time:     time.Now(),
+	}
+
+	t.addLine(newLine)
+}
+
 func (t *Transcript) GetTranscriptString() string {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -6,13 +6,24 @@ type UsageEvent interface {
 	UsageType() string
 }
 
-// usageEventToJSON converts a UsageEvent to a JSON string
+// usageEventToJSON converts a UsageEvent to a JSON string, including its UsageType so listeners can tell events apart
 func UsageEventToJSON(event UsageEvent) (string, error) {
 	jsonEvent, err := json.Marshal(event)
 	if err != nil {
 		return "", err
 	}
 
+	var fields map[string]interface{}
+	if err := json.Unmarshal(jsonEvent, &fields); err != nil {
+		return "", err
+	}
+	fields["UsageType"] = event.UsageType()
+
+	jsonEvent, err = json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+
 	return string(jsonEvent), nil
 }
 
@@ -54,16 +65,28 @@ func (t *TranscriptionEvent) IsEmpty() bool {
 	return *t == TranscriptionEvent{}
 }
 
+// What the tokens of an LLM event were spent on
+const (
+	PurposeResponse   = "response"
+	PurposeSummary    = "summary"
+	PurposeAddressee  = "addressee"
+	PurposeEmbeddings = "embeddings"
+)
+
 type LLMEvent struct {
 	Service          string
 	Model            string
 	PromptTokens     int
 	CompletionTokens int
+	// PromptTokensBySection breaks the prompt tokens down by section of the prompt, like the transcript or documents
+	PromptTokensBySection map[string]int `json:",omitempty"`
+	// Purpose is what the tokens were spent on, one of the Purpose constants
+	Purpose string
 	// other common fields...
 }
 
-func NewLLMEvent(service string, model string, promptTokens int, completionTokens int) *LLMEvent {
-	return &LLMEvent{Service: service, Model: model, PromptTokens: promptTokens, CompletionTokens: completionTokens}
+func NewLLMEvent(service string, purpose string, model string, promptTokens int, completionTokens int) *LLMEvent {
+	return &LLMEvent{Service: service, Purpose: purpose, Model: model, PromptTokens: promptTokens, CompletionTokens: completionTokens}
 }
 
 func (l *LLMEvent) SetCompletionTokens(tokens int) {
@@ -71,7 +94,7 @@ func (l *LLMEvent) SetCompletionTokens(tokens int) {
 }
 
 func (l *LLMEvent) IsEmpty() bool {
-	return *l == LLMEvent{}
+	return l.Service == "" && l.Purpose == "" && l.Model == "" && l.PromptTokens == 0 && l.CompletionTokens == 0 && len(l.PromptTokensBySection) == 0
 }
 
 func (l LLMEvent) UsageType() string {
@@ -9,8 +9,11 @@ import (
 	Config "com.deablabs.teno-voice/internal/config"
 	"com.deablabs.teno-voice/internal/deps"
 	"com.deablabs.teno-voice/internal/llm"
+	"com.deablabs.teno-voice/internal/openapi"
 	"com.deablabs.teno-voice/internal/redis"
+	"com.deablabs.teno-voice/internal/responder/tools"
 	texttospeech "com.deablabs.teno-voice/internal/textToSpeech"
+	"com.deablabs.teno-voice/pkg/deepgram"
 	"github.com/disgoorg/log"
 	"github.com/go-chi/chi"
 	"github.com/go-playground/validator/v10"
@@ -26,6 +29,7 @@ func main() {
 
 	validate.RegisterValidation("LLMConfigValidation", llm.LLMConfigValidation)
 	validate.RegisterValidation("TTSConfigValidation", texttospeech.TTSConfigValidation)
+	validate.RegisterValidation("ToolInputSchema", tools.InputSchemaValidation)
 
 	log.Info("starting up")
 
@@ -38,23 +42,58 @@ func main() {
 	// create a new instance of the Deps struct
 	// We pass this struct into the handlers so they can access the discord client
 	// and kill signal
-	dependencies := &deps.Deps{RedisClient: redisClient, Validate: validate}
+	dependencies := &deps.Deps{
+		RedisClient: redisClient,
+		Validate:    validate,
+		Deepgram:    deepgram.NewClient(Config.Environment.DeepgramToken),
+	}
 
 	// Set up the router, connected to discord functionality
 	router := chi.NewRouter()
-	router.Use(auth.ApiKeyAuthMiddleware(Config.Environment.ApiKey))
-	// Accepts join request and joins the voice channel
-	router.Post("/join", calls.JoinVoiceChannel(dependencies))
-	// Accepts leave request and leaves the voice channel
-	router.Post("/{bot_id}/{guild_id}/leave", calls.LeaveVoiceChannel(dependencies))
-	// Accepts a Config object and sets the responder config
-	router.Post("/{bot_id}/{guild_id}/config", calls.UpdateConfig(dependencies))
-	// Subscribes to the transcript SSE stream, which sends lines of the transcript as strings when new lines are available
-	router.Get("/{bot_id}/{guild_id}/transcript", calls.TranscriptSSEHandler(dependencies))
-	// Subscribes to the tool messages SSE stream, which sends tool messages as strings when the responder sends them
-	router.Get("/{bot_id}/{guild_id}/tool-messages", calls.ToolMessagesSSEHandler(dependencies))
-	// Subscribes to the usages SSE stream, which sends tts, transcription, and llm usage events as strings when the responder sends them
-	router.Get("/{bot_id}/{guild_id}/service-usages", calls.UsageSSEHandler(dependencies))
+	// Serves the OpenAPI document describing this API, without authentication
+	router.Get("/openapi.json", openapi.Handler())
+
+	router.Group(func(router chi.Router) {
+		router.Use(auth.ApiKeyAuthMiddleware(Config.Environment.ApiKey))
+		// Lists the ongoing calls
+		router.Get("/calls", calls.ListCallsHandler(dependencies))
+		// Accepts join request and joins the voice channel
+		router.Post("/join", calls.JoinVoiceChannel(dependencies))
+		// Accepts leave request and leaves the voice channel
+		router.Post("/{bot_id}/{guild_id}/leave", calls.LeaveVoiceChannel(dependencies))
+		// Accepts a Config object and sets the responder config
+		router.Post("/{bot_id}/{guild_id}/config", calls.UpdateConfig(dependencies))
+		// Makes the bot speak the given text
+		router.Post("/{bot_id}/{guild_id}/say", calls.SayHandler(dependencies))
+		// Makes the bot respond to the transcript now
+		router.Post("/{bot_id}/{guild_id}/respond", calls.RespondHandler(dependencies))
+		// Stops the bot's current response
+		router.Post("/{bot_id}/{guild_id}/interrupt", calls.InterruptHandler(dependencies))
+		// Returns the tool invocations waiting to be confirmed
+		router.Get("/{bot_id}/{guild_id}/confirmations", calls.ConfirmationsHandler(dependencies))
+		// Confirms or declines a tool invocation waiting to be confirmed
+		router.Post("/{bot_id}/{guild_id}/confirmations/{confirmation_id}", calls.ResolveConfirmationHandler(dependencies))
+		// Returns the rolling summary of the part of the call that is no longer in the transcript
+		router.Get("/{bot_id}/{guild_id}/summary", calls.SummaryHandler(dependencies))
+		// Returns the call's tasks with their statuses
+		router.Get("/{bot_id}/{guild_id}/tasks", calls.TasksHandler(dependencies))
+		// Returns the jobs scheduled in the call
+		router.Get("/{bot_id}/{guild_id}/jobs", calls.JobsHandler(dependencies))
+		// Schedules a job in the call
+		router.Post("/{bot_id}/{guild_id}/jobs", calls.ScheduleJobHandler(dependencies))
+		// Cancels a scheduled job
+		router.Delete("/{bot_id}/{guild_id}/jobs/{job_id}", calls.CancelJobHandler(dependencies))
+		// Renders the prompt the bot would respond to now, without calling the LLM
+		router.Get("/{bot_id}/{guild_id}/prompt", calls.PromptHandler(dependencies))
+		// Returns the participants currently in the call's voice channel
+		router.Get("/{bot_id}/{guild_id}/participants", calls.ParticipantsHandler(dependencies))
+		// Subscribes to the transcript SSE stream, which sends lines of the transcript as strings when new lines are available
+		router.Get("/{bot_id}/{guild_id}/transcript", calls.TranscriptSSEHandler(dependencies))
+		// Subscribes to the tool messages SSE stream, which sends tool messages as strings when the responder sends them
+		router.Get("/{bot_id}/{guild_id}/tool-messages", calls.ToolMessagesSSEHandler(dependencies))
+		// Subscribes to the usages SSE stream, which sends tts, transcription, and llm usage events as strings when the responder sends them
+		router.Get("/{bot_id}/{guild_id}/service-usages", calls.UsageSSEHandler(dependencies))
+	})
 
 	// Start the REST API server
 	log.Info("Starting REST API server on :8080")
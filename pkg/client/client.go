@@ -0,0 +1,246 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client is a typed client for the teno-voice REST API
+type Client struct {
+	ApiKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewClient(baseURL string, apiKey string) *Client {
+	return &Client{
+		ApiKey:     apiKey,
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// APIError is returned when the API responds with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("teno-voice API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// Join joins the voice channel in the request, and returns the API's confirmation message
+func (c *Client) Join(ctx context.Context, joinReq JoinRequest) (string, error) {
+	return c.postText(ctx, "/join", joinReq)
+}
+
+// Leave leaves the voice channel the bot is in for the guild, and returns the API's confirmation message
+func (c *Client) Leave(ctx context.Context, botID string, guildID string) (string, error) {
+	return c.postText(ctx, callPath(botID, guildID, "leave"), nil)
+}
+
+// UpdateConfig replaces the non-nil sections of the config of an ongoing call
+func (c *Client) UpdateConfig(ctx context.Context, botID string, guildID string, config Config) error {
+	_, err := c.postText(ctx, callPath(botID, guildID, "config"), config)
+	return err
+}
+
+// ListCalls returns the ongoing calls
+func (c *Client) ListCalls(ctx context.Context) ([]CallInfo, error) {
+	var callInfos []CallInfo
+	err := c.getJSON(ctx, "/calls", &callInfos)
+	return callInfos, err
+}
+
+// Say makes the bot speak the given text, interrupting anything it is saying
+func (c *Client) Say(ctx context.Context, botID string, guildID string, text string) error {
+	_, err := c.postText(ctx, callPath(botID, guildID, "say"), struct{ Text string }{Text: text})
+	return err
+}
+
+// Respond asks the bot to respond to the transcript now
+func (c *Client) Respond(ctx context.Context, botID string, guildID string) error {
+	_, err := c.postText(ctx, callPath(botID, guildID, "respond"), nil)
+	return err
+}
+
+// Interrupt stops the bot's current response
+func (c *Client) Interrupt(ctx context.Context, botID string, guildID string) error {
+	_, err := c.postText(ctx, callPath(botID, guildID, "interrupt"), nil)
+	return err
+}
+
+// Summary returns the rolling summary of the part of the call that is no longer in the transcript
+func (c *Client) Summary(ctx context.Context, botID string, guildID string) (*Summary, error) {
+	var summary Summary
+	if err := c.getJSON(ctx, callPath(botID, guildID, "summary"), &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// Tasks returns the call's tasks with their statuses
+func (c *Client) Tasks(ctx context.Context, botID string, guildID string) ([]Task, error) {
+	var tasks []Task
+	err := c.getJSON(ctx, callPath(botID, guildID, "tasks"), &tasks)
+	return tasks, err
+}
+
+// Jobs returns the jobs scheduled in the call, the next to run first
+func (c *Client) Jobs(ctx context.Context, botID string, guildID string) ([]Job, error) {
+	var jobs []Job
+	err := c.getJSON(ctx, callPath(botID, guildID, "jobs"), &jobs)
+	return jobs, err
+}
+
+// ScheduleJob schedules a job in the call, and returns it
+func (c *Client) ScheduleJob(ctx context.Context, botID string, guildID string, spec JobSpec) (*Job, error) {
+	var job Job
+	if err := c.postJSON(ctx, callPath(botID, guildID, "jobs"), spec, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CancelJob cancels a scheduled job
+func (c *Client) CancelJob(ctx context.Context, botID string, guildID string, jobID string) error {
+	res, err := c.do(ctx, http.MethodDelete, callPath(botID, guildID, "jobs/"+url.PathEscape(jobID)), nil, "text/plain")
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// Prompt renders the prompt the bot would respond to now, without calling the LLM
+func (c *Client) Prompt(ctx context.Context, botID string, guildID string) (*RenderedPrompt, error) {
+	var prompt RenderedPrompt
+	if err := c.getJSON(ctx, callPath(botID, guildID, "prompt"), &prompt); err != nil {
+		return nil, err
+	}
+	return &prompt, nil
+}
+
+// Participants returns the users in the call's voice channel
+func (c *Client) Participants(ctx context.Context, botID string, guildID string) ([]Participant, error) {
+	var participants []Participant
+	err := c.getJSON(ctx, callPath(botID, guildID, "participants"), &participants)
+	return participants, err
+}
+
+// Confirmations returns the tool invocations waiting to be confirmed
+func (c *Client) Confirmations(ctx context.Context, botID string, guildID string) ([]PendingConfirmation, error) {
+	var confirmations []PendingConfirmation
+	err := c.getJSON(ctx, callPath(botID, guildID, "confirmations"), &confirmations)
+	return confirmations, err
+}
+
+// ResolveConfirmation confirms or declines a tool invocation waiting to be confirmed
+func (c *Client) ResolveConfirmation(ctx context.Context, botID string, guildID string, confirmationID string, confirmed bool) error {
+	_, err := c.postText(ctx, callPath(botID, guildID, "confirmations/"+url.PathEscape(confirmationID)), struct{ Confirmed bool }{Confirmed: confirmed})
+	return err
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, target interface{}) error {
+	res, err := c.do(ctx, http.MethodGet, path, nil, "application/json")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(target); err != nil {
+		return fmt.Errorf("error decoding response: %s", err)
+	}
+
+	return nil
+}
+
+func (c *Client) postJSON(ctx context.Context, path string, body interface{}, target interface{}) error {
+	res, err := c.do(ctx, http.MethodPost, path, body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(target); err != nil {
+		return fmt.Errorf("error decoding response: %s", err)
+	}
+
+	return nil
+}
+
+func (c *Client) postText(ctx context.Context, path string, body interface{}) (string, error) {
+	res, err := c.do(ctx, http.MethodPost, path, body, "text/plain")
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	text, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(text), nil
+}
+
+// do sends a request to the API and returns the response if its status is 2xx, or an APIError otherwise
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, accept string) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling request body: %s", err)
+		}
+		reqBody = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.ApiKey)
+	req.Header.Set("Accept", accept)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+		return nil, readAPIError(res)
+	}
+
+	return res, nil
+}
+
+func readAPIError(res *http.Response) error {
+	body, _ := io.ReadAll(res.Body)
+
+	var errorResponse struct {
+		Error string
+	}
+	if err := json.Unmarshal(body, &errorResponse); err != nil || errorResponse.Error == "" {
+		errorResponse.Error = strings.TrimSpace(string(body))
+	}
+
+	return &APIError{
+		StatusCode: res.StatusCode,
+		Message:    errorResponse.Error,
+	}
+}
+
+func callPath(botID string, guildID string, endpoint string) string {
+	return "/" + url.PathEscape(botID) + "/" + url.PathEscape(guildID) + "/" + endpoint
+}
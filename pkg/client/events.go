@@ -0,0 +1,221 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	minReconnectDelay = 500 * time.Millisecond
+	maxReconnectDelay = 10 * time.Second
+	// Give up after this many failed connection attempts in a row
+	maxReconnectAttempts = 10
+)
+
+// Stream iterates over the events of one of the API's server-sent event streams, reconnecting when the connection drops.
+// It ends when the context is cancelled, when the call ends, or when reconnecting keeps failing.
+//
+//	stream := c.ToolMessages(ctx, botID, guildID)
+//	defer stream.Close()
+//	for stream.Next() {
+//		event := stream.Value()
+//	}
+//	if err := stream.Err(); err != nil {
+//		...
+//	}
+type Stream[T any] struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	client    *Client
+	path      string
+	decode    func(data string) (T, error)
+	body      io.ReadCloser
+	scanner   *bufio.Scanner
+	connected bool
+	value     T
+	err       error
+}
+
+// Transcript streams the formatted lines of the call's transcript
+func (c *Client) Transcript(ctx context.Context, botID string, guildID string) *Stream[string] {
+	return newStream(ctx, c, callPath(botID, guildID, "transcript"), func(data string) (string, error) {
+		return data, nil
+	})
+}
+
+// ToolMessages streams tool messages and call events. Use Event.Decode to read their data.
+func (c *Client) ToolMessages(ctx context.Context, botID string, guildID string) *Stream[Event] {
+	return newStream(ctx, c, callPath(botID, guildID, "tool-messages"), decodeJSON[Event])
+}
+
+// Usages streams the TTS, transcription and LLM usage of the call
+func (c *Client) Usages(ctx context.Context, botID string, guildID string) *Stream[UsageEvent] {
+	return newStream(ctx, c, callPath(botID, guildID, "service-usages"), decodeJSON[UsageEvent])
+}
+
+// Decode unmarshals the JSON data of the event into v, e.g. a CallEndedEvent for call-ended events
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal([]byte(e.Data), v)
+}
+
+func decodeJSON[T any](data string) (T, error) {
+	var value T
+	err := json.Unmarshal([]byte(data), &value)
+	return value, err
+}
+
+func newStream[T any](ctx context.Context, client *Client, path string, decode func(data string) (T, error)) *Stream[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Stream[T]{
+		ctx:    ctx,
+		cancel: cancel,
+		client: client,
+		path:   path,
+		decode: decode,
+	}
+}
+
+// Next waits for the next event and reports whether there is one
+func (s *Stream[T]) Next() bool {
+	for s.err == nil {
+		if s.scanner == nil {
+			if !s.reconnect() {
+				return false
+			}
+		}
+
+		data, ok := s.readEvent()
+		if !ok {
+			// The connection dropped or the server closed the stream, try again
+			s.closeBody()
+			continue
+		}
+
+		value, err := s.decode(data)
+		if err != nil {
+			s.err = fmt.Errorf("error decoding event %q: %s", data, err)
+			return false
+		}
+
+		s.value = value
+		return true
+	}
+
+	return false
+}
+
+// Value returns the event read by the last call to Next
+func (s *Stream[T]) Value() T {
+	return s.value
+}
+
+// Err returns the error that ended the stream, if any. It is nil if the stream ended because the call ended
+// or the context was cancelled.
+func (s *Stream[T]) Err() error {
+	if errors.Is(s.err, io.EOF) {
+		return nil
+	}
+	return s.err
+}
+
+// Close stops the stream
+func (s *Stream[T]) Close() {
+	s.cancel()
+	s.closeBody()
+	if s.err == nil {
+		s.err = io.EOF
+	}
+}
+
+// reconnect opens the stream, retrying with exponential backoff, and reports whether it succeeded
+func (s *Stream[T]) reconnect() bool {
+	delay := minReconnectDelay
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-s.ctx.Done():
+				s.err = io.EOF
+				return false
+			case <-time.After(delay):
+			}
+			delay = minDuration(delay*2, maxReconnectDelay)
+		}
+
+		res, err := s.client.do(s.ctx, http.MethodGet, s.path, nil, "text/event-stream")
+		if err == nil {
+			s.body = res.Body
+			s.scanner = bufio.NewScanner(res.Body)
+			s.scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			s.connected = true
+			return true
+		}
+
+		if s.ctx.Err() != nil {
+			s.err = io.EOF
+			return false
+		}
+
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			if apiErr.StatusCode == http.StatusNotFound && s.connected {
+				// The call we were streaming has ended
+				s.err = io.EOF
+				return false
+			}
+			if apiErr.StatusCode != http.StatusNotFound && apiErr.StatusCode < 500 {
+				s.err = err
+				return false
+			}
+		}
+
+		if attempt+1 >= maxReconnectAttempts {
+			s.err = fmt.Errorf("giving up after %d attempts: %w", maxReconnectAttempts, err)
+			return false
+		}
+	}
+}
+
+// readEvent reads lines until the end of the next event and returns its data
+func (s *Stream[T]) readEvent() (string, bool) {
+	var dataLines []string
+
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+
+		if line == "" {
+			if len(dataLines) > 0 {
+				return strings.Join(dataLines, "\n"), true
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "data:") {
+			dataLines = append(dataLines, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	return "", false
+}
+
+func (s *Stream[T]) closeBody() {
+	if s.body != nil {
+		s.body.Close()
+	}
+	s.body = nil
+	s.scanner = nil
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
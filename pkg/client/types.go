@@ -0,0 +1,400 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The types below mirror the JSON accepted and returned by the teno-voice API. The server rejects unknown fields,
+// so these only ever contain fields the API knows about. See /openapi.json for the full description.
+
+type JoinRequest struct {
+	BotID              string
+	BotToken           string
+	GuildID            string
+	ChannelID          string
+	RedisTranscriptKey string `json:",omitempty"`
+	FollowUserID       string `json:",omitempty"`
+	Config             Config
+}
+
+// Config is the config of a call. When updating a call, only the non-nil sections are replaced.
+type Config struct {
+	BotName           string             `json:",omitempty"`
+	PromptContents    *PromptContents    `json:",omitempty"`
+	VoiceUXConfig     *VoiceUXConfig     `json:",omitempty"`
+	LLMConfig         *LLMConfig         `json:",omitempty"`
+	TTSConfig         *TTSConfig         `json:",omitempty"`
+	TranscriptConfig  *TranscriptConfig  `json:",omitempty"`
+	TranscriberConfig *TranscriberConfig `json:",omitempty"`
+	LifecycleConfig   *LifecycleConfig   `json:",omitempty"`
+	AccessPolicy      *AccessPolicy      `json:",omitempty"`
+}
+
+type PromptContents struct {
+	BotPrimer              string
+	CustomTranscriptPrimer string              `json:",omitempty"`
+	CustomToolPrimer       string              `json:",omitempty"`
+	CustomDocumentPrimer   string              `json:",omitempty"`
+	CustomTaskPrimer       string              `json:",omitempty"`
+	CustomSummaryPrimer    string              `json:",omitempty"`
+	Template               string              `json:",omitempty"`
+	Timezone               string              `json:",omitempty"`
+	RepairToolCalls        bool                `json:",omitempty"`
+	Tools                  []Tool              `json:",omitempty"`
+	BuiltinTools           *BuiltinToolsConfig `json:",omitempty"`
+	Documents              []Document          `json:",omitempty"`
+	Retrieval              *RetrievalConfig    `json:",omitempty"`
+	Tasks                  []Task              `json:",omitempty"`
+	TokenBudget            *TokenBudget        `json:",omitempty"`
+}
+
+// TokenBudget sizes the prompt to fit the model's context. Shares are fractions of the context left after the response.
+type TokenBudget struct {
+	ContextTokens int     `json:",omitempty"`
+	Primers       float64 `json:",omitempty"`
+	Tools         float64 `json:",omitempty"`
+	Documents     float64 `json:",omitempty"`
+	Summary       float64 `json:",omitempty"`
+	Transcript    float64 `json:",omitempty"`
+}
+
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	InputGuide  string `json:"inputGuide"`
+	OutputGuide string `json:"outputGuide"`
+	// InputSchema is an optional JSON Schema for structured inputs
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+	// Policy is fire-immediately, fire-after-speech or cancel-on-interrupt (the default)
+	Policy string `json:"policy,omitempty"`
+	// RequiresConfirmation makes the bot read invocations back and wait for a yes before sending them
+	RequiresConfirmation bool `json:"requiresConfirmation,omitempty"`
+}
+
+// BuiltinToolsConfig enables tools the bot executes itself
+type BuiltinToolsConfig struct {
+	LeaveCall       bool     `json:",omitempty"`
+	GoToSleep       bool     `json:",omitempty"`
+	MuteSelf        bool     `json:",omitempty"`
+	ChangeVoice     bool     `json:",omitempty"`
+	Voices          []string `json:",omitempty"`
+	SetSpeakingMode bool     `json:",omitempty"`
+	PlayClip        bool     `json:",omitempty"`
+	Clips           []Clip   `json:",omitempty"`
+	SetTaskStatus   bool     `json:",omitempty"`
+	ScheduleJobs    bool     `json:",omitempty"`
+}
+
+// Clip is a sound the bot can play. The URL must point to an Ogg Opus file.
+type Clip struct {
+	Name        string
+	Description string `json:",omitempty"`
+	URL         string
+}
+
+type Document struct {
+	Name    string
+	Content string
+	// Priority decides which documents are kept first when they don't all fit in the token budget
+	Priority int `json:",omitempty"`
+}
+
+// RetrievalConfig adds only the chunks of the documents most relevant to the conversation to the prompt
+type RetrievalConfig struct {
+	ChunkWords     int    `json:",omitempty"`
+	ChunkOverlap   int    `json:",omitempty"`
+	TopK           int    `json:",omitempty"`
+	MaxTokens      int    `json:",omitempty"`
+	QueryLines     int    `json:",omitempty"`
+	EmbeddingModel string `json:",omitempty"`
+}
+
+// Task statuses
+const (
+	TaskPending    = "pending"
+	TaskInProgress = "in-progress"
+	TaskDone       = "done"
+	TaskFailed     = "failed"
+)
+
+type Task struct {
+	ID               string `json:",omitempty"`
+	Name             string
+	Description      string
+	DeliverableGuide string
+	Status           string     `json:",omitempty"`
+	Due              *time.Time `json:",omitempty"`
+	// ReminderInterval is in seconds
+	ReminderInterval int `json:",omitempty"`
+}
+
+// TaskUpdate is sent as a task event when a task is added or removed, its status changes, or it becomes overdue.
+// Reason is "added", "removed", "config", "tool" or "overdue".
+type TaskUpdate struct {
+	Task   Task
+	From   string
+	Reason string
+}
+
+type VoiceUXConfig struct {
+	SpeakingMode               string
+	LinesBeforeSleep           int
+	BotNameConfidenceThreshold float64
+	AutoRespondInterval        int
+	BargeIn                    *BargeInConfig       `json:",omitempty"`
+	ConfirmationTimeout        int                  `json:",omitempty"`
+	BotNameAliases             []string             `json:",omitempty"`
+	FuzzyWakeThreshold         float64              `json:",omitempty"`
+	DirectAddress              *DirectAddressConfig `json:",omitempty"`
+	AwakeWindow                int                  `json:",omitempty"`
+	Earcons                    *EarconConfig        `json:",omitempty"`
+}
+
+// EarconConfig has the URLs of Ogg Opus sounds played when the bot wakes up or falls asleep
+type EarconConfig struct {
+	Wake  string `json:",omitempty"`
+	Sleep string `json:",omitempty"`
+}
+
+// DirectAddressConfig tunes the DirectAddress speaking mode. FollowUpWindow is in seconds and ClassifierTimeout in milliseconds.
+type DirectAddressConfig struct {
+	FollowUpWindow    int    `json:",omitempty"`
+	Classifier        bool   `json:",omitempty"`
+	ClassifierModel   string `json:",omitempty"`
+	ClassifierTimeout int    `json:",omitempty"`
+}
+
+// BargeInConfig durations are in milliseconds
+type BargeInConfig struct {
+	MinWords       int
+	MinDuration    int
+	Backchannels   []string
+	PauseAndResume bool
+	ResumeTimeout  int
+}
+
+type LLMConfig struct {
+	LLMServiceName string
+	LLMConfig      interface{}
+}
+
+type TTSConfig struct {
+	TTSServiceName string
+	TTSConfig      interface{}
+}
+
+type TranscriptConfig struct {
+	NumberOfTranscriptLines int
+	PresenceLines           bool
+	Summary                 *SummaryConfig `json:",omitempty"`
+}
+
+// SummaryConfig turns on the rolling summary of the lines that fall out of the transcript
+type SummaryConfig struct {
+	Model      string `json:",omitempty"`
+	BatchLines int    `json:",omitempty"`
+	MaxWords   int    `json:",omitempty"`
+}
+
+// RenderedPrompt is the prompt the bot would respond to, as returned by Prompt
+type RenderedPrompt struct {
+	Messages              []PromptMessage
+	PromptTokensBySection map[string]int
+}
+
+type PromptMessage struct {
+	Role    string
+	Content string
+}
+
+// Summary is the rolling summary of the part of the call that is no longer in the transcript, also sent as a summary event
+type Summary struct {
+	Text            string
+	LinesSummarized int
+	UpdatedAt       time.Time
+}
+
+type TranscriberConfig struct {
+	Keywords      []string
+	IgnoredUsers  []string
+	TurnDetection *TurnDetectionConfig `json:",omitempty"`
+}
+
+// TurnDetectionConfig durations are in milliseconds. Each one left at 0 uses its default.
+type TurnDetectionConfig struct {
+	SilenceDebounce        int `json:",omitempty"`
+	IncompleteTurnDebounce int `json:",omitempty"`
+	UtteranceEndMs         int `json:",omitempty"`
+}
+
+type LifecycleConfig struct {
+	EmptyChannelGracePeriod int
+	MaxCallDuration         int
+	SilenceTimeout          int
+	FollowedUserGracePeriod int
+}
+
+// AccessPolicy controls who can wake the bot, trigger its responses and have it use tools. Rules that aren't set allow everyone.
+type AccessPolicy struct {
+	Wake    *AccessRule           `json:",omitempty"`
+	Respond *AccessRule           `json:",omitempty"`
+	Tools   map[string]AccessRule `json:",omitempty"`
+}
+
+// AccessRule allows or denies participants by Discord user ID and role ID. Denials win over allowances.
+type AccessRule struct {
+	AllowUsers []string `json:",omitempty"`
+	DenyUsers  []string `json:",omitempty"`
+	AllowRoles []string `json:",omitempty"`
+	DenyRoles  []string `json:",omitempty"`
+}
+
+type Participant struct {
+	UserID      string
+	Username    string
+	DisplayName string
+	Bot         bool
+	Muted       bool
+	Deafened    bool
+}
+
+// Event is a message on the tool-messages stream. Data is a plain string or JSON, depending on Type.
+type Event struct {
+	Type string
+	Data string
+}
+
+// ToolMessage is a tool invocation. Input is a JSON string, or a value matching the tool's input schema.
+type ToolMessage struct {
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	SpeakerID string          `json:"speakerId,omitempty"`
+}
+
+// ToolRejection is an invalid tool invocation, sent as a tool-rejected event
+type ToolRejection struct {
+	Name   string          `json:"name"`
+	Input  json.RawMessage `json:"input"`
+	Reason string          `json:"reason"`
+}
+
+// BuiltinToolExecution is a built-in tool the bot executed, sent as a builtin-tool event
+type BuiltinToolExecution struct {
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+	Error string          `json:"error,omitempty"`
+}
+
+// JobSpec describes a job to schedule. A job runs once At a time or In some seconds, or Every some seconds
+// starting At or In if either is set. It needs either Say, spoken as is, or Instruction, which the bot responds to.
+type JobSpec struct {
+	Name        string     `json:",omitempty"`
+	At          *time.Time `json:",omitempty"`
+	In          int        `json:",omitempty"`
+	Every       int        `json:",omitempty"`
+	Say         string     `json:",omitempty"`
+	Instruction string     `json:",omitempty"`
+}
+
+// Job is a scheduled job. Source is api or tool.
+type Job struct {
+	JobSpec
+	ID      string
+	Source  string
+	NextRun time.Time
+	Runs    int
+}
+
+// JobUpdate is sent as a job event when a job is scheduled, runs or is cancelled. Reason is scheduled, ran or cancelled.
+type JobUpdate struct {
+	Job    Job
+	Reason string
+}
+
+// PendingConfirmation is a tool invocation waiting to be confirmed, also sent as a confirmation-pending event
+type PendingConfirmation struct {
+	ID          string
+	ToolMessage ToolMessage
+	ExpiresAt   time.Time
+}
+
+// ConfirmationResolution is sent as a confirmation-resolved event. Outcome is confirmed, declined, unanswered or timed-out.
+type ConfirmationResolution struct {
+	ID          string
+	ToolMessage ToolMessage
+	Outcome     string
+	ResolvedBy  string
+}
+
+type CallEndedEvent struct {
+	Reason   string
+	Duration float64
+}
+
+type PresenceEvent struct {
+	Type        string
+	Participant Participant
+}
+
+// StateChange is the Data of a responder-state event. States are idle, listening, thinking, speaking and sleeping.
+type StateChange struct {
+	From   string
+	To     string
+	Reason string
+}
+
+// WakeEvent is sent as a wake event when a participant wakes the bot by saying its name.
+// Alias is empty when only the transcriber's search heard the name.
+type WakeEvent struct {
+	UserID           string
+	Username         string
+	Alias            string
+	Score            float64
+	SearchConfidence float64
+	// Combined is Score combined with SearchConfidence, which FuzzyWakeThreshold is checked against
+	Combined float64
+}
+
+// RetrievalEvent is sent as a retrieval event with the chunks of the documents added to a response's prompt
+type RetrievalEvent struct {
+	Query     string
+	Retriever string
+	Chunks    []RetrievedChunk
+}
+
+type RetrievedChunk struct {
+	Document string
+	Index    int
+	Score    float64
+	Tokens   int
+}
+
+type MovedEvent struct {
+	ChannelID string
+}
+
+// UsageEvent is a message on the service-usages stream. UsageType is one of TextToSpeech, Transcription or LLM,
+// and only the fields for that type are set.
+type UsageEvent struct {
+	UsageType        string
+	Service          string
+	Model            string
+	Characters       int
+	Minutes          float64
+	PromptTokens     int
+	CompletionTokens int
+	// PromptTokensBySection breaks an LLM event's prompt tokens down by section of the prompt
+	PromptTokensBySection map[string]int `json:",omitempty"`
+	// Purpose is what an LLM event's tokens were spent on: "response", "summary", "addressee" or "embeddings"
+	Purpose string `json:",omitempty"`
+}
+
+type CallInfo struct {
+	BotID        string
+	GuildID      string
+	ChannelID    string
+	BotName      string
+	StartTime    time.Time
+	Participants int
+}
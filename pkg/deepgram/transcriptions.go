@@ -37,6 +37,7 @@ type LiveTranscriptionOptions struct {
 	Tag              []string `json:"tag" url:"tag,omitempty" `
 	Tier             string   `json:"tier" url:"tier,omitempty" `
 	Times            bool     `json:"times" url:"times,omitempty" `
+	Utterance_end_ms int      `json:"utterance_end_ms" url:"utterance_end_ms,omitempty" ` // Milliseconds of silence after the last finalized word before an UtteranceEnd message is sent. Requires interim results.
 	Vad_turnoff      int      `json:"vad_turnoff" url:"vad_turnoff,omitempty" `
 	Version          string   `json:"version" url:"version,omitempty" `
 }